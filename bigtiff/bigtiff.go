@@ -94,4 +94,5 @@ func ParseBigTIFF(ordr [2]byte, vers uint16, br tiff.BReader, tsp tiff.TagSpace,
 
 func init() {
 	tiff.RegisterVersion(Version, ParseBigTIFF)
+	tiff.RegisterIFDParser(Version, ParseIFD)
 }
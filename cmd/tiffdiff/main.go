@@ -0,0 +1,140 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command tiffdiff compares the structure of two TIFF files.
+
+Usage:
+
+	tiffdiff [flags] a.tif b.tif
+
+Differences in the header, IFDs that were added or removed, and fields whose
+type, count or value changed are printed one per line (see tiff.Diff).  The
+values of fields holding offsets are not compared since they move whenever a
+file is rewritten.  The data those offsets point to is compared instead, and
+data that is identical is reported as such.  Image data is compared once
+decoded, so an image that was only recompressed, re-stripped or re-tiled has
+identical data.  Image data that cannot be decoded is compared as it is
+stored, which the report says.
+
+The exit status is 0 if the files are the same, 1 if they differ and 2 if an
+error occurred.
+
+The flags are:
+
+	-json
+		Print the differences and the identical data as JSON instead of
+		text.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/tiff"
+	_ "github.com/google/tiff/bigtiff"
+	_ "github.com/google/tiff/dng"
+	_ "github.com/google/tiff/exif"
+	_ "github.com/google/tiff/geotiff"
+	_ "github.com/google/tiff/image"
+	_ "github.com/google/tiff/modi"
+	_ "github.com/google/tiff/tiff85"
+	_ "github.com/google/tiff/tiffep"
+)
+
+var asJSON = flag.Bool("json", false, "print the differences as JSON")
+
+type diffDump struct {
+	Kind string `json:"kind"`
+	Path string `json:"path,omitempty"`
+	Tag  uint16 `json:"tag,omitempty"`
+	Name string `json:"name,omitempty"`
+	A    string `json:"a,omitempty"`
+	B    string `json:"b,omitempty"`
+}
+
+func parse(name string) (tiff.TIFF, *os.File, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := tiff.Parse(fh, nil, nil)
+	if err != nil {
+		fh.Close()
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return t, fh, nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tiffdiff: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tiffdiff [flags] a.tif b.tif\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a, fa, err := parse(flag.Arg(0))
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	defer fa.Close()
+	b, fb, err := parse(flag.Arg(1))
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	defer fb.Close()
+
+	diffs, comps, err := tiff.DiffWithData(a, b)
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	var same []tiff.DataComparison
+	for _, c := range comps {
+		if c.Same {
+			same = append(same, c)
+		}
+	}
+	if *asJSON {
+		dumps := make([]diffDump, 0, len(diffs)+len(same))
+		for _, d := range diffs {
+			dumps = append(dumps, diffDump{d.Kind.String(), d.Path, d.Tag, d.Name, d.A, d.B})
+		}
+		for _, c := range same {
+			kind := "stored data identical"
+			if c.Decoded {
+				kind = "decoded data identical"
+			}
+			dumps = append(dumps, diffDump{Kind: kind, Path: c.Path, Tag: c.Tag, Name: c.Name})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(dumps); err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+	} else {
+		for _, d := range diffs {
+			fmt.Println(d)
+		}
+		for _, c := range same {
+			fmt.Println(c)
+		}
+	}
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
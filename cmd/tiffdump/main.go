@@ -0,0 +1,250 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command tiffdump prints the structure of TIFF files.
+
+Usage:
+
+	tiffdump [flags] file...
+
+For each file, the header is printed followed by every IFD in the file and
+every IFD reachable through a registered sub-IFD tag (SubIFDs, Exif, GPS and
+Interoperability).  Each field is shown with its tag name, the tag set that
+defines it, its field type, its count and its value.
+
+The flags are:
+
+	-full
+		Print every value of each field instead of a shortened form.
+	-json
+		Print the output as JSON instead of text.
+	-ifd path
+		Only print the IFD at path (i.e. "0", "0/34665" or "1/330:2").
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/tiff"
+	_ "github.com/google/tiff/bigtiff"
+	_ "github.com/google/tiff/dng"
+	_ "github.com/google/tiff/exif"
+	_ "github.com/google/tiff/geotiff"
+	_ "github.com/google/tiff/modi"
+	_ "github.com/google/tiff/tiff85"
+	_ "github.com/google/tiff/tiffep"
+)
+
+var (
+	full    = flag.Bool("full", false, "print every value of each field")
+	asJSON  = flag.Bool("json", false, "print the output as JSON")
+	ifdPath = flag.String("ifd", "", "only print the IFD at this path (i.e. 0, 0/34665 or 1/330:2)")
+)
+
+type fieldDump struct {
+	Tag      uint16 `json:"tag"`
+	Name     string `json:"name"`
+	TagSet   string `json:"tagSet"`
+	Type     uint16 `json:"type"`
+	TypeName string `json:"typeName"`
+	Count    uint64 `json:"count"`
+	Offset   uint64 `json:"offset,omitempty"`
+	Value    string `json:"value"`
+}
+
+type ifdDump struct {
+	Path       string      `json:"path"`
+	TagSpace   string      `json:"tagSpace"`
+	NumEntries uint64      `json:"numEntries"`
+	NextOffset uint64      `json:"nextOffset"`
+	Fields     []fieldDump `json:"fields"`
+}
+
+type fileDump struct {
+	File        string    `json:"file"`
+	Order       string    `json:"order"`
+	Version     uint16    `json:"version"`
+	OffsetSize  uint16    `json:"offsetSize"`
+	FirstOffset uint64    `json:"firstOffset"`
+	IFDs        []ifdDump `json:"ifds"`
+}
+
+// valueString renders the value of f.  The tag's FieldInterpreter is used when
+// it has something to say.  Otherwise, the values are rendered with the
+// representation of the field type.
+func valueString(f tiff.Field) string {
+	if s := f.Tag().Interpreter()(f); s != "" {
+		return s
+	}
+	const (
+		maxItems = 10
+		maxChars = 40
+	)
+	printFull := tiff.GetTiffFieldPrintFullFieldValue()
+	ft := f.Type()
+	buf := f.Value().Bytes()
+	count := f.Count()
+
+	if ft.ReflectType().Kind() == reflect.String {
+		if count < uint64(len(buf)) {
+			buf = buf[:count]
+		}
+		s := strings.TrimRight(string(buf), "\x00")
+		if !printFull && len(s) > maxChars {
+			return strconv.Quote(s[:maxChars]) + "..."
+		}
+		return strconv.Quote(s)
+	}
+
+	size := ft.Size()
+	if size == 0 {
+		return fmt.Sprintf("%v", buf)
+	}
+	n := count
+	if !printFull && n > maxItems {
+		n = maxItems
+	}
+	vals := make([]string, 0, n)
+	for i := uint64(0); i < n && uint64(len(buf)) >= size; i++ {
+		if repr := ft.Repr(); repr != nil {
+			vals = append(vals, repr(buf[:size], f.Value().Order()))
+		} else {
+			vals = append(vals, fmt.Sprintf("%v", buf[:size]))
+		}
+		buf = buf[size:]
+	}
+	s := strings.Join(vals, " ")
+	if count == 1 {
+		return s
+	}
+	if uint64(len(vals)) < count {
+		s += " ..."
+	}
+	return "[" + s + "]"
+}
+
+func dumpIFD(path string, ifd tiff.IFD, tsp tiff.TagSpace) ifdDump {
+	d := ifdDump{
+		Path:       path,
+		TagSpace:   tsp.Name(),
+		NumEntries: ifd.NumEntries(),
+		NextOffset: ifd.NextOffset(),
+	}
+	for _, f := range ifd.Fields() {
+		tagID := f.Tag().ID()
+		d.Fields = append(d.Fields, fieldDump{
+			Tag:      tagID,
+			Name:     f.Tag().Name(),
+			TagSet:   tsp.GetTagSetNameFromTag(tagID),
+			Type:     f.Type().ID(),
+			TypeName: f.Type().Name(),
+			Count:    f.Count(),
+			Offset:   f.Offset(),
+			Value:    valueString(f),
+		})
+	}
+	return d
+}
+
+func dumpFile(name string) (*fileDump, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	t, err := tiff.Parse(fh, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := &fileDump{
+		File:        name,
+		Order:       t.Order(),
+		Version:     t.Version(),
+		OffsetSize:  t.OffsetSize(),
+		FirstOffset: t.FirstOffset(),
+	}
+	if *ifdPath != "" {
+		ifd, tsp, err := tiff.FindIFD(t, nil, *ifdPath)
+		if err != nil {
+			return nil, err
+		}
+		d.IFDs = append(d.IFDs, dumpIFD(*ifdPath, ifd, tsp))
+		return d, nil
+	}
+	err = tiff.WalkIFDs(t, nil, func(path string, ifd tiff.IFD, tsp tiff.TagSpace) error {
+		d.IFDs = append(d.IFDs, dumpIFD(path, ifd, tsp))
+		return nil
+	})
+	return d, err
+}
+
+func printText(w io.Writer, d *fileDump) {
+	fmt.Fprintf(w, "%s: order %q, version %d, offset size %d, first IFD at %d\n",
+		d.File, d.Order, d.Version, d.OffsetSize, d.FirstOffset)
+	for _, ifd := range d.IFDs {
+		fmt.Fprintf(w, "\nIFD %s (TagSpace %q): %d entries, next IFD at %d\n",
+			ifd.Path, ifd.TagSpace, ifd.NumEntries, ifd.NextOffset)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, f := range ifd.Fields {
+			fmt.Fprintf(tw, "  %#04x/%05[1]d\t%s\t%s\t%s\t%d\t%s\n",
+				f.Tag, f.Name, f.TagSet, f.TypeName, f.Count, f.Value)
+		}
+		tw.Flush()
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tiffdump: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tiffdump [flags] file...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	tiff.SetTiffFieldPrintFullFieldValue(*full)
+
+	var (
+		dumps  []*fileDump
+		failed bool
+	)
+	for _, name := range flag.Args() {
+		d, err := dumpFile(name)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			failed = true
+			continue
+		}
+		if *asJSON {
+			dumps = append(dumps, d)
+			continue
+		}
+		printText(os.Stdout, d)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(dumps); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+// writeTestFile writes a TIFF whose only IFD has a description and two
+// SubIFDs, each with a description of its own.
+func writeTestFile(t *testing.T) string {
+	t.Helper()
+	bo := binary.LittleEndian
+	desc := func(s string) *tiff.WritableIFD {
+		return &tiff.WritableIFD{Fields: []tiff.Field{
+			tiff.NewField(270, 2, uint32(len(s)+1), append([]byte(s), 0), bo, nil, nil),
+			tiff.NewField(256, 3, 1, []byte{7, 0}, bo, nil, nil),
+		}}
+	}
+	root := desc("root")
+	root.SubIFDs = map[uint16][]*tiff.WritableIFD{330: {desc("first"), desc("second")}}
+	var buf bytes.Buffer
+	if err := tiff.Write(&buf, bo, []*tiff.WritableIFD{root}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "test.tif")
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func fieldValue(d ifdDump, name string) string {
+	for _, f := range d.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func TestDumpFile(t *testing.T) {
+	name := writeTestFile(t)
+	d, err := dumpFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Order != "II" || d.Version != 42 || d.OffsetSize != 4 || d.FirstOffset != 8 {
+		t.Errorf("header is %q %d %d %d", d.Order, d.Version, d.OffsetSize, d.FirstOffset)
+	}
+	var paths, descs []string
+	for _, ifd := range d.IFDs {
+		paths = append(paths, ifd.Path)
+		descs = append(descs, fieldValue(ifd, "ImageDescription"))
+		if ifd.NumEntries != uint64(len(ifd.Fields)) {
+			t.Errorf("IFD %s: %d entries, but %d fields", ifd.Path, ifd.NumEntries, len(ifd.Fields))
+		}
+	}
+	if want := []string{"0", "0/330:0", "0/330:1"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths are %v, want %v", paths, want)
+	}
+	if want := []string{`"root"`, `"first"`, `"second"`}; !reflect.DeepEqual(descs, want) {
+		t.Errorf("descriptions are %v, want %v", descs, want)
+	}
+	if got := fieldValue(d.IFDs[0], "ImageWidth"); got != "7" {
+		t.Errorf("ImageWidth is %q, want 7", got)
+	}
+
+	var out bytes.Buffer
+	printText(&out, d)
+	for _, s := range []string{"IFD 0/330:1 (TagSpace", `ImageDescription`, `"second"`} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("text output lacks %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestDumpFileIFDPath(t *testing.T) {
+	name := writeTestFile(t)
+	defer func(p string) { *ifdPath = p }(*ifdPath)
+
+	*ifdPath = "0/330:1"
+	d, err := dumpFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.IFDs) != 1 || d.IFDs[0].Path != "0/330:1" || fieldValue(d.IFDs[0], "ImageDescription") != `"second"` {
+		t.Errorf("dumped %+v, want only the second SubIFD", d.IFDs)
+	}
+	for _, path := range []string{"1", "0/330:2", "0/34665", "0/330:"} {
+		*ifdPath = path
+		if _, err := dumpFile(name); err == nil {
+			t.Errorf("-ifd %s succeeded, want an error", path)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command tiffset changes the fields of a TIFF file.
+
+Usage:
+
+	tiffset [flags] file
+
+Examples:
+
+	tiffset -ifd 0 -tag ImageDescription -set "A new description" in.tif
+	tiffset -delete 305 -delete DateTime -o out.tif in.tif
+	tiffset -from-json edits.json in.tif
+
+Tags are given by name or by id and are looked up in the TagSpace of the IFD
+being changed.  Values are checked against the field type of the existing
+field.  A field that does not exist yet gets the field type its tag is known to
+have (i.e. ASCII for ImageDescription).  For other tags, the field type must be
+given with -type.
+Values follow the same rules as the "def" key of a tiff field struct tag (see
+tiff.EncodeFieldValue): ASCII is used as is, multiple values are separated by
+',' and rationals have the form x/y.
+
+The flags are:
+
+	-ifd path
+		The path of the IFD to change (i.e. "0", "0/34665" or "1/330:2").
+	-tag tag
+		The tag of the field to set.
+	-set value
+		The value to set.
+	-type type
+		The field type (name or id) to use when adding a new field.
+	-delete tag
+		Delete the field for tag.  May be repeated.
+	-from-json file
+		Apply the list of edits in file.  Each edit is an object with the
+		keys "ifd", "tag", "set", "type" and "delete" which have the same
+		meaning as the flags above.
+	-o file
+		Write the result to file instead of changing the input in place.
+
+The offsets to image data and to other IFDs are rebuilt when the file is
+written, so the fields holding them cannot be set.  Only TIFF files (version
+42) can be written.
+*/
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/tiff"
+	_ "github.com/google/tiff/bigtiff"
+	_ "github.com/google/tiff/dng"
+	_ "github.com/google/tiff/exif"
+	_ "github.com/google/tiff/geotiff"
+	_ "github.com/google/tiff/modi"
+	_ "github.com/google/tiff/tiff85"
+	_ "github.com/google/tiff/tiffep"
+)
+
+type stringList []string
+
+func (sl *stringList) String() string     { return strings.Join(*sl, ",") }
+func (sl *stringList) Set(s string) error { *sl = append(*sl, s); return nil }
+
+var (
+	ifdPath  = flag.String("ifd", "0", "the path of the IFD to change (i.e. 0, 0/34665 or 1/330:2)")
+	tagFlag  = flag.String("tag", "", "the tag (name or id) of the field to set")
+	setFlag  = flag.String("set", "", "the value to set")
+	typeFlag = flag.String("type", "", "the field type (name or id) to use when adding a new field")
+	fromJSON = flag.String("from-json", "", "apply the list of edits in this JSON file")
+	output   = flag.String("o", "", "write the result to this file instead of changing the input in place")
+	deletes  stringList
+)
+
+// tagRef refers to a tag by name or by id.  In JSON it may be a string or a
+// number.
+type tagRef string
+
+func (tr *tagRef) UnmarshalJSON(b []byte) error {
+	var n uint16
+	if err := json.Unmarshal(b, &n); err == nil {
+		*tr = tagRef(strconv.Itoa(int(n)))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("tag must be a name or an id: %s", b)
+	}
+	*tr = tagRef(s)
+	return nil
+}
+
+type edit struct {
+	IFD    string  `json:"ifd"`
+	Tag    tagRef  `json:"tag"`
+	Set    *string `json:"set"`
+	Type   string  `json:"type"`
+	Delete bool    `json:"delete"`
+}
+
+func resolveTag(tsp tiff.TagSpace, ref tagRef) (uint16, error) {
+	if id, err := strconv.ParseUint(string(ref), 10, 16); err == nil {
+		return uint16(id), nil
+	}
+	t, ok := tiff.GetTagByName(tsp, string(ref))
+	if !ok {
+		return 0, fmt.Errorf("no tag named %q in TagSpace %q", ref, tsp.Name())
+	}
+	return t.ID(), nil
+}
+
+func resolveFieldType(ftsp tiff.FieldTypeSpace, name string) (tiff.FieldType, error) {
+	if id, err := strconv.ParseUint(name, 10, 16); err == nil {
+		return ftsp.GetFieldType(uint16(id)), nil
+	}
+	for _, ftsName := range ftsp.ListFieldTypeSets() {
+		fts, ok := ftsp.GetFieldTypeSet(ftsName)
+		if !ok {
+			continue
+		}
+		for _, id := range fts.ListFieldTypes() {
+			if ft, ok := fts.GetFieldType(id); ok && strings.EqualFold(ft.Name(), name) {
+				return ft, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no field type named %q", name)
+}
+
+func isRebuiltTag(tagID uint16) bool {
+	if _, ok := tiff.GetSubIFDTagSpace(tagID); ok {
+		return true
+	}
+	if tagID >= 519 && tagID <= 521 { // JPEGQTables, JPEGDCTables, JPEGACTables
+		return true
+	}
+	for _, offTagID := range tiff.ListOffsetTags() {
+		bcTagID, _ := tiff.GetByteCountTag(offTagID)
+		if tagID == offTagID || tagID == bcTagID {
+			return true
+		}
+	}
+	return false
+}
+
+func apply(wifds []*tiff.WritableIFD, bo binary.ByteOrder, e edit) error {
+	path := e.IFD
+	if path == "" {
+		path = "0"
+	}
+	wi, err := tiff.FindWritableIFD(wifds, path)
+	if err != nil {
+		return err
+	}
+	tsp := wi.TagSpace
+	if tsp == nil {
+		tsp = tiff.DefaultTagSpace
+	}
+	tagID, err := resolveTag(tsp, e.Tag)
+	if err != nil {
+		return err
+	}
+	if e.Delete {
+		if !wi.DeleteField(tagID) {
+			return fmt.Errorf("IFD %s has no field for tag %d", path, tagID)
+		}
+		return nil
+	}
+	if e.Set == nil {
+		return fmt.Errorf("nothing to do for tag %d: need a value to set or delete", tagID)
+	}
+	if isRebuiltTag(tagID) {
+		return fmt.Errorf("tag %d holds offsets that are rebuilt when writing and cannot be set", tagID)
+	}
+	var ft tiff.FieldType
+	switch {
+	case e.Type != "":
+		if ft, err = resolveFieldType(tiff.DefaultFieldTypeSpace, e.Type); err != nil {
+			return err
+		}
+	case wi.Field(tagID) != nil:
+		ft = wi.Field(tagID).Type()
+	default:
+		tt, ok := tsp.GetTag(tagID).(tiff.TypedTag)
+		if !ok || len(tt.FieldTypes()) == 0 {
+			return fmt.Errorf("IFD %s has no field for tag %d and its field type is unknown: the field type must be given", path, tagID)
+		}
+		ft = tiff.DefaultFieldTypeSpace.GetFieldType(tt.FieldTypes()[0])
+	}
+	val, count, err := tiff.EncodeFieldValue(ft, *e.Set, bo)
+	if err != nil {
+		return err
+	}
+	wi.SetField(tiff.NewField(tagID, ft.ID(), count, val, bo, tsp, nil))
+	return nil
+}
+
+func run(name string, edits []edit) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	t, err := tiff.Parse(in, nil, nil)
+	if err != nil {
+		return err
+	}
+	if t.Version() != tiff.Version {
+		return fmt.Errorf("writing version %d files is not supported", t.Version())
+	}
+	bo := tiff.GetByteOrder(binary.BigEndian.Uint16([]byte(t.Order())))
+	wifds, err := tiff.NewWritableIFDs(t, nil)
+	if err != nil {
+		return err
+	}
+	for _, e := range edits {
+		if err := apply(wifds, bo, e); err != nil {
+			return err
+		}
+	}
+
+	dst := *output
+	if dst == "" {
+		dst = name
+	}
+	// Write to a temporary file next to the destination first.  This
+	// allows changing the input in place since its data is still being
+	// read while writing.
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tiff.Write(tmp, bo, wifds); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if fi, err := in.Stat(); err == nil {
+		os.Chmod(tmp.Name(), fi.Mode().Perm())
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tiffset: ")
+	flag.Var(&deletes, "delete", "delete the field for this tag (name or id); may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tiffset [flags] file\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var edits []edit
+	if *fromJSON != "" {
+		b, err := os.ReadFile(*fromJSON)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(b, &edits); err != nil {
+			log.Fatalf("%s: %v", *fromJSON, err)
+		}
+	}
+	if *tagFlag != "" {
+		setFlagGiven := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "set" {
+				setFlagGiven = true
+			}
+		})
+		if !setFlagGiven {
+			log.Fatal("-tag needs a value given with -set")
+		}
+		value := *setFlag
+		edits = append(edits, edit{IFD: *ifdPath, Tag: tagRef(*tagFlag), Set: &value, Type: *typeFlag})
+	}
+	for _, d := range deletes {
+		edits = append(edits, edit{IFD: *ifdPath, Tag: tagRef(d), Delete: true})
+	}
+	if len(edits) == 0 {
+		log.Fatal("no edits given")
+	}
+	if err := run(flag.Arg(0), edits); err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+// writeTestFile writes a TIFF whose only IFD has a Software field and a
+// strip, but no ImageDescription.
+func writeTestFile(t *testing.T) string {
+	t.Helper()
+	bo := binary.BigEndian
+	wi := &tiff.WritableIFD{
+		Fields: []tiff.Field{
+			tiff.NewField(256, 3, 1, []byte{0, 4}, bo, nil, nil),
+			tiff.NewField(305, 2, 5, []byte("test\x00"), bo, nil, nil),
+		},
+		Data: map[uint16][]*io.SectionReader{
+			273: {io.NewSectionReader(strings.NewReader("strip data"), 0, 10)},
+		},
+	}
+	var buf bytes.Buffer
+	if err := tiff.Write(&buf, bo, []*tiff.WritableIFD{wi}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "test.tif")
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func parseFile(t *testing.T, name string) (tiff.TIFF, []byte) {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf, err := tiff.Parse(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tf, b
+}
+
+func setEdit(tag, value, typ string) edit {
+	return edit{Tag: tagRef(tag), Set: &value, Type: typ}
+}
+
+func TestRun(t *testing.T) {
+	name := writeTestFile(t)
+	edits := []edit{
+		// New fields get the field type of their tag.
+		setEdit("ImageDescription", "A new description", ""),
+		setEdit("XResolution", "300/1", ""),
+		setEdit("282", "300/1", ""),
+		// Existing fields keep their field type.
+		setEdit("ImageWidth", "5", ""),
+		// Unknown tags need the field type.
+		setEdit("65000", "1,2", "Short"),
+		{Tag: "Software", Delete: true},
+	}
+	if err := run(name, edits); err != nil {
+		t.Fatal(err)
+	}
+	tf, b := parseFile(t, name)
+	ifd := tf.IFDs()[0]
+	want := map[uint16]struct {
+		typeID uint16
+		count  uint64
+	}{
+		256:   {3, 1},
+		270:   {2, 18},
+		282:   {5, 1},
+		65000: {3, 2},
+	}
+	for tagID, w := range want {
+		if !ifd.HasField(tagID) {
+			t.Errorf("no field for tag %d", tagID)
+			continue
+		}
+		f := ifd.GetField(tagID)
+		if f.Type().ID() != w.typeID || f.Count() != w.count {
+			t.Errorf("tag %d: %s[%d], want type %d count %d", tagID, f.Type().Name(), f.Count(), w.typeID, w.count)
+		}
+	}
+	if got := string(ifd.GetField(270).Value().Bytes()); got != "A new description\x00" {
+		t.Errorf("ImageDescription is %q", got)
+	}
+	if ifd.HasField(305) {
+		t.Error("Software was not deleted")
+	}
+	if !bytes.Contains(b, []byte("strip data")) {
+		t.Error("the strip was lost")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		edit edit
+		want string
+	}{
+		{setEdit("65000", "1", ""), "the field type must be given"},
+		{setEdit("SMinSampleValue", "1", ""), "the field type must be given"},
+		{setEdit("StripOffsets", "8", ""), "rebuilt"},
+		{setEdit("JPEGQTables", "8", ""), "rebuilt"},
+		{setEdit("NoSuchTag", "8", ""), "no tag named"},
+		{setEdit("ImageDescription", "x", "NoSuchType"), "no field type named"},
+		{setEdit("ImageWidth", "x", ""), "invalid value"},
+		{edit{Tag: "DateTime", Delete: true}, "no field for tag 306"},
+		{edit{Tag: "DateTime"}, "need a value"},
+		{edit{IFD: "1", Tag: "DateTime", Delete: true}, "out of range"},
+	}
+	for _, tt := range tests {
+		name := writeTestFile(t)
+		before, _ := os.ReadFile(name)
+		err := run(name, []edit{tt.edit})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got error %v, want one about %q", tt.edit, err, tt.want)
+		}
+		if after, _ := os.ReadFile(name); !bytes.Equal(after, before) {
+			t.Errorf("%+v: the file changed", tt.edit)
+		}
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	name := writeTestFile(t)
+	before, _ := os.ReadFile(name)
+	dst := filepath.Join(filepath.Dir(name), "out.tif")
+	defer func(o string) { *output = o }(*output)
+	*output = dst
+	if err := run(name, []edit{setEdit("ImageDescription", "copy", "")}); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(name); !bytes.Equal(after, before) {
+		t.Error("the input changed")
+	}
+	tf, _ := parseFile(t, dst)
+	if !tf.IFDs()[0].HasField(270) {
+		t.Error("the output has no ImageDescription")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command tiffvalidate checks TIFF files against a conformance profile.
+
+Usage:
+
+	tiffvalidate [flags] file...
+
+Each image IFD is classified by the profile and every missing field and
+illegal value is printed.  The exit status is 1 if any file has errors or could
+not be read.  Warnings do not change the exit status.
+
+The flags are:
+
+	-profile name
+		The profile to check against (default "baseline").
+	-list
+		List the available profiles and exit.
+	-json
+		Print the results as JSON instead of text.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/tiff"
+	_ "github.com/google/tiff/bigtiff"
+	_ "github.com/google/tiff/exif"
+	"github.com/google/tiff/validate"
+)
+
+var (
+	profileName = flag.String("profile", "baseline", "the profile to check against")
+	list        = flag.Bool("list", false, "list the available profiles and exit")
+	asJSON      = flag.Bool("json", false, "print the results as JSON")
+)
+
+type problemDump struct {
+	Severity string `json:"severity"`
+	Tag      uint16 `json:"tag,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Message  string `json:"message"`
+}
+
+type resultDump struct {
+	Path     string        `json:"path"`
+	Class    string        `json:"class"`
+	Problems []problemDump `json:"problems"`
+}
+
+type fileDump struct {
+	File    string       `json:"file"`
+	Error   string       `json:"error,omitempty"`
+	Results []resultDump `json:"results,omitempty"`
+}
+
+func check(name string, p validate.Profile) ([]validate.Result, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	t, err := tiff.Parse(fh, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return validate.Validate(t, p)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tiffvalidate: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tiffvalidate [flags] file...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *list {
+		for _, name := range validate.ListProfiles() {
+			fmt.Println(name)
+		}
+		return
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p := validate.GetProfile(*profileName)
+	if p == nil {
+		log.Fatalf("unknown profile %q", *profileName)
+	}
+
+	var (
+		dumps  []fileDump
+		failed bool
+	)
+	for _, name := range flag.Args() {
+		results, err := check(name, p)
+		d := fileDump{File: name}
+		if err != nil {
+			failed = true
+			d.Error = err.Error()
+			if !*asJSON {
+				log.Printf("%s: %v", name, err)
+			}
+		}
+		for _, r := range results {
+			if r.HasErrors() {
+				failed = true
+			}
+			rd := resultDump{Path: r.Path, Class: r.Class, Problems: []problemDump{}}
+			for _, pr := range r.Problems {
+				rd.Problems = append(rd.Problems, problemDump{pr.Severity.String(), pr.Tag, pr.Name, pr.Message})
+			}
+			d.Results = append(d.Results, rd)
+			if *asJSON {
+				continue
+			}
+			class := r.Class
+			if class == "" {
+				class = "unclassified"
+			}
+			fmt.Printf("%s: IFD %s: %s: %d problems\n", name, r.Path, class, len(r.Problems))
+			for _, pr := range r.Problems {
+				fmt.Printf("\t%s\n", pr)
+			}
+		}
+		dumps = append(dumps, d)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(dumps); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
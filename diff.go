@@ -0,0 +1,428 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DiffKind identifies what a Difference is about.
+type DiffKind int
+
+const (
+	DiffHeader       DiffKind = iota // A value in the header differs.
+	DiffIFDAdded                     // An IFD exists only in b.
+	DiffIFDRemoved                   // An IFD exists only in a.
+	DiffFieldAdded                   // A field exists only in b.
+	DiffFieldRemoved                 // A field exists only in a.
+	DiffFieldChanged                 // A field's type, count or value differs.
+	DiffData                         // The image data or the data pointed to by an offset tag differs.
+)
+
+var diffKindNames = [...]string{
+	DiffHeader:       "header",
+	DiffIFDAdded:     "ifd added",
+	DiffIFDRemoved:   "ifd removed",
+	DiffFieldAdded:   "field added",
+	DiffFieldRemoved: "field removed",
+	DiffFieldChanged: "field changed",
+	DiffData:         "data changed",
+}
+
+func (k DiffKind) String() string {
+	if k >= 0 && int(k) < len(diffKindNames) {
+		return diffKindNames[k]
+	}
+	return "DiffKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Difference is a single difference found by Diff.  Path is the IFD path (see
+// IFD Paths) and is empty for differences in the header.  Tag and Name are only
+// set for differences of fields and data.  A and B describe the value on each
+// side and are empty for the side that does not exist.  For DiffData, only A
+// is set and describes what differs.
+type Difference struct {
+	Kind DiffKind
+	Path string
+	Tag  uint16
+	Name string
+	A, B string
+}
+
+func (d Difference) String() string {
+	if d.Kind == DiffHeader {
+		return fmt.Sprintf("header: %s: %s -> %s", d.Name, d.A, d.B)
+	}
+	var where string
+	switch {
+	case d.Name != "":
+		where = fmt.Sprintf("IFD %s: %s (%d)", d.Path, d.Name, d.Tag)
+	case d.Kind >= DiffFieldAdded:
+		where = fmt.Sprintf("IFD %s: tag %d", d.Path, d.Tag)
+	default:
+		where = "IFD " + d.Path
+	}
+	switch d.Kind {
+	case DiffIFDAdded, DiffFieldAdded:
+		return fmt.Sprintf("%s: %s: %s", where, d.Kind, d.B)
+	case DiffIFDRemoved, DiffFieldRemoved, DiffData:
+		return fmt.Sprintf("%s: %s: %s", where, d.Kind, d.A)
+	}
+	return fmt.Sprintf("%s: %s: %s -> %s", where, d.Kind, d.A, d.B)
+}
+
+// DataComparison is the result of comparing the data of an IFD found in both
+// TIFFs.  Tag and Name are those of the offset tag the data was found with in a.
+// Decoded is true when the decoded image data of the IFDs was compared (see
+// RegisterPayloadDecoder) and false when the blocks of data of the offset tag
+// were compared byte for byte as they are stored.  Why describes the
+// difference when Same is false.
+type DataComparison struct {
+	Path    string
+	Tag     uint16
+	Name    string
+	Decoded bool
+	Same    bool
+	Why     string
+}
+
+func (c DataComparison) String() string {
+	what := "stored data"
+	if c.Decoded {
+		what = "decoded image data"
+	}
+	where := fmt.Sprintf("IFD %s: %s (%d)", c.Path, c.Name, c.Tag)
+	if c.Same {
+		return fmt.Sprintf("%s: %s identical", where, what)
+	}
+	return fmt.Sprintf("%s: %s differs: %s", where, what, c.Why)
+}
+
+// A PayloadDecoder returns the image data of ifd decoded into a layout that
+// does not depend on how it is stored, so that two IFDs hold the same image
+// data if and only if their payloads are equal.  The br reads the TIFF ifd is
+// part of.
+type PayloadDecoder func(ifd IFD, br BReader) ([]byte, error)
+
+var payloadDecoder = struct {
+	mu  sync.RWMutex
+	dec PayloadDecoder
+}{}
+
+// RegisterPayloadDecoder sets the PayloadDecoder Diff uses to compare image
+// data.  The image package (github.com/google/tiff/image) registers one that
+// decompresses strips and tiles with its registered compressions.
+func RegisterPayloadDecoder(dec PayloadDecoder) {
+	payloadDecoder.mu.Lock()
+	payloadDecoder.dec = dec
+	payloadDecoder.mu.Unlock()
+}
+
+func getPayloadDecoder() PayloadDecoder {
+	payloadDecoder.mu.RLock()
+	defer payloadDecoder.mu.RUnlock()
+	return payloadDecoder.dec
+}
+
+// imageDataTag returns the offset tag holding the image data of ifd, which is
+// TileOffsets or StripOffsets, or 0 if ifd has no image data.
+func imageDataTag(ifd IFD) uint16 {
+	for _, tagID := range []uint16{324, 273} {
+		if ifd.HasField(tagID) {
+			return tagID
+		}
+	}
+	return 0
+}
+
+// equalPayloads compares the decoded image data of ifdA and ifdB.  The bool
+// ok is false when there is no registered PayloadDecoder or either image
+// cannot be decoded, in which case the stored data is compared instead.
+func equalPayloads(ra, rb BReader, ifdA, ifdB IFD) (same, ok bool, why string) {
+	dec := getPayloadDecoder()
+	if dec == nil || imageDataTag(ifdA) == 0 || imageDataTag(ifdB) == 0 {
+		return false, false, ""
+	}
+	pa, err := dec(ifdA, ra)
+	if err != nil {
+		return false, false, ""
+	}
+	pb, err := dec(ifdB, rb)
+	if err != nil {
+		return false, false, ""
+	}
+	if len(pa) != len(pb) {
+		return false, true, fmt.Sprintf("%d bytes vs %d bytes", len(pa), len(pb))
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return false, true, fmt.Sprintf("first difference at byte %d", i)
+		}
+	}
+	return true, true, ""
+}
+
+type diffIFD struct {
+	ifd IFD
+	tsp TagSpace
+}
+
+func collectIFDs(t TIFF) (map[string]diffIFD, []string, error) {
+	ifds := make(map[string]diffIFD)
+	var paths []string
+	err := WalkIFDs(t, nil, func(path string, ifd IFD, tsp TagSpace) error {
+		ifds[path] = diffIFD{ifd, tsp}
+		paths = append(paths, path)
+		return nil
+	})
+	return ifds, paths, err
+}
+
+// fieldValueStrings returns the representation of each value of f.  ASCII
+// fields are returned as a single quoted string.
+func fieldValueStrings(f Field) []string {
+	ft := f.Type()
+	buf := f.Value().Bytes()
+	if ft.ReflectType().Kind() == reflect.String {
+		if f.Count() < uint64(len(buf)) {
+			buf = buf[:f.Count()]
+		}
+		return []string{strconv.Quote(strings.TrimRight(string(buf), "\x00"))}
+	}
+	size := ft.Size()
+	if size == 0 || ft.Repr() == nil {
+		return []string{fmt.Sprintf("%v", buf)}
+	}
+	vals := make([]string, 0, f.Count())
+	for i := uint64(0); i < f.Count() && uint64(len(buf)) >= size; i++ {
+		vals = append(vals, ft.Repr()(buf[:size], f.Value().Order()))
+		buf = buf[size:]
+	}
+	return vals
+}
+
+func describeField(f Field, withValue bool) string {
+	s := fmt.Sprintf("%s[%d]", f.Type().Name(), f.Count())
+	if !withValue {
+		return s
+	}
+	const maxItems = 10
+	vals := fieldValueStrings(f)
+	more := ""
+	if len(vals) > maxItems {
+		vals, more = vals[:maxItems], " ..."
+	}
+	return s + " " + strings.Join(vals, " ") + more
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isOffsetValueTag reports whether the values of tagID are offsets which are
+// expected to move when a file is rewritten.
+func isOffsetValueTag(tagID uint16) bool {
+	if _, ok := GetSubIFDTagSpace(tagID); ok {
+		return true
+	}
+	_, ok := GetByteCountTag(tagID)
+	return ok
+}
+
+// equalBlocks compares the blocks of data that the offset tag offTagID points
+// to in ifdA and ifdB.
+func equalBlocks(ra, rb io.ReaderAt, ifdA, ifdB IFD, offTagID uint16) (bool, string, error) {
+	bcTagID, _ := GetByteCountTag(offTagID)
+	if !ifdA.HasField(bcTagID) || !ifdB.HasField(bcTagID) {
+		return ifdA.HasField(bcTagID) == ifdB.HasField(bcTagID), "no byte counts", nil
+	}
+	var blocks [2][2][]uint64
+	for i, ifd := range []IFD{ifdA, ifdB} {
+		var err error
+		if blocks[i][0], err = fieldUints(ifd.GetField(offTagID)); err != nil {
+			return false, "", err
+		}
+		if blocks[i][1], err = fieldUints(ifd.GetField(bcTagID)); err != nil {
+			return false, "", err
+		}
+		if len(blocks[i][0]) != len(blocks[i][1]) {
+			return false, "", fmt.Errorf("tiff: %d offsets for tag %d, but %d byte counts for tag %d", len(blocks[i][0]), offTagID, len(blocks[i][1]), bcTagID)
+		}
+	}
+	offsA, countsA := blocks[0][0], blocks[0][1]
+	offsB, countsB := blocks[1][0], blocks[1][1]
+	if len(offsA) != len(offsB) {
+		return false, fmt.Sprintf("%d blocks vs %d blocks", len(offsA), len(offsB)), nil
+	}
+	const chunk = 32 * 1024
+	bufA, bufB := make([]byte, chunk), make([]byte, chunk)
+	for i := range offsA {
+		if countsA[i] != countsB[i] {
+			return false, fmt.Sprintf("block %d has %d bytes vs %d bytes", i, countsA[i], countsB[i]), nil
+		}
+		sa := io.NewSectionReader(ra, int64(offsA[i]), int64(countsA[i]))
+		sb := io.NewSectionReader(rb, int64(offsB[i]), int64(countsB[i]))
+		for pos := uint64(0); pos < countsA[i]; pos += chunk {
+			n := countsA[i] - pos
+			if n > chunk {
+				n = chunk
+			}
+			if _, err := io.ReadFull(sa, bufA[:n]); err != nil {
+				return false, "", fmt.Errorf("tiff: unable to read block %d for tag %d: %v", i, offTagID, err)
+			}
+			if _, err := io.ReadFull(sb, bufB[:n]); err != nil {
+				return false, "", fmt.Errorf("tiff: unable to read block %d for tag %d: %v", i, offTagID, err)
+			}
+			if !bytes.Equal(bufA[:n], bufB[:n]) {
+				return false, fmt.Sprintf("block %d differs", i), nil
+			}
+		}
+	}
+	return true, "", nil
+}
+
+func sortedFields(ifd IFD) []Field {
+	fields := append([]Field(nil), ifd.Fields()...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Tag().ID() < fields[j].Tag().ID() })
+	return fields
+}
+
+func diffFields(path string, a, b diffIFD, ra, rb BReader) ([]Difference, []DataComparison, error) {
+	var (
+		diffs []Difference
+		comps []DataComparison
+	)
+	dataTag := imageDataTag(a.ifd)
+	sameData, decoded, why := equalPayloads(ra, rb, a.ifd, b.ifd)
+	if decoded {
+		name := a.ifd.GetField(dataTag).Tag().Name()
+		comps = append(comps, DataComparison{Path: path, Tag: dataTag, Name: name, Decoded: true, Same: sameData, Why: why})
+	}
+	fa, fb := sortedFields(a.ifd), sortedFields(b.ifd)
+	i, j := 0, 0
+	for i < len(fa) || j < len(fb) {
+		switch {
+		case j >= len(fb) || (i < len(fa) && fa[i].Tag().ID() < fb[j].Tag().ID()):
+			f := fa[i]
+			diffs = append(diffs, Difference{Kind: DiffFieldRemoved, Path: path, Tag: f.Tag().ID(), Name: f.Tag().Name(), A: describeField(f, true)})
+			i++
+			continue
+		case i >= len(fa) || fb[j].Tag().ID() < fa[i].Tag().ID():
+			f := fb[j]
+			diffs = append(diffs, Difference{Kind: DiffFieldAdded, Path: path, Tag: f.Tag().ID(), Name: f.Tag().Name(), B: describeField(f, true)})
+			j++
+			continue
+		}
+		f, g := fa[i], fb[j]
+		i, j = i+1, j+1
+		tagID := f.Tag().ID()
+		name := f.Tag().Name()
+		if name == "" {
+			name = g.Tag().Name()
+		}
+		// Writers are free to pick the integer type of offsets, so only
+		// their count matters.
+		offsets := isOffsetValueTag(tagID)
+		if (!offsets && f.Type().ID() != g.Type().ID()) || f.Count() != g.Count() ||
+			(!offsets && !equalStrings(fieldValueStrings(f), fieldValueStrings(g))) {
+			diffs = append(diffs, Difference{Kind: DiffFieldChanged, Path: path, Tag: tagID, Name: name,
+				A: describeField(f, !offsets), B: describeField(g, !offsets)})
+		}
+		if _, ok := GetByteCountTag(tagID); !ok || decoded && (tagID == 273 || tagID == 324) {
+			continue
+		}
+		same, why, err := equalBlocks(ra, rb, a.ifd, b.ifd, tagID)
+		if err != nil {
+			return nil, nil, err
+		}
+		comps = append(comps, DataComparison{Path: path, Tag: tagID, Name: name, Same: same, Why: why})
+		if !same {
+			diffs = append(diffs, Difference{Kind: DiffData, Path: path, Tag: tagID, Name: name, A: "stored data differs: " + why})
+		}
+	}
+	if decoded && !sameData {
+		c := comps[0]
+		diffs = append(diffs, Difference{Kind: DiffData, Path: path, Tag: c.Tag, Name: c.Name, A: "decoded image data differs: " + c.Why})
+	}
+	return diffs, comps, nil
+}
+
+// Diff compares a and b structurally.  The header (except the offset of the
+// first IFD), the tree of IFDs (matched by IFD path) and the fields of each IFD
+// are compared.  Fields are compared by field type, count and the
+// representation of their values, so a change of byte order alone is not a
+// difference.  The values of offset tags and sub-IFD tags are not compared
+// since they move whenever a file is rewritten.  Instead, the data they point
+// to is compared.  The image data (StripOffsets or TileOffsets) is decoded with
+// the registered PayloadDecoder, so that recompressed, re-stripped or re-tiled
+// images with the same pixels have the same data.  When there is no
+// PayloadDecoder or an image cannot be decoded, and for other offset tags,
+// the blocks of data are compared byte for byte as they are stored.  Both
+// TIFFs are walked with the DefaultTagSpace and the registered sub-IFD tags.
+func Diff(a, b TIFF) ([]Difference, error) {
+	diffs, _, err := DiffWithData(a, b)
+	return diffs, err
+}
+
+// DiffWithData is like Diff, but also returns the result of every comparison
+// of data, including those of identical data.
+func DiffWithData(a, b TIFF) ([]Difference, []DataComparison, error) {
+	var (
+		diffs []Difference
+		comps []DataComparison
+	)
+	header := func(what string, va, vb interface{}) {
+		if va != vb {
+			diffs = append(diffs, Difference{Kind: DiffHeader, Name: what, A: fmt.Sprint(va), B: fmt.Sprint(vb)})
+		}
+	}
+	header("Order", a.Order(), b.Order())
+	header("Version", a.Version(), b.Version())
+	header("OffsetSize", a.OffsetSize(), b.OffsetSize())
+
+	ifdsA, pathsA, err := collectIFDs(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	ifdsB, pathsB, err := collectIFDs(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range pathsA {
+		ia := ifdsA[path]
+		ib, ok := ifdsB[path]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: DiffIFDRemoved, Path: path, A: fmt.Sprintf("%d fields", len(ia.ifd.Fields()))})
+			continue
+		}
+		fd, fc, err := diffFields(path, ia, ib, a.R(), b.R())
+		if err != nil {
+			return nil, nil, err
+		}
+		diffs = append(diffs, fd...)
+		comps = append(comps, fc...)
+	}
+	for _, path := range pathsB {
+		if _, ok := ifdsA[path]; !ok {
+			diffs = append(diffs, Difference{Kind: DiffIFDAdded, Path: path, B: fmt.Sprintf("%d fields", len(ifdsB[path].ifd.Fields()))})
+		}
+	}
+	return diffs, comps, nil
+}
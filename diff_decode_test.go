@@ -0,0 +1,73 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff_test
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/google/tiff"
+	timage "github.com/google/tiff/image"
+)
+
+func parse(t *testing.T, b []byte) tiff.TIFF {
+	t.Helper()
+	tf, err := tiff.Parse(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return tf
+}
+
+func TestDiffDecodedData(t *testing.T) {
+	m := gradient(37, 29)
+	a := parse(t, encode(t, m, &timage.Options{RowsPerStrip: 5}))
+	// The same pixels, recompressed and re-striped.  Only the fields that
+	// describe the layout differ.
+	b := parse(t, encode(t, m, &timage.Options{Compression: 5, Predictor: 2, RowsPerStrip: 8}))
+	diffs, comps, err := tiff.DiffWithData(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diffs {
+		switch d.Tag {
+		case 259, 273, 278, 279, 317:
+		default:
+			t.Errorf("unexpected difference %v", d)
+		}
+	}
+	if len(comps) != 1 || !comps[0].Decoded || !comps[0].Same || comps[0].Tag != 273 {
+		t.Errorf("got comparisons %v, want identical decoded strips", comps)
+	}
+
+	// Tiles against strips.
+	c := parse(t, encode(t, m, &timage.Options{Compression: 8, TileWidth: 16, TileLength: 16}))
+	_, comps, err = tiff.DiffWithData(a, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comps) != 1 || !comps[0].Decoded || !comps[0].Same {
+		t.Errorf("got comparisons %v, want identical decoded data", comps)
+	}
+
+	// A changed pixel is reported however the data is stored.
+	m.SetGray16(36, 28, color.Gray16{1})
+	d := parse(t, encode(t, m, &timage.Options{Compression: 5, Predictor: 2, RowsPerStrip: 8}))
+	diffs, comps, err = tiff.DiffWithData(a, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comps) != 1 || !comps[0].Decoded || comps[0].Same {
+		t.Errorf("got comparisons %v, want different decoded strips", comps)
+	}
+	var found bool
+	for _, d := range diffs {
+		found = found || d.Kind == tiff.DiffData && d.Tag == 273
+	}
+	if !found {
+		t.Errorf("got differences %v, want one of the data", diffs)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// diffTestFile lays out an IFD with two strips at stripsAt and stripsAt+100
+// and the StripOffsets array at offsetsAt, so that two files can hold the
+// same fields and data in different places.
+func diffTestFile(t *testing.T, bo binary.ByteOrder, stripsAt, offsetsAt uint32, width uint32, strips ...string) TIFF {
+	t.Helper()
+	rt := newRawTIFF(bo, 8)
+	rt.ifd(8, 0,
+		rawEntry{tag: 256, typ: 3, count: 1, value: width},
+		rawEntry{tag: 273, typ: 4, count: 2, value: offsetsAt},
+		rawEntry{tag: 279, typ: 3, count: 2, value: uint32(len(strips[0]))<<16 | uint32(len(strips[1]))},
+	)
+	rt.put(stripsAt, []byte(strips[0]))
+	rt.put(stripsAt+100, []byte(strips[1]))
+	rt.longs(offsetsAt, stripsAt, stripsAt+100)
+	return rt.parse(t)
+}
+
+// withoutPayloadDecoder runs f with no registered PayloadDecoder, so that
+// image data is compared as it is stored.
+func withoutPayloadDecoder(f func()) {
+	dec := getPayloadDecoder()
+	RegisterPayloadDecoder(nil)
+	defer RegisterPayloadDecoder(dec)
+	f()
+}
+
+func TestDiff(t *testing.T) {
+	be, le := binary.BigEndian, binary.LittleEndian
+	base := func(t *testing.T) TIFF { return diffTestFile(t, be, 100, 400, 4, "first", "second") }
+	tests := []struct {
+		name  string
+		b     func(t *testing.T) TIFF
+		kinds []DiffKind
+		tags  []uint16
+	}{
+		{"identical", base, nil, nil},
+		{"moved strips", func(t *testing.T) TIFF { return diffTestFile(t, be, 600, 64, 4, "first", "second") }, nil, nil},
+		{"byte order", func(t *testing.T) TIFF { return diffTestFile(t, le, 100, 400, 4, "first", "second") }, []DiffKind{DiffHeader}, []uint16{0}},
+		{"changed field", func(t *testing.T) TIFF { return diffTestFile(t, be, 100, 400, 5, "first", "second") }, []DiffKind{DiffFieldChanged}, []uint16{256}},
+		{"changed strip", func(t *testing.T) TIFF { return diffTestFile(t, be, 100, 400, 4, "first", "SECOND") }, []DiffKind{DiffData}, []uint16{273}},
+		{"resized strip", func(t *testing.T) TIFF { return diffTestFile(t, be, 100, 400, 4, "first", "second!") }, []DiffKind{DiffData, DiffFieldChanged}, []uint16{273, 279}},
+	}
+	withoutPayloadDecoder(func() {
+		for _, tt := range tests {
+			diffs, comps, err := DiffWithData(base(t), tt.b(t))
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if len(diffs) != len(tt.kinds) {
+				t.Errorf("%s: got differences %v, want kinds %v", tt.name, diffs, tt.kinds)
+				continue
+			}
+			for i, d := range diffs {
+				if d.Kind != tt.kinds[i] || d.Tag != tt.tags[i] {
+					t.Errorf("%s: difference %d is %v, want %v for tag %d", tt.name, i, d, tt.kinds[i], tt.tags[i])
+				}
+			}
+			if len(comps) != 1 || comps[0].Tag != 273 || comps[0].Decoded {
+				t.Errorf("%s: got comparisons %v, want one of the stored strips", tt.name, comps)
+				continue
+			}
+			if same := len(tt.kinds) == 0 || tt.kinds[0] != DiffData; comps[0].Same != same {
+				t.Errorf("%s: %v, want Same %v", tt.name, comps[0], same)
+			}
+		}
+	})
+}
+
+func TestDiffIFDs(t *testing.T) {
+	one := func(rt *rawTIFF, off, next uint32) {
+		rt.ifd(off, next, rawEntry{tag: 256, typ: 3, count: 1, value: 4}, rawEntry{tag: 305, typ: 2, count: 2, value: 'x' << 24})
+	}
+	rt := newRawTIFF(binary.BigEndian, 8)
+	one(rt, 8, 0)
+	a := rt.parse(t)
+	rt = newRawTIFF(binary.BigEndian, 8)
+	one(rt, 8, 100)
+	rt.ifd(100, 0, rawEntry{tag: 256, typ: 3, count: 1, value: 4}, rawEntry{tag: 257, typ: 3, count: 1, value: 2})
+	b := rt.parse(t)
+
+	diffs, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != DiffIFDAdded || diffs[0].Path != "1" {
+		t.Errorf("got differences %v, want IFD 1 added", diffs)
+	}
+	diffs, err = Diff(b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != DiffIFDRemoved || diffs[0].Path != "1" {
+		t.Errorf("got differences %v, want IFD 1 removed", diffs)
+	}
+
+	// An IFD with the fields of IFD 1 of b in place of IFD 0 of a.
+	rt = newRawTIFF(binary.BigEndian, 100)
+	rt.ifd(100, 0, rawEntry{tag: 256, typ: 3, count: 1, value: 4}, rawEntry{tag: 257, typ: 3, count: 1, value: 2})
+	diffs, err = Diff(a, rt.parse(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"IFD 0: ImageLength (257): field added: Short[1] 2",
+		"IFD 0: Software (305): field removed: ASCII[2] \"x\"",
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got differences %v, want %q", diffs, want)
+	}
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("difference %d is %q, want %q", i, d, want[i])
+		}
+	}
+}
@@ -190,4 +190,5 @@ func init() {
 	ExifTagSpace.RegisterTagSet(exifTags)
 
 	tiff.RegisterTagSpace(ExifTagSpace)
+	tiff.RegisterSubIFDTag(ExifIFDTagID, ExifTagSpace)
 }
@@ -59,6 +59,7 @@ func init() {
 
 	GPSTagSpace.RegisterTagSet(gpsTags)
 	tiff.RegisterTagSpace(GPSTagSpace)
+	tiff.RegisterSubIFDTag(GPSIFDTagID, GPSTagSpace)
 }
 
 type gpsIFD struct {
@@ -28,4 +28,5 @@ func init() {
 
 	IOPTagSpace.RegisterTagSet(iopTags)
 	tiff.RegisterTagSpace(IOPTagSpace)
+	tiff.RegisterSubIFDTag(InteroperabilityIFDTagID, IOPTagSpace)
 }
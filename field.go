@@ -217,6 +217,29 @@ func (f *field) MarshalJSON() ([]byte, error) {
 	return json.Marshal(tmp)
 }
 
+// fieldUints returns the values of f as uint64s.  It is meant for fields that
+// hold offsets, counts, or other unsigned integers (i.e. Byte, Short, Long,
+// IFD or any 64 bit equivalents registered by other packages).
+func fieldUints(f Field) ([]uint64, error) {
+	ft := f.Type()
+	size := ft.Size()
+	buf := f.Value().Bytes()
+	if uint64(len(buf)) < size*f.Count() {
+		return nil, fmt.Errorf("tiff: field %d has %d bytes for %d values of size %d", f.Tag().ID(), len(buf), f.Count(), size)
+	}
+	switch ft.ReflectType().Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, fmt.Errorf("tiff: field %d has non integer type %q", f.Tag().ID(), ft.Name())
+	}
+	out := make([]uint64, f.Count())
+	for i := range out {
+		out[i] = ft.Valuer()(buf[:size], f.Value().Order()).Uint()
+		buf = buf[size:]
+	}
+	return out, nil
+}
+
 func ParseField(br BReader, tsp TagSpace, ftsp FieldTypeSpace) (out Field, err error) {
 	if ftsp == nil {
 		ftsp = DefaultFieldTypeSpace
@@ -0,0 +1,131 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type ErrInvalidFieldValue struct {
+	Type    FieldType
+	Text    string
+	Problem string
+}
+
+func (e ErrInvalidFieldValue) Error() string {
+	return fmt.Sprintf("tiff: invalid value %q for field type %q (id: %d): %s", e.Text, e.Type.Name(), e.Type.ID(), e.Problem)
+}
+
+// EncodeFieldValue converts the text representation of one or more values of
+// the field type ft into the raw bytes of a field value in byte order bo.  It
+// returns those bytes along with the count of values.  The text follows the
+// same rules as the "def" key of a tiff field struct tag:
+//  1. ASCII is used as is.  A terminating NUL is added.
+//  2. Byte, Undefined, and Integers MUST be in base10 (decimal).
+//  3. Rationals MUST have the form x/y where x and y are both base10.
+//  4. Floats & Doubles have the form "-1234.5678".
+//  5. For counts > 1 for all types other than ASCII, a ',' separates values.
+func EncodeFieldValue(ft FieldType, text string, bo binary.ByteOrder) ([]byte, uint32, error) {
+	if ft.ReflectType().Kind() == reflect.String {
+		b := append([]byte(text), 0)
+		return b, uint32(len(b)), nil
+	}
+	parts := strings.Split(text, ",")
+	out := make([]byte, 0, len(parts)*int(ft.Size()))
+	for _, p := range parts {
+		b, err := encodeOneFieldValue(ft, strings.TrimSpace(p), bo)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, b...)
+	}
+	return out, uint32(len(parts)), nil
+}
+
+func encodeOneFieldValue(ft FieldType, text string, bo binary.ByteOrder) ([]byte, error) {
+	size := ft.Size()
+	b := make([]byte, size)
+	invalid := func(problem string) error {
+		return ErrInvalidFieldValue{ft, text, problem}
+	}
+	kind := ft.ReflectType().Kind()
+	switch kind {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if uint64(ft.ReflectType().Size()) != size {
+			return nil, invalid("unsupported field type")
+		}
+		u, err := strconv.ParseUint(text, 10, int(size*8))
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		putUint(b, u, bo)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if uint64(ft.ReflectType().Size()) != size {
+			return nil, invalid("unsupported field type")
+		}
+		i, err := strconv.ParseInt(text, 10, int(size*8))
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		putUint(b, uint64(i), bo)
+	case reflect.Float32:
+		f, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		bo.PutUint32(b, math.Float32bits(float32(f)))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		bo.PutUint64(b, math.Float64bits(f))
+	default:
+		if ft.ReflectType() != bigRatType || size != 8 {
+			return nil, invalid("unsupported field type")
+		}
+		pair := strings.SplitN(text, "/", 2)
+		if len(pair) != 2 {
+			return nil, invalid("rationals must have the form x/y")
+		}
+		var nums [2]uint64
+		for i, p := range pair {
+			if ft.Signed() {
+				n, err := strconv.ParseInt(strings.TrimSpace(p), 10, 32)
+				if err != nil {
+					return nil, invalid(err.Error())
+				}
+				nums[i] = uint64(uint32(int32(n)))
+			} else {
+				n, err := strconv.ParseUint(strings.TrimSpace(p), 10, 32)
+				if err != nil {
+					return nil, invalid(err.Error())
+				}
+				nums[i] = n
+			}
+		}
+		bo.PutUint32(b, uint32(nums[0]))
+		bo.PutUint32(b[4:], uint32(nums[1]))
+	}
+	return b, nil
+}
+
+func putUint(b []byte, u uint64, bo binary.ByteOrder) {
+	switch len(b) {
+	case 1:
+		b[0] = uint8(u)
+	case 2:
+		bo.PutUint16(b, uint16(u))
+	case 4:
+		bo.PutUint32(b, uint32(u))
+	case 8:
+		bo.PutUint64(b, u)
+	}
+}
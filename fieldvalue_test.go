@@ -0,0 +1,75 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeFieldValue(t *testing.T) {
+	tests := []struct {
+		ft     FieldType
+		text   string
+		count  uint32
+		be, le []byte
+	}{
+		{FTAscii, "A new description", 18, []byte("A new description\x00"), []byte("A new description\x00")},
+		{FTAscii, "", 1, []byte{0}, []byte{0}},
+		{FTByte, "1,2,255", 3, []byte{1, 2, 255}, []byte{1, 2, 255}},
+		{FTUndefined, "7", 1, []byte{7}, []byte{7}},
+		{FTShort, "1, 2", 2, []byte{0, 1, 0, 2}, []byte{1, 0, 2, 0}},
+		{FTLong, "65536", 1, []byte{0, 1, 0, 0}, []byte{0, 0, 1, 0}},
+		{FTSShort, "-2", 1, []byte{0xff, 0xfe}, []byte{0xfe, 0xff}},
+		{FTSLong, "-1", 1, []byte{0xff, 0xff, 0xff, 0xff}, []byte{0xff, 0xff, 0xff, 0xff}},
+		{FTRational, "300/1", 1, []byte{0, 0, 1, 44, 0, 0, 0, 1}, []byte{44, 1, 0, 0, 1, 0, 0, 0}},
+		{FTSRational, "-1/2", 1, []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 2}, []byte{0xff, 0xff, 0xff, 0xff, 2, 0, 0, 0}},
+		{FTFloat, "1.5", 1, []byte{0x3f, 0xc0, 0, 0}, []byte{0, 0, 0xc0, 0x3f}},
+		{FTDouble, "-2", 1, []byte{0xc0, 0, 0, 0, 0, 0, 0, 0}, []byte{0, 0, 0, 0, 0, 0, 0, 0xc0}},
+	}
+	for _, tt := range tests {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			want := tt.be
+			if bo == binary.LittleEndian {
+				want = tt.le
+			}
+			got, count, err := EncodeFieldValue(tt.ft, tt.text, bo)
+			if err != nil {
+				t.Errorf("%s %q %v: %v", tt.ft.Name(), tt.text, bo, err)
+				continue
+			}
+			if count != tt.count || !bytes.Equal(got, want) {
+				t.Errorf("%s %q %v = %v (count %d), want %v (count %d)", tt.ft.Name(), tt.text, bo, got, count, want, tt.count)
+			}
+		}
+	}
+}
+
+func TestEncodeFieldValueErrors(t *testing.T) {
+	tests := []struct {
+		ft   FieldType
+		text string
+	}{
+		{FTByte, "256"},
+		{FTShort, "-1"},
+		{FTShort, "65536"},
+		{FTShort, "1,,2"},
+		{FTLong, "x"},
+		{FTSShort, "32768"},
+		{FTRational, "3"},
+		{FTRational, "-1/2"},
+		{FTRational, "1/4294967296"},
+		{FTFloat, "one"},
+		{FTUnicode, "1"},
+	}
+	for _, tt := range tests {
+		if got, _, err := EncodeFieldValue(tt.ft, tt.text, binary.BigEndian); err == nil {
+			t.Errorf("%s %q = %v, want an error", tt.ft.Name(), tt.text, got)
+		} else if _, ok := err.(ErrInvalidFieldValue); !ok {
+			t.Errorf("%s %q: error %T is not an ErrInvalidFieldValue", tt.ft.Name(), tt.text, err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sync"
 	"text/tabwriter"
 )
 
@@ -101,3 +102,28 @@ func ParseIFD(br BReader, offset uint64, tsp TagSpace, ftsp FieldTypeSpace) (out
 	}
 	return ifd, nil
 }
+
+var ifdParsers = struct {
+	mu      sync.RWMutex
+	parsers map[uint16]IFDParser
+}{
+	parsers: make(map[uint16]IFDParser, 1),
+}
+
+// RegisterIFDParser registers the IFDParser used for IFDs found in files of
+// version v.  This is used when locating IFDs outside of the main IFD chain
+// (i.e. SubIFDs or the Exif IFD) where the layout of an IFD depends on the
+// version of the file it comes from.
+func RegisterIFDParser(v uint16, ip IFDParser) {
+	ifdParsers.mu.Lock()
+	defer ifdParsers.mu.Unlock()
+	ifdParsers.parsers[v] = ip
+}
+
+// GetIFDParser returns the IFDParser registered for version v or nil if there
+// is none.
+func GetIFDParser(v uint16) IFDParser {
+	ifdParsers.mu.RLock()
+	defer ifdParsers.mu.RUnlock()
+	return ifdParsers.parsers[v]
+}
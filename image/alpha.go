@@ -0,0 +1,130 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+/* Extra Samples
+
+Tag 338 (ExtraSamples)
+	0 = Unspecified data
+	1 = Associated alpha data (with pre-multiplied color)
+	2 = Unassociated alpha data
+	Note: One value for each sample past the color samples of a pixel
+		(SamplesPerPixel minus the number of color samples).
+
+Notes
+	When the first extra sample of a gray or RGB image is alpha, the image is
+	decoded into an *image.RGBA, or an *image.RGBA64 for more than 8 bits
+	per sample, with associated alpha, and into an *image.NRGBA or
+	*image.NRGBA64 with unassociated alpha.  Gray images are decoded as RGB.
+	Other extra samples are left out of the image, but can be read as planes
+	(see BandDecoder).
+*/
+
+// Extras returns the ExtraSamples value of each extra sample.
+func (bld *bilevelDecoder) Extras() []uint16 {
+	return bld.ExtraSamples
+}
+
+// alpha returns the ExtraSamples value of the first extra sample of an image
+// with spp samples per pixel and colors color samples when it is alpha, and 0
+// otherwise.
+func (bld *bilevelDecoder) alpha(spp, colors int) uint16 {
+	if spp > colors && len(bld.ExtraSamples) > 0 {
+		if es := bld.ExtraSamples[0]; es == 1 || es == 2 {
+			return es
+		}
+	}
+	return 0
+}
+
+// alphaModel returns the color model of decodeAlpha.
+func alphaModel(alpha uint16, deep bool) color.Model {
+	switch {
+	case alpha == 1 && deep:
+		return color.RGBA64Model
+	case alpha == 1:
+		return color.RGBAModel
+	case deep:
+		return color.NRGBA64Model
+	}
+	return color.NRGBAModel
+}
+
+// decodeAlpha decodes the first n color samples (1 for gray and 3 for RGB) of
+// each pixel of the decoded rows data and the alpha sample after them.  With
+// associated alpha (1), the image is an *image.RGBA or *image.RGBA64, and
+// with unassociated alpha (2), an *image.NRGBA or *image.NRGBA64.  When
+// whiteIsZero is set, gray samples are inverted.
+func decodeAlpha(r *raster, data []byte, n int, whiteIsZero bool, alpha uint16) image.Image {
+	rect := image.Rect(0, 0, r.width, r.height)
+	deep := r.bitsPerSample > 8
+	var (
+		img    image.Image
+		pix    []byte
+		stride int
+	)
+	switch {
+	case alpha == 1 && deep:
+		m := image.NewRGBA64(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	case alpha == 1:
+		m := image.NewRGBA(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	case deep:
+		m := image.NewNRGBA64(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	default:
+		m := image.NewNRGBA(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	}
+	rb := r.rowBytes()
+	spp := r.samplesPerPixel
+	max := r.maxValue()
+	for y := 0; y < r.height; y++ {
+		row := data[y*rb : (y+1)*rb]
+		p := pix[y*stride:]
+		for x := 0; x < r.width; x++ {
+			a := r.sample(row, x*spp+n)
+			var c [4]uint32
+			for i := 0; i < 3; i++ {
+				ci := i
+				if ci >= n {
+					ci = n - 1
+				}
+				v := r.sample(row, x*spp+ci)
+				if whiteIsZero {
+					top := max
+					if alpha == 1 {
+						// Pre-multiplied white is the alpha.
+						top = a
+					}
+					if v > top {
+						v = top
+					}
+					v = top - v
+				}
+				if alpha == 1 && v > a {
+					v = a
+				}
+				c[i] = v * 0xffff / max
+			}
+			c[3] = a * 0xffff / max
+			for i, v := range c {
+				if deep {
+					p[8*x+2*i] = uint8(v >> 8)
+					p[8*x+2*i+1] = uint8(v)
+				} else {
+					p[4*x+i] = uint8(v >> 8)
+				}
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+func TestAlpha(t *testing.T) {
+	be, le := binary.BigEndian, binary.LittleEndian
+	tests := []struct {
+		name  string
+		ti    *testIFD
+		model color.Model
+		want  []color.Color
+	}{
+		// Color samples above a premultiplied alpha are clamped to it.
+		{"associated RGB", newTestIFD(be).image(2, 1, 2, 8, 8, 8, 8).short(338, 1).
+			strips(1, []byte{100, 50, 0, 128, 200, 0, 0, 100}),
+			color.RGBAModel, []color.Color{color.RGBA{100, 50, 0, 128}, color.RGBA{100, 0, 0, 100}}},
+		{"associated RGB of 16 bits", newTestIFD(le).image(1, 1, 2, 16, 16, 16, 16).short(338, 1).
+			strips(1, sampleBytes(le, 2, 0x1234, 0x8000, 0x9000, 0x8000)),
+			color.RGBA64Model, []color.Color{color.RGBA64{0x1234, 0x8000, 0x8000, 0x8000}}},
+		{"unassociated RGB", newTestIFD(le).image(2, 1, 2, 8, 8, 8, 8).short(338, 2).
+			strips(1, []byte{100, 50, 0, 128, 200, 0, 0, 100}),
+			color.NRGBAModel, []color.Color{color.NRGBA{100, 50, 0, 128}, color.NRGBA{200, 0, 0, 100}}},
+		{"unassociated RGB of 16 bits", newTestIFD(be).image(1, 1, 2, 16, 16, 16, 16).short(338, 2).
+			strips(1, sampleBytes(be, 2, 0x1234, 0xffff, 0x9000, 0x8000)),
+			color.NRGBA64Model, []color.Color{color.NRGBA64{0x1234, 0xffff, 0x9000, 0x8000}}},
+		{"associated gray", newTestIFD(be).image(2, 1, 1, 8, 8).short(338, 1).
+			strips(1, []byte{60, 128, 255, 255}),
+			color.RGBAModel, []color.Color{color.RGBA{60, 60, 60, 128}, color.RGBA{255, 255, 255, 255}}},
+		{"unassociated gray", newTestIFD(le).image(1, 1, 1, 8, 8).short(338, 2).
+			strips(1, []byte{60, 128}),
+			color.NRGBAModel, []color.Color{color.NRGBA{60, 60, 60, 128}}},
+		{"unassociated gray of 16 bits", newTestIFD(le).image(1, 1, 1, 16, 16).short(338, 2).
+			strips(1, sampleBytes(le, 2, 0x0102, 0x0304)),
+			color.NRGBA64Model, []color.Color{color.NRGBA64{0x0102, 0x0102, 0x0102, 0x0304}}},
+		// Premultiplied white is the alpha, so 0 is as white as the alpha.
+		{"associated WhiteIsZero", newTestIFD(be).image(2, 1, 0, 8, 8).short(338, 1).
+			strips(1, []byte{0, 128, 28, 128}),
+			color.RGBAModel, []color.Color{color.RGBA{128, 128, 128, 128}, color.RGBA{100, 100, 100, 128}}},
+		{"unassociated WhiteIsZero", newTestIFD(be).image(1, 1, 0, 8, 8).short(338, 2).
+			strips(1, []byte{55, 128}),
+			color.NRGBAModel, []color.Color{color.NRGBA{200, 200, 200, 128}}},
+		{"planar", newTestIFD(le).image(2, 1, 2, 8, 8, 8, 8).short(338, 2).short(284, 2).
+			strips(1, []byte{1, 2}, []byte{3, 4}, []byte{5, 6}, []byte{7, 8}),
+			color.NRGBAModel, []color.Color{color.NRGBA{1, 3, 5, 7}, color.NRGBA{2, 4, 6, 8}}},
+	}
+	for _, tt := range tests {
+		b := testTIFF(t, tt.ti)
+		cfg, err := DecodeConfig(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: config: %v", tt.name, err)
+			continue
+		}
+		if cfg.ColorModel != tt.model {
+			t.Errorf("%s: config has color model %v, want %v", tt.name, cfg.ColorModel, tt.model)
+		}
+		m, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if m.ColorModel() != tt.model {
+			t.Errorf("%s: decoded as %T", tt.name, m)
+			continue
+		}
+		for x, want := range tt.want {
+			if got := m.At(x, 0); got != want {
+				t.Errorf("%s: pixel %d is %v, want %v", tt.name, x, got, want)
+			}
+		}
+	}
+}
+
+func TestExtras(t *testing.T) {
+	be := binary.BigEndian
+	tests := []struct {
+		name   string
+		ti     *testIFD
+		extras []uint16
+		typ    string
+		pixel  color.Color
+		planes [][]byte
+	}{
+		{"RGB with unspecified samples", newTestIFD(be).image(2, 1, 2, 8, 8, 8, 8, 8).short(338, 0, 0).
+			strips(1, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}),
+			[]uint16{0, 0}, "*image.RGBA", color.RGBA{1, 2, 3, 0xff}, [][]byte{{4, 9}, {5, 10}}},
+		{"RGB with alpha and an unspecified sample", newTestIFD(be).image(2, 1, 2, 8, 8, 8, 8, 8).short(338, 2, 0).
+			strips(1, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}),
+			[]uint16{2, 0}, "*image.NRGBA", color.NRGBA{1, 2, 3, 4}, [][]byte{{4, 9}, {5, 10}}},
+		{"gray with an unspecified sample", newTestIFD(be).image(2, 1, 1, 8, 8).short(338, 0).
+			strips(1, []byte{1, 2, 3, 4}),
+			[]uint16{0}, "*image.Gray", color.Gray{1}, [][]byte{{2, 4}}},
+		{"planar", newTestIFD(be).image(2, 1, 2, 8, 8, 8, 8).short(338, 0).short(284, 2).
+			strips(1, []byte{1, 2}, []byte{3, 4}, []byte{5, 6}, []byte{7, 8}),
+			[]uint16{0}, "*image.RGBA", color.RGBA{1, 3, 5, 0xff}, [][]byte{{7, 8}}},
+		{"no extra samples", newTestIFD(be).image(1, 1, 2, 8, 8, 8).strips(1, []byte{1, 2, 3}),
+			nil, "*image.RGBA", color.RGBA{1, 2, 3, 0xff}, nil},
+	}
+	for _, tt := range tests {
+		tf, err := tiff.Parse(bytes.NewReader(testTIFF(t, tt.ti)), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := getDecoder(tf)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		bd, ok := dec.(BandDecoder)
+		if !ok {
+			t.Errorf("%s: %T is not a BandDecoder", tt.name, dec)
+			continue
+		}
+		extras := bd.Extras()
+		if fmt.Sprint(extras) != fmt.Sprint(tt.extras) {
+			t.Errorf("%s: Extras() = %v, want %v", tt.name, extras, tt.extras)
+		}
+		m, err := bd.Image()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if typ := fmt.Sprintf("%T", m); typ != tt.typ {
+			t.Errorf("%s: decoded as %s, want %s", tt.name, typ, tt.typ)
+		}
+		if got := m.At(0, 0); got != tt.pixel {
+			t.Errorf("%s: pixel 0 is %v, want %v", tt.name, got, tt.pixel)
+		}
+		// Extra sample i is plane Planes()-len(Extras())+i.
+		for i, want := range tt.planes {
+			p, err := bd.Plane(bd.Planes() - len(extras) + i)
+			if err != nil {
+				t.Errorf("%s: extra sample %d: %v", tt.name, i, err)
+				continue
+			}
+			if g, ok := p.(*image.Gray); !ok || !bytes.Equal(g.Pix, want) {
+				t.Errorf("%s: extra sample %d is %v, want %v", tt.name, i, p, want)
+			}
+		}
+	}
+}
@@ -48,7 +48,7 @@ type Baseline struct {
 	Artist                    *string  `tiff:"field,tag=315"`
 	HostComputer              *string  `tiff:"field,tag=316"`
 	ColorMap                  []uint16 `tiff:"field,tag=320"`
-	ExtraSamples              []byte   `tiff:"field,tag=338"`
+	ExtraSamples              []uint16 `tiff:"field,tag=338"`
 	Copyright                 *string  `tiff:"field,tag=33432"`
 }
 
@@ -96,7 +96,15 @@ func (bld *BaselineDecoder) Config() (cfg image.Config, err error) {
 	switch {
 	case len(bld.ColorMap) > 0:
 		// palette color
-		// TODO: Create a palette color ColorModel from the ColorMap field.
+		bps := 1
+		if len(bld.BitsPerSample) > 0 {
+			bps = int(bld.BitsPerSample[0])
+		}
+		var p color.Palette
+		if p, err = colorMapPalette(bld.ColorMap, bps); err != nil {
+			return
+		}
+		cfg.ColorModel = p
 	case bld.SamplesPerPixel != nil && *bld.SamplesPerPixel > 0:
 		switch *bld.SamplesPerPixel {
 		case 3:
@@ -125,35 +133,51 @@ func (bld *BaselineDecoder) Config() (cfg image.Config, err error) {
 
 type BaselineHandler struct{}
 
+// Decoder picks the Baseline class of ifd from its PhotometricInterpretation
+// (tag 262) and returns the decoder for that class.
 func (BaselineHandler) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
-	return nil, err
-	/*
-		switch {
-		case ifd.HasField(320): // ColorMap (PaletteColor)
-			return new(PaletteColor).Decoder(ifd, br)
-		case ifd.HasField(277): // SamplesPerPixel (RGB)
-			sppField := ifd.GetField(277)
-			spp := sppField.Value().Order().Uint16(sppField.Value().Bytes())
-			switch spp {
-			case 1:
-				if ifd.HasField(258) {
-					bpsField := ifd.GetField(258)
-					bps := bpsField.Value().Order().Uint16(bpsField.Value().Bytes())
-					if bps > 1 {
-						return new(Grayscale).Decoder(ifd, br)
-					}
-				}
-				return new(Bilevel).Decoder(ifd, br)
-			case 3:
-				return new(FullColorRGB).Decoder(ifd, br)
-			}
-			return nil, fmt.Errorf("tiff/image: unsupported SamplesPerPixel value: %d", spp)
-		case ifd.HasField(258): // BitsPerSample (GrayScale)
+	if !ifd.HasField(262) {
+		return nil, fmt.Errorf("tiff/image: missing value for PhotometricInterpretation")
+	}
+	var p struct {
+		PhotometricInterpretation uint16 `tiff:"field,tag=262"`
+	}
+	if err = tiff.UnmarshalIFD(ifd, &p); err != nil {
+		return nil, err
+	}
+	switch p.PhotometricInterpretation {
+	case 0, 1: // WhiteIsZero, BlackIsZero
+		if new(Grayscale).CanHandle(ifd) {
 			return new(Grayscale).Decoder(ifd, br)
 		}
-		// Assume bilevel at the very least
 		return new(Bilevel).Decoder(ifd, br)
-	*/
+	case 2: // RGB
+		if !new(FullColorRGB).CanHandle(ifd) {
+			return nil, fmt.Errorf("tiff/image: missing value for SamplesPerPixel")
+		}
+		return new(FullColorRGB).Decoder(ifd, br)
+	case 3: // Palette Color
+		if !new(PaletteColor).CanHandle(ifd) {
+			return nil, fmt.Errorf("tiff/image: missing value for ColorMap")
+		}
+		return new(PaletteColor).Decoder(ifd, br)
+	case 5: // Separated
+		if !new(Separated).CanHandle(ifd) {
+			return nil, fmt.Errorf("tiff/image: missing value for SamplesPerPixel")
+		}
+		return new(Separated).Decoder(ifd, br)
+	case 6: // YCbCr
+		if !new(YCbCr).CanHandle(ifd) {
+			return nil, fmt.Errorf("tiff/image: missing value for SamplesPerPixel")
+		}
+		return new(YCbCr).Decoder(ifd, br)
+	case 8, 9, 10: // CIELab, ICCLab, ITULab
+		if !new(CIELab).CanHandle(ifd) {
+			return nil, fmt.Errorf("tiff/image: missing value for BitsPerSample")
+		}
+		return new(CIELab).Decoder(ifd, br)
+	}
+	return nil, fmt.Errorf("tiff/image: unsupported PhotometricInterpretation value: %d", p.PhotometricInterpretation)
 }
 
 func (BaselineHandler) CanHandle(ifd tiff.IFD) bool {
@@ -5,7 +5,6 @@
 package image
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 	"math/big"
@@ -24,6 +23,8 @@ Compression
 	Tag 259 (Compression)
 		1 = uncompressed (when encoding/writing, pack data into bytes tightly)
 		2 = CCITT Group 3 1-Dimensional Modified Huffman run length encoding (CCITT_Grp3_1-D_MH_RLE)
+		3 = CCITT T.4 (Group 3 fax, not Baseline)
+		4 = CCITT T.6 (Group 4 fax, not Baseline)
 		32773 = PackBits
 		Note: Data compression applies only to raster image data. All other TIFF fields are unaffected.
 		Note: Baseline TIFF readers must handle all three compression schemes.
@@ -78,25 +79,69 @@ type bilevelDecoder struct {
 	ImageLength               uint32   `tiff:"field,tag=257"`
 	Compression               uint16   `tiff:"field,tag=259"`
 	PhotometricInterpretation uint16   `tiff:"field,tag=262"`
-	StripOffsets              []uint32 `tiff:"field,tag=273"`
+	StripOffsets              []uint64 `tiff:"field,tag=273"`
 	RowsPerStrip              uint32   `tiff:"field,tag=278"`
-	StripByteCounts           []uint32 `tiff:"field,tag=279"`
+	StripByteCounts           []uint64 `tiff:"field,tag=279"`
 	XResolution               *big.Rat `tiff:"field,tag=282"`
 	YResolution               *big.Rat `tiff:"field,tag=283"`
 	ResolutionUnit            uint16   `tiff:"field,tag=296"`
 
+	// Not part of Baseline, but shared by every class (see Raster Data).
+	FillOrder           uint16    `tiff:"field,tag=266"`
+	Orientation         uint16    `tiff:"field,tag=274"`
+	PlanarConfiguration uint16    `tiff:"field,tag=284"`
+	T4Options           uint32    `tiff:"field,tag=292"`
+	T6Options           uint32    `tiff:"field,tag=293"`
+	Predictor           uint16    `tiff:"field,tag=317"`
+	SampleFormat        []uint16  `tiff:"field,tag=339"`
+	SMinSampleValue     []float64 `tiff:"field,tag=340"`
+	SMaxSampleValue     []float64 `tiff:"field,tag=341"`
+	TileWidth           uint32    `tiff:"field,tag=322"`
+	TileLength          uint32    `tiff:"field,tag=323"`
+	TileOffsets         []uint64  `tiff:"field,tag=324"`
+	TileByteCounts      []uint64  `tiff:"field,tag=325"`
+	ExtraSamples        []uint16  `tiff:"field,tag=338"`
+	JPEGTables          []byte    `tiff:"field,tag=347"`
+
+	// Old-style JPEG (see Old-style JPEG Compression).
+	JPEGProc                    uint16   `tiff:"field,tag=512"`
+	JPEGInterchangeFormat       uint64   `tiff:"field,tag=513"`
+	JPEGInterchangeFormatLength uint64   `tiff:"field,tag=514"`
+	JPEGRestartInterval         uint16   `tiff:"field,tag=515"`
+	JPEGQTables                 []uint64 `tiff:"field,tag=519"`
+	JPEGDCTables                []uint64 `tiff:"field,tag=520"`
+	JPEGACTables                []uint64 `tiff:"field,tag=521"`
+	YCbCrSubSampling            []uint16 `tiff:"field,tag=530"`
+
 	br  tiff.BReader
 	img image.Image
 }
 
 func (bld *bilevelDecoder) Image() (image.Image, error) {
 	if bld.img == nil {
-		return nil, fmt.Errorf("tiff/image: no baseline bilevel image found")
-		// Do decoding here...
+		r, err := newRaster(bld, 1, nil)
+		if err != nil {
+			return nil, err
+		}
+		if bld.img, err = decodeGray(r, bld.PhotometricInterpretation == 0, 0); err != nil {
+			return nil, err
+		}
 	}
 	return bld.img, nil
 }
 
+func (bld *bilevelDecoder) Planes() int {
+	return 1
+}
+
+func (bld *bilevelDecoder) Plane(i int) (image.Image, error) {
+	r, err := newRaster(bld, 1, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodePlane(r, i)
+}
+
 func (bld *bilevelDecoder) Config() (cfg image.Config, err error) {
 	cfg.Height = int(bld.ImageLength)
 	cfg.Width = int(bld.ImageWidth)
@@ -112,16 +157,18 @@ func (Bilevel) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
 	if err = tiff.UnmarshalIFD(ifd, blDec); err != nil {
 		return
 	}
-	fmt.Printf("bilevel: %#v\n", blDec)
 	return blDec, nil
 }
 
 func (Bilevel) CanHandle(ifd tiff.IFD) bool {
-	requiredTags := []uint16{256, 257, 259, 262, 273}
+	// Compression is not required since it defaults to 1 (uncompressed).
+	requiredTags := []uint16{256, 257, 262}
 	for _, tagID := range requiredTags {
 		if !ifd.HasField(tagID) {
 			return false
 		}
 	}
-	return true
+	// The image data is either in strips or in tiles, or, for old-style JPEG,
+	// in a single JPEG stream.
+	return ifd.HasField(273) || ifd.HasField(324) || ifd.HasField(513)
 }
@@ -5,7 +5,6 @@
 package image
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 
@@ -47,20 +46,128 @@ type grayscaleDecoder struct {
 	BitsPerSample  []uint16 `tiff:"field,tag=258"`
 }
 
+// raster returns the raster for the image.  Any samples past the first one
+// are extra samples (see Extra Samples).
+func (gsd *grayscaleDecoder) raster() (*raster, error) {
+	return newRaster(&gsd.bilevelDecoder, uint16(len(gsd.BitsPerSample)), gsd.BitsPerSample)
+}
+
 func (gsd *grayscaleDecoder) Image() (image.Image, error) {
 	if gsd.img == nil {
-		return nil, fmt.Errorf("tiff/image: no baseline grayscale image found")
+		r, err := gsd.raster()
+		if err != nil {
+			return nil, err
+		}
+		alpha := gsd.alpha(r.samplesPerPixel, 1)
+		if gsd.img, err = decodeGray(r, gsd.PhotometricInterpretation == 0, alpha); err != nil {
+			return nil, err
+		}
 	}
 	return gsd.img, nil
 }
 
+func (gsd *grayscaleDecoder) Planes() int {
+	if len(gsd.BitsPerSample) == 0 {
+		return 1
+	}
+	return len(gsd.BitsPerSample)
+}
+
+func (gsd *grayscaleDecoder) Plane(i int) (image.Image, error) {
+	r, err := gsd.raster()
+	if err != nil {
+		return nil, err
+	}
+	return decodePlane(r, i)
+}
+
 func (gsd *grayscaleDecoder) Config() (cfg image.Config, err error) {
 	cfg.Height = int(gsd.ImageLength)
 	cfg.Width = int(gsd.ImageWidth)
 	cfg.ColorModel = color.GrayModel
+	deep := len(gsd.BitsPerSample) > 0 && gsd.BitsPerSample[0] > 8
+	if deep || gsd.keepsValues(gsd.BitsPerSample) {
+		cfg.ColorModel = color.Gray16Model
+	}
+	if alpha := gsd.alpha(len(gsd.BitsPerSample), 1); alpha != 0 && !gsd.keepsValues(gsd.BitsPerSample) {
+		cfg.ColorModel = alphaModel(alpha, deep)
+	}
 	return
 }
 
+// decodeGray decodes the first sample of each pixel of r into an *image.Gray,
+// or an *image.Gray16 when there are more than 8 bits per sample.  Samples are
+// scaled to the full range of the image type, unless they keep their values
+// in a *Gray32f or *Bands64f (see Sample Format).  When whiteIsZero is set,
+// the samples are inverted.  A non-zero alpha is the ExtraSamples value of
+// the second sample (see Extra Samples).
+func decodeGray(r *raster, whiteIsZero bool, alpha uint16) (image.Image, error) {
+	data, err := r.decode()
+	if err != nil {
+		return nil, err
+	}
+	if r.keepsValues() {
+		return decodeValues(r, data, 1, whiteIsZero), nil
+	}
+	if alpha != 0 {
+		return decodeAlpha(r, data, 1, whiteIsZero, alpha), nil
+	}
+	return decodeSamples(r, data, whiteIsZero), nil
+}
+
+// decodeSamples converts the first sample of each pixel of the decoded rows
+// data for decodeGray.
+func decodeSamples(r *raster, data []byte, whiteIsZero bool) image.Image {
+	rect := image.Rect(0, 0, r.width, r.height)
+	rb := r.rowBytes()
+	max := r.maxValue()
+	if r.bitsPerSample > 8 {
+		img := image.NewGray16(rect)
+		for y := 0; y < r.height; y++ {
+			row := data[y*rb : (y+1)*rb]
+			pix := img.Pix[y*img.Stride:]
+			for x := 0; x < r.width; x++ {
+				v := r.sample(row, x*r.samplesPerPixel)
+				if whiteIsZero {
+					v = max - v
+				}
+				v = v * 0xffff / max
+				pix[2*x] = uint8(v >> 8)
+				pix[2*x+1] = uint8(v)
+			}
+		}
+		return img
+	}
+	img := image.NewGray(rect)
+	for y := 0; y < r.height; y++ {
+		row := data[y*rb : (y+1)*rb]
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < r.width; x++ {
+			v := r.sample(row, x*r.samplesPerPixel)
+			if whiteIsZero {
+				v = max - v
+			}
+			pix[x] = uint8(v * 0xff / max)
+		}
+	}
+	return img
+}
+
+// decodePlane decodes sample i of each pixel of r like decodeGray, without
+// inverting it.  The range of a Gray32f or Bands64f is that of sample i.
+func decodePlane(r *raster, i int) (image.Image, error) {
+	data, err := r.decodePlane(i)
+	if err != nil {
+		return nil, err
+	}
+	plane := *r
+	plane.samplesPerPixel, plane.planar = 1, false
+	if plane.keepsValues() {
+		return decodeValues(&plane, data, 1, false), nil
+	}
+	return decodeSamples(&plane, data, false), nil
+}
+
 type Grayscale struct{}
 
 func (Grayscale) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
@@ -68,7 +175,6 @@ func (Grayscale) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error)
 	if err = tiff.UnmarshalIFD(ifd, gsDec); err != nil {
 		return
 	}
-	fmt.Printf("grayscale: %#v\n", gsDec)
 	return gsDec, nil
 }
 
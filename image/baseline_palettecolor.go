@@ -54,7 +54,34 @@ type paletteColorDecoder struct {
 
 func (pcd *paletteColorDecoder) Image() (image.Image, error) {
 	if pcd.img == nil {
-		return nil, fmt.Errorf("tiff/image: no baseline palette color image found")
+		r, err := pcd.raster()
+		if err != nil {
+			return nil, err
+		}
+		if r.bitsPerSample > 8 {
+			return nil, fmt.Errorf("tiff/image: unsupported BitsPerSample value for palette color: %d", r.bitsPerSample)
+		}
+		if r.sampleFormat != 1 {
+			return nil, fmt.Errorf("tiff/image: unsupported SampleFormat value for palette color: %d", r.sampleFormat)
+		}
+		p, err := colorMapPalette(pcd.ColorMap, r.bitsPerSample)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.decode()
+		if err != nil {
+			return nil, err
+		}
+		img := image.NewPaletted(image.Rect(0, 0, r.width, r.height), p)
+		rb := r.rowBytes()
+		for y := 0; y < r.height; y++ {
+			row := data[y*rb : (y+1)*rb]
+			pix := img.Pix[y*img.Stride:]
+			for x := 0; x < r.width; x++ {
+				pix[x] = uint8(r.sample(row, x*r.samplesPerPixel))
+			}
+		}
+		pcd.img = img
 	}
 	return pcd.img, nil
 }
@@ -62,10 +89,36 @@ func (pcd *paletteColorDecoder) Image() (image.Image, error) {
 func (pcd *paletteColorDecoder) Config() (cfg image.Config, err error) {
 	cfg.Height = int(pcd.ImageLength)
 	cfg.Width = int(pcd.ImageWidth)
-	cfg.ColorModel = color.Palette{} // TODO: Take this from the ColorMap field
+	bps := 1
+	if len(pcd.BitsPerSample) > 0 {
+		bps = int(pcd.BitsPerSample[0])
+	}
+	p, err := colorMapPalette(pcd.ColorMap, bps)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.ColorModel = p
 	return
 }
 
+// colorMapPalette converts a ColorMap into a palette for images with bps bits
+// per sample.  The ColorMap must have 3 * 2**bps values: all of the reds,
+// followed by all of the greens and then all of the blues.
+func colorMapPalette(colorMap []uint16, bps int) (color.Palette, error) {
+	if bps < 1 || bps > 8 {
+		return nil, fmt.Errorf("tiff/image: unsupported BitsPerSample value for palette color: %d", bps)
+	}
+	n := 1 << uint(bps)
+	if len(colorMap) != 3*n {
+		return nil, fmt.Errorf("tiff/image: ColorMap has %d values, but needs 3 * 2**BitsPerSample (%d) for %d bits per sample", len(colorMap), 3*n, bps)
+	}
+	p := make(color.Palette, n)
+	for i := range p {
+		p[i] = color.RGBA64{colorMap[i], colorMap[n+i], colorMap[2*n+i], 0xffff}
+	}
+	return p, nil
+}
+
 type PaletteColor struct{}
 
 func (PaletteColor) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
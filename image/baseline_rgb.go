@@ -52,18 +52,100 @@ type fullColorRGBDecoder struct {
 
 func (rgbDec *fullColorRGBDecoder) Image() (image.Image, error) {
 	if rgbDec.img == nil {
-		return nil, fmt.Errorf("tiff/image: no baseline rgb image found")
+		r, err := newRaster(&rgbDec.bilevelDecoder, rgbDec.SamplesPerPixel, rgbDec.BitsPerSample)
+		if err != nil {
+			return nil, err
+		}
+		if r.samplesPerPixel < 3 {
+			return nil, fmt.Errorf("tiff/image: unsupported SamplesPerPixel value for rgb: %d", r.samplesPerPixel)
+		}
+		alpha := rgbDec.alpha(r.samplesPerPixel, 3)
+		if rgbDec.img, err = decodeRGB(r, alpha); err != nil {
+			return nil, err
+		}
 	}
 	return rgbDec.img, nil
 }
 
+func (rgbDec *fullColorRGBDecoder) Planes() int {
+	if rgbDec.SamplesPerPixel == 0 {
+		return 1
+	}
+	return int(rgbDec.SamplesPerPixel)
+}
+
+func (rgbDec *fullColorRGBDecoder) Plane(i int) (image.Image, error) {
+	r, err := newRaster(&rgbDec.bilevelDecoder, rgbDec.SamplesPerPixel, rgbDec.BitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	return decodePlane(r, i)
+}
+
 func (rgbDec *fullColorRGBDecoder) Config() (cfg image.Config, err error) {
 	cfg.Height = int(rgbDec.ImageLength)
 	cfg.Width = int(rgbDec.ImageWidth)
 	cfg.ColorModel = color.RGBAModel
+	deep := len(rgbDec.BitsPerSample) > 0 && rgbDec.BitsPerSample[0] > 8
+	if deep || rgbDec.keepsValues(rgbDec.BitsPerSample) {
+		cfg.ColorModel = color.RGBA64Model
+	}
+	if alpha := rgbDec.alpha(int(rgbDec.SamplesPerPixel), 3); alpha != 0 && !rgbDec.keepsValues(rgbDec.BitsPerSample) {
+		cfg.ColorModel = alphaModel(alpha, deep)
+	}
 	return
 }
 
+// decodeRGB decodes the first three samples of each pixel of r into an opaque
+// *image.RGBA, or an *image.RGBA64 when there are more than 8 bits per sample.
+// A non-zero alpha is the ExtraSamples value of the fourth sample (see Extra
+// Samples).  Samples that keep their values are all decoded into a *Bands64f.
+func decodeRGB(r *raster, alpha uint16) (image.Image, error) {
+	data, err := r.decode()
+	if err != nil {
+		return nil, err
+	}
+	if r.keepsValues() {
+		return decodeValues(r, data, r.samplesPerPixel, false), nil
+	}
+	if alpha != 0 {
+		return decodeAlpha(r, data, 3, false, alpha), nil
+	}
+	rect := image.Rect(0, 0, r.width, r.height)
+	rb := r.rowBytes()
+	spp := r.samplesPerPixel
+	max := r.maxValue()
+	if r.bitsPerSample > 8 {
+		img := image.NewRGBA64(rect)
+		for y := 0; y < r.height; y++ {
+			row := data[y*rb : (y+1)*rb]
+			pix := img.Pix[y*img.Stride:]
+			for x := 0; x < r.width; x++ {
+				for c := 0; c < 3; c++ {
+					v := r.sample(row, x*spp+c) * 0xffff / max
+					pix[8*x+2*c] = uint8(v >> 8)
+					pix[8*x+2*c+1] = uint8(v)
+				}
+				pix[8*x+6] = 0xff
+				pix[8*x+7] = 0xff
+			}
+		}
+		return img, nil
+	}
+	img := image.NewRGBA(rect)
+	for y := 0; y < r.height; y++ {
+		row := data[y*rb : (y+1)*rb]
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < r.width; x++ {
+			for c := 0; c < 3; c++ {
+				pix[4*x+c] = uint8(r.sample(row, x*spp+c) * 0xff / max)
+			}
+			pix[4*x+3] = 0xff
+		}
+	}
+	return img, nil
+}
+
 type FullColorRGB struct{}
 
 func (FullColorRGB) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
@@ -71,7 +153,6 @@ func (FullColorRGB) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err err
 	if err = tiff.UnmarshalIFD(ifd, rgbDec); err != nil {
 		return
 	}
-	fmt.Printf("rgb: %#v\n", rgbDec)
 	return rgbDec, nil
 }
 
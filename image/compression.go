@@ -33,6 +33,78 @@ type Compression interface {
 	Decompress([]byte) ([]byte, error)
 }
 
+// CompressionParams describes the chunk of image data being compressed or
+// decompressed for compressions that need more than the bytes themselves.
+type CompressionParams struct {
+	Width           int    // The width of the chunk in pixels.
+	Height          int    // The number of rows in the chunk.
+	SamplesPerPixel int    // The number of samples in each pixel of the chunk.
+	BitsPerSample   int    // The number of bits in each sample.
+	Photometric     uint16 // Tag 262 (PhotometricInterpretation)
+	FillOrder       uint16 // Tag 266 (FillOrder)
+	T4Options       uint32 // Tag 292 (T4Options)
+	T6Options       uint32 // Tag 293 (T6Options)
+	JPEGTables      []byte // Tag 347 (JPEGTables)
+
+	YCbCrSubSampling [2]int // Tag 530 (YCbCrSubSampling)
+
+	// YCbCrUnits asks decompressors of JPEG data to return YCbCr samples as
+	// data units of YCbCrSubSampling, like uncompressed YCbCr data (see
+	// YCbCr Images), instead of converting them to RGB.
+	YCbCrUnits bool
+}
+
+// ParamCompression is a Compression that needs CompressionParams.  Its
+// Compress and Decompress methods fail since they lack the parameters.
+type ParamCompression interface {
+	Compression
+	CompressParams([]byte, *CompressionParams) ([]byte, error)
+	DecompressParams([]byte, *CompressionParams) ([]byte, error)
+}
+
+func NewParamCompression(id uint16, name string, comp, decomp func([]byte, *CompressionParams) ([]byte, error)) ParamCompression {
+	pc := &paramCompression{
+		compressParams:   comp,
+		decompressParams: decomp,
+	}
+	pc.compression = compression{
+		id:   id,
+		name: name,
+		compress: func([]byte) ([]byte, error) {
+			return nil, CompressionError{name, "compressing needs CompressionParams"}
+		},
+		decompress: func([]byte) ([]byte, error) {
+			return nil, CompressionError{name, "decompressing needs CompressionParams"}
+		},
+	}
+	return pc
+}
+
+type paramCompression struct {
+	compression
+	compressParams   func([]byte, *CompressionParams) ([]byte, error)
+	decompressParams func([]byte, *CompressionParams) ([]byte, error)
+}
+
+func (pc *paramCompression) CompressParams(in []byte, p *CompressionParams) ([]byte, error) {
+	return pc.compressParams(in, p)
+}
+
+func (pc *paramCompression) DecompressParams(in []byte, p *CompressionParams) ([]byte, error) {
+	return pc.decompressParams(in, p)
+}
+
+// reverseBits reverses the order of the bits in each byte of b.  It is used for
+// data with a FillOrder of 2 (the lowest bit of each byte comes first).
+func reverseBits(b []byte) {
+	for i, v := range b {
+		v = v>>4 | v<<4
+		v = (v&0xcc)>>2 | (v&0x33)<<2
+		v = (v&0xaa)>>1 | (v&0x55)<<1
+		b[i] = v
+	}
+}
+
 func NewCompression(id uint16, name string, comp, decomp func([]byte) ([]byte, error)) Compression {
 	return &compression{
 		id:         id,
@@ -108,8 +180,63 @@ func decompPackBits(in []byte) ([]byte, error) {
 	return out, nil
 }
 
+// compPackBits compresses in as a single row.  Use compPackBitsRows to keep
+// runs from crossing rows, as TIFF requires.
 func compPackBits(in []byte) ([]byte, error) {
-	return nil, CompressionError{"PackBits", "compressing not implemented."}
+	return appendPackBits(make([]byte, 0, len(in)+len(in)/128+1), in), nil
+}
+
+// compPackBitsRows compresses each row of in on its own.  The length of the
+// rows comes from p.
+func compPackBitsRows(in []byte, p *CompressionParams) ([]byte, error) {
+	if p == nil || p.Width <= 0 {
+		return compPackBits(in)
+	}
+	spp, bps := p.SamplesPerPixel, p.BitsPerSample
+	if spp == 0 {
+		spp = 1
+	}
+	if bps == 0 {
+		bps = 1
+	}
+	rowBytes := (p.Width*spp*bps + 7) / 8
+	out := make([]byte, 0, len(in)+len(in)/128+len(in)/rowBytes+1)
+	for len(in) > 0 {
+		n := rowBytes
+		if n > len(in) {
+			n = len(in)
+		}
+		out = appendPackBits(out, in[:n])
+		in = in[n:]
+	}
+	return out, nil
+}
+
+// appendPackBits appends the PackBits encoding of row to out.  Runs of three
+// or more equal bytes are replicate runs and anything else, pairs of equal
+// bytes included, is copied literally.  Neither is longer than 128 bytes.
+func appendPackBits(out, row []byte) []byte {
+	for i := 0; i < len(row); {
+		j := i + 1
+		for j < len(row) && row[j] == row[i] && j-i < 128 {
+			j++
+		}
+		if j-i >= 3 {
+			out = append(out, byte(1-(j-i)), row[i])
+			i = j
+			continue
+		}
+		start := i
+		for i < len(row) && i-start < 128 {
+			if i+2 < len(row) && row[i] == row[i+1] && row[i] == row[i+2] {
+				break
+			}
+			i++
+		}
+		out = append(out, byte(i-start-1))
+		out = append(out, row[start:i]...)
+	}
+	return out
 }
 
 var (
@@ -120,11 +247,17 @@ var (
 		decompress: compUncompressed,
 	}
 
-	packbitsCompression = &compression{
-		id:         32773,
-		name:       "PackBits",
-		compress:   compPackBits,
-		decompress: decompPackBits,
+	packbitsCompression = &paramCompression{
+		compression: compression{
+			id:         32773,
+			name:       "PackBits",
+			compress:   compPackBits,
+			decompress: decompPackBits,
+		},
+		compressParams: compPackBitsRows,
+		decompressParams: func(in []byte, _ *CompressionParams) ([]byte, error) {
+			return decompPackBits(in)
+		},
 	}
 )
 
@@ -0,0 +1,712 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+/* CCITT Compression
+
+Compression
+	Tag 259 (Compression)
+		2 = CCITT Group 3 1-Dimensional Modified Huffman run length encoding
+		3 = CCITT T.4 bi-level encoding (Group 3 fax)
+		4 = CCITT T.6 bi-level encoding (Group 4 fax)
+
+Options
+	Tag 292 (T4Options)
+		Bit 0: 1 = 2-dimensional coding (otherwise 1-dimensional)
+		Bit 1: 1 = uncompressed mode may be used
+		Bit 2: 1 = fill bits were added before each EOL so it ends on a byte
+			boundary
+	Tag 293 (T6Options)
+		Bit 1: 1 = uncompressed mode may be used
+
+Notes
+	All three code one bit per pixel: runs of white (0 bits) and black (1 bits)
+	in the decompressed data.  With a PhotometricInterpretation of
+	BlackIsZero, the image is therefore reversed.
+
+	With 2, each row is coded 1-dimensionally and starts on a byte boundary.
+	There are no EOL codes.
+
+	With 3, each row starts with an EOL code, followed, with 2-dimensional
+	coding, by a bit that is 1 if the row is coded 1-dimensionally and 0 if it
+	is coded relative to the row above.  When encoding 2-dimensionally, every
+	fourth row is coded 1-dimensionally.
+
+	With 4, every row is coded relative to the row above, the first one
+	relative to an imaginary white row.  There are no EOL codes, but the data
+	may end with an EOFB (two EOL codes).
+
+	The bits of the compressed data are in FillOrder (see Tag 266).  The
+	raster reverses them before decompressing when FillOrder is 2, so
+	these only deal with data that is MSB first.
+*/
+
+const (
+	faxWhite = 0
+	faxBlack = 1
+
+	faxT4TwoD         = 1 << 0
+	faxT4Uncompressed = 1 << 1
+	faxT4FillBits     = 1 << 2
+	faxT6Uncompressed = 1 << 1
+
+	// faxKFactor is how often a T.4 row is coded 1-dimensionally when encoding
+	// 2-dimensionally.
+	faxKFactor = 4
+)
+
+// faxCode is a run length or a 2-dimensional mode with its code, written as
+// a string of '0' and '1'.
+type faxCode struct {
+	value int
+	code  string
+}
+
+var faxWhiteCodes = []faxCode{
+	// Terminating codes.
+	{0, "00110101"}, {1, "000111"}, {2, "0111"}, {3, "1000"},
+	{4, "1011"}, {5, "1100"}, {6, "1110"}, {7, "1111"},
+	{8, "10011"}, {9, "10100"}, {10, "00111"}, {11, "01000"},
+	{12, "001000"}, {13, "000011"}, {14, "110100"}, {15, "110101"},
+	{16, "101010"}, {17, "101011"}, {18, "0100111"}, {19, "0001100"},
+	{20, "0001000"}, {21, "0010111"}, {22, "0000011"}, {23, "0000100"},
+	{24, "0101000"}, {25, "0101011"}, {26, "0010011"}, {27, "0100100"},
+	{28, "0011000"}, {29, "00000010"}, {30, "00000011"}, {31, "00011010"},
+	{32, "00011011"}, {33, "00010010"}, {34, "00010011"}, {35, "00010100"},
+	{36, "00010101"}, {37, "00010110"}, {38, "00010111"}, {39, "00101000"},
+	{40, "00101001"}, {41, "00101010"}, {42, "00101011"}, {43, "00101100"},
+	{44, "00101101"}, {45, "00000100"}, {46, "00000101"}, {47, "00001010"},
+	{48, "00001011"}, {49, "01010010"}, {50, "01010011"}, {51, "01010100"},
+	{52, "01010101"}, {53, "00100100"}, {54, "00100101"}, {55, "01011000"},
+	{56, "01011001"}, {57, "01011010"}, {58, "01011011"}, {59, "01001010"},
+	{60, "01001011"}, {61, "00110010"}, {62, "00110011"}, {63, "00110100"},
+	// Makeup codes.
+	{64, "11011"}, {128, "10010"}, {192, "010111"}, {256, "0110111"},
+	{320, "00110110"}, {384, "00110111"}, {448, "01100100"}, {512, "01100101"},
+	{576, "01101000"}, {640, "01100111"}, {704, "011001100"}, {768, "011001101"},
+	{832, "011010010"}, {896, "011010011"}, {960, "011010100"}, {1024, "011010101"},
+	{1088, "011010110"}, {1152, "011010111"}, {1216, "011011000"}, {1280, "011011001"},
+	{1344, "011011010"}, {1408, "011011011"}, {1472, "010011000"}, {1536, "010011001"},
+	{1600, "010011010"}, {1664, "011000"}, {1728, "010011011"},
+}
+
+var faxBlackCodes = []faxCode{
+	// Terminating codes.
+	{0, "0000110111"}, {1, "010"}, {2, "11"}, {3, "10"},
+	{4, "011"}, {5, "0011"}, {6, "0010"}, {7, "00011"},
+	{8, "000101"}, {9, "000100"}, {10, "0000100"}, {11, "0000101"},
+	{12, "0000111"}, {13, "00000100"}, {14, "00000111"}, {15, "000011000"},
+	{16, "0000010111"}, {17, "0000011000"}, {18, "0000001000"}, {19, "00001100111"},
+	{20, "00001101000"}, {21, "00001101100"}, {22, "00000110111"}, {23, "00000101000"},
+	{24, "00000010111"}, {25, "00000011000"}, {26, "000011001010"}, {27, "000011001011"},
+	{28, "000011001100"}, {29, "000011001101"}, {30, "000001101000"}, {31, "000001101001"},
+	{32, "000001101010"}, {33, "000001101011"}, {34, "000011010010"}, {35, "000011010011"},
+	{36, "000011010100"}, {37, "000011010101"}, {38, "000011010110"}, {39, "000011010111"},
+	{40, "000001101100"}, {41, "000001101101"}, {42, "000011011010"}, {43, "000011011011"},
+	{44, "000001010100"}, {45, "000001010101"}, {46, "000001010110"}, {47, "000001010111"},
+	{48, "000001100100"}, {49, "000001100101"}, {50, "000001010010"}, {51, "000001010011"},
+	{52, "000000100100"}, {53, "000000110111"}, {54, "000000111000"}, {55, "000000100111"},
+	{56, "000000101000"}, {57, "000001011000"}, {58, "000001011001"}, {59, "000000101011"},
+	{60, "000000101100"}, {61, "000001011010"}, {62, "000001100110"}, {63, "000001100111"},
+	// Makeup codes.
+	{64, "0000001111"}, {128, "000011001000"}, {192, "000011001001"}, {256, "000001011011"},
+	{320, "000000110011"}, {384, "000000110100"}, {448, "000000110101"}, {512, "0000001101100"},
+	{576, "0000001101101"}, {640, "0000001001010"}, {704, "0000001001011"}, {768, "0000001001100"},
+	{832, "0000001001101"}, {896, "0000001110010"}, {960, "0000001110011"}, {1024, "0000001110100"},
+	{1088, "0000001110101"}, {1152, "0000001110110"}, {1216, "0000001110111"}, {1280, "0000001010010"},
+	{1344, "0000001010011"}, {1408, "0000001010100"}, {1472, "0000001010101"}, {1536, "0000001011010"},
+	{1600, "0000001011011"}, {1664, "0000001100100"}, {1728, "0000001100101"},
+}
+
+// faxExtendedCodes are the makeup codes shared by both colors.
+var faxExtendedCodes = []faxCode{
+	{1792, "00000001000"}, {1856, "00000001100"}, {1920, "00000001101"}, {1984, "000000010010"},
+	{2048, "000000010011"}, {2112, "000000010100"}, {2176, "000000010101"}, {2240, "000000010110"},
+	{2304, "000000010111"}, {2368, "000000011100"}, {2432, "000000011101"}, {2496, "000000011110"},
+	{2560, "000000011111"},
+}
+
+// 2-dimensional modes.  The vertical modes are faxV0 plus the offset of a1
+// from b1.
+const (
+	faxPass = iota
+	faxHorizontal
+	faxVL3
+	faxVL2
+	faxVL1
+	faxV0
+	faxVR1
+	faxVR2
+	faxVR3
+	faxUncompressed
+)
+
+var faxModeCodes = []faxCode{
+	{faxPass, "0001"}, {faxHorizontal, "001"},
+	{faxVL3, "0000010"}, {faxVL2, "000010"}, {faxVL1, "010"},
+	{faxV0, "1"},
+	{faxVR1, "011"}, {faxVR2, "000011"}, {faxVR3, "0000011"},
+	{faxUncompressed, "0000001111"},
+}
+
+const (
+	faxEOL        = "000000000001"
+	faxMaxCodeLen = 13
+	// faxUncompressed1D enters uncompressed mode in place of a run length.
+	faxUncompressed1D = "000000001111"
+)
+
+// faxTable maps the codes of a set of faxCodes to their values and back.
+type faxTable struct {
+	decode map[uint32]int // len<<16 | code to value
+	encode map[int]faxBits
+}
+
+type faxBits struct {
+	code uint32
+	n    uint
+}
+
+func newFaxTable(sets ...[]faxCode) *faxTable {
+	t := &faxTable{decode: make(map[uint32]int), encode: make(map[int]faxBits)}
+	for _, set := range sets {
+		for _, c := range set {
+			b := parseFaxBits(c.code)
+			t.decode[uint32(b.n)<<16|b.code] = c.value
+			t.encode[c.value] = b
+		}
+	}
+	return t
+}
+
+func parseFaxBits(s string) faxBits {
+	var b faxBits
+	for _, c := range s {
+		b.code = b.code<<1 | uint32(c-'0')
+		b.n++
+	}
+	return b
+}
+
+var (
+	faxRunTables = [2]*faxTable{
+		faxWhite: newFaxTable(faxWhiteCodes, faxExtendedCodes),
+		faxBlack: newFaxTable(faxBlackCodes, faxExtendedCodes),
+	}
+	faxModeTable = newFaxTable(faxModeCodes)
+)
+
+// faxReader reads the bits of in, MSB first.
+type faxReader struct {
+	in  []byte
+	pos int // in bits
+}
+
+func (fr *faxReader) bit() (uint32, bool) {
+	if fr.pos >= 8*len(fr.in) {
+		return 0, false
+	}
+	b := uint32(fr.in[fr.pos/8]>>(7-uint(fr.pos%8))) & 1
+	fr.pos++
+	return b, true
+}
+
+func (fr *faxReader) eof() bool {
+	return fr.pos >= 8*len(fr.in)
+}
+
+func (fr *faxReader) align() {
+	fr.pos = (fr.pos + 7) &^ 7
+}
+
+// match consumes s if the next bits are s.
+func (fr *faxReader) match(s string) bool {
+	pos := fr.pos
+	for _, c := range s {
+		if b, ok := fr.bit(); !ok || b != uint32(c-'0') {
+			fr.pos = pos
+			return false
+		}
+	}
+	return true
+}
+
+// skipEOL consumes an EOL code and any fill bits before it.  It reports
+// whether there was one.
+func (fr *faxReader) skipEOL() bool {
+	pos := fr.pos
+	zeros := 0
+	for {
+		b, ok := fr.bit()
+		if !ok {
+			break
+		}
+		if b == 1 {
+			if zeros >= 11 {
+				return true
+			}
+			break
+		}
+		zeros++
+	}
+	fr.pos = pos
+	return false
+}
+
+// code reads one code of t.
+func (fr *faxReader) code(t *faxTable) (int, error) {
+	var code uint32
+	for n := uint32(1); n <= faxMaxCodeLen; n++ {
+		b, ok := fr.bit()
+		if !ok {
+			return 0, errFaxEOF
+		}
+		code = code<<1 | b
+		if v, ok := t.decode[n<<16|code]; ok {
+			return v, nil
+		}
+	}
+	return 0, CompressionError{"CCITT", "invalid code in compressed data"}
+}
+
+// run reads a run length of color: any makeup codes and a terminating code.
+func (fr *faxReader) run(color int) (int, error) {
+	total := 0
+	for {
+		n, err := fr.code(faxRunTables[color])
+		if err != nil {
+			return 0, err
+		}
+		total += n
+		if n < 64 {
+			return total, nil
+		}
+	}
+}
+
+var errFaxEOF = CompressionError{"CCITT", "unexpected end of compressed data"}
+
+// faxRow builds a row of pixels as a list of changing elements: the positions
+// where the color changes, starting with a change from white to black.
+type faxRow struct {
+	width   int
+	pos     int
+	last    int // The color of the pixel before pos.
+	changes []int
+}
+
+func (r *faxRow) reset() {
+	r.pos, r.last = 0, faxWhite
+	r.changes = r.changes[:0]
+}
+
+// add appends a run of n pixels of color.
+func (r *faxRow) add(n, color int) error {
+	if n < 0 || r.pos+n > r.width {
+		return CompressionError{"CCITT", "run goes past the end of the row"}
+	}
+	if n == 0 {
+		return nil
+	}
+	if color != r.last {
+		r.changes = append(r.changes, r.pos)
+		r.last = color
+	}
+	r.pos += n
+	return nil
+}
+
+// pack sets the bits of the black pixels of the row in dst.
+func (r *faxRow) pack(dst []byte) {
+	for i := 0; i < len(r.changes); i += 2 {
+		end := r.width
+		if i+1 < len(r.changes) {
+			end = r.changes[i+1]
+		}
+		for x := r.changes[i]; x < end; x++ {
+			dst[x/8] |= 0x80 >> uint(x%8)
+		}
+	}
+}
+
+// faxRef returns the changing elements of a row, followed by entries of width
+// so that b1 and b2 can always be found.
+func faxRef(changes []int, width int) []int {
+	ref := make([]int, 0, len(changes)+3)
+	ref = append(ref, changes...)
+	return append(ref, width, width, width)
+}
+
+// faxNext returns the index of the first changing element in ref to the right
+// of a0 whose color is the opposite of color.  Elements at even indexes change
+// to black.
+func faxNext(ref []int, a0, color int) int {
+	i := 0
+	for ref[i] <= a0 || i%2 != color {
+		if i >= len(ref)-2 {
+			return len(ref) - 2
+		}
+		i++
+	}
+	return i
+}
+
+// faxDecoder decodes the rows of one chunk.
+type faxDecoder struct {
+	fr           faxReader
+	row          faxRow
+	ref          []int
+	uncompressed bool
+}
+
+// row1D decodes a 1-dimensionally coded row.
+func (d *faxDecoder) row1D() error {
+	color := faxWhite
+	for d.row.pos < d.row.width {
+		if d.uncompressed && d.fr.match(faxUncompressed1D) {
+			var err error
+			if color, err = d.uncompressedMode(); err != nil {
+				return err
+			}
+			continue
+		}
+		n, err := d.fr.run(color)
+		if err != nil {
+			return err
+		}
+		if err := d.row.add(n, color); err != nil {
+			return err
+		}
+		color ^= 1
+	}
+	return nil
+}
+
+// row2D decodes a row coded relative to d.ref.
+func (d *faxDecoder) row2D() error {
+	a0, color := -1, faxWhite
+	for a0 < d.row.width {
+		mode, err := d.fr.code(faxModeTable)
+		if err != nil {
+			return err
+		}
+		start := a0
+		if start < 0 {
+			start = 0
+		}
+		b := faxNext(d.ref, a0, color)
+		b1, b2 := d.ref[b], d.ref[b+1]
+		switch {
+		case mode == faxPass:
+			if err := d.row.add(b2-start, color); err != nil {
+				return err
+			}
+			a0 = b2
+		case mode == faxHorizontal:
+			n1, err := d.fr.run(color)
+			if err != nil {
+				return err
+			}
+			n2, err := d.fr.run(color ^ 1)
+			if err != nil {
+				return err
+			}
+			if err := d.row.add(n1, color); err != nil {
+				return err
+			}
+			if err := d.row.add(n2, color^1); err != nil {
+				return err
+			}
+			a0 = start + n1 + n2
+		case mode == faxUncompressed:
+			if !d.uncompressed {
+				return CompressionError{"CCITT", "uncompressed mode is not enabled"}
+			}
+			if color, err = d.uncompressedMode(); err != nil {
+				return err
+			}
+			a0 = d.row.pos
+		default:
+			a1 := b1 + mode - faxV0
+			if err := d.row.add(a1-start, color); err != nil {
+				return err
+			}
+			a0 = a1
+			color ^= 1
+		}
+	}
+	return nil
+}
+
+// uncompressedMode decodes pixels until the exit code and returns the color of
+// the next run.
+func (d *faxDecoder) uncompressedMode() (int, error) {
+	for {
+		zeros := 0
+		for {
+			b, ok := d.fr.bit()
+			if !ok {
+				return 0, errFaxEOF
+			}
+			if b == 1 {
+				break
+			}
+			zeros++
+		}
+		switch {
+		case zeros <= 4:
+			// Some white pixels followed by a black one.
+			if err := d.row.add(zeros, faxWhite); err != nil {
+				return 0, err
+			}
+			if err := d.row.add(1, faxBlack); err != nil {
+				return 0, err
+			}
+		case zeros == 5:
+			if err := d.row.add(5, faxWhite); err != nil {
+				return 0, err
+			}
+		case zeros <= 10:
+			// The exit code, with up to 4 more white pixels.
+			if err := d.row.add(zeros-6, faxWhite); err != nil {
+				return 0, err
+			}
+			t, ok := d.fr.bit()
+			if !ok {
+				return 0, errFaxEOF
+			}
+			return int(t), nil
+		default:
+			return 0, CompressionError{"CCITT", "invalid code in uncompressed mode"}
+		}
+	}
+}
+
+func decompCCITT(compression uint16, in []byte, p *CompressionParams) ([]byte, error) {
+	if p == nil || p.Width <= 0 {
+		return nil, CompressionError{"CCITT", "the width of the image is unknown"}
+	}
+	if p.SamplesPerPixel > 1 || p.BitsPerSample > 1 {
+		return nil, CompressionError{"CCITT", "only bilevel images are supported"}
+	}
+	d := &faxDecoder{fr: faxReader{in: in}, row: faxRow{width: p.Width}}
+	d.ref = faxRef(nil, p.Width)
+	twoD := false
+	switch compression {
+	case 3:
+		twoD = p.T4Options&faxT4TwoD != 0
+		d.uncompressed = p.T4Options&faxT4Uncompressed != 0
+	case 4:
+		d.uncompressed = p.T6Options&faxT6Uncompressed != 0
+	}
+
+	rowBytes := (p.Width + 7) / 8
+	out := make([]byte, 0, rowBytes*p.Height)
+	for p.Height <= 0 || len(out) < rowBytes*p.Height {
+		var err error
+		switch compression {
+		case 2:
+			if d.fr.eof() {
+				return out, nil
+			}
+			err = d.row1D()
+			d.fr.align()
+		case 3:
+			eols := 0
+			for d.fr.skipEOL() {
+				eols++
+			}
+			// Several EOLs in a row are the end of the page (RTC).
+			if eols > 1 || d.fr.eof() {
+				return out, nil
+			}
+			if !twoD {
+				err = d.row1D()
+			} else if b, ok := d.fr.bit(); !ok || d.fr.skipEOL() {
+				return out, nil
+			} else if b == 1 {
+				err = d.row1D()
+			} else {
+				err = d.row2D()
+			}
+		case 4:
+			if d.fr.skipEOL() || d.fr.eof() {
+				// EOFB.
+				return out, nil
+			}
+			err = d.row2D()
+		}
+		if err == errFaxEOF {
+			// Keep the complete rows and drop the truncated one (see
+			// Truncated Data).
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]byte, rowBytes)
+		d.row.pack(row)
+		out = append(out, row...)
+		d.ref = faxRef(d.row.changes, p.Width)
+		d.row.reset()
+	}
+	return out, nil
+}
+
+// faxWriter writes bits MSB first.
+type faxWriter struct {
+	out   []byte
+	bits  uint32
+	nbits uint
+}
+
+func (fw *faxWriter) write(b faxBits) {
+	for i := b.n; i > 0; i-- {
+		fw.bits = fw.bits<<1 | b.code>>(i-1)&1
+		fw.nbits++
+		if fw.nbits == 8 {
+			fw.out = append(fw.out, byte(fw.bits))
+			fw.bits, fw.nbits = 0, 0
+		}
+	}
+}
+
+// pos returns the number of bits written.
+func (fw *faxWriter) pos() int {
+	return 8*len(fw.out) + int(fw.nbits)
+}
+
+func (fw *faxWriter) align() {
+	if fw.nbits > 0 {
+		fw.write(faxBits{0, 8 - fw.nbits})
+	}
+}
+
+func (fw *faxWriter) run(n, color int) {
+	t := faxRunTables[color]
+	for n > 2560 {
+		fw.write(t.encode[2560])
+		n -= 2560
+	}
+	if n >= 64 {
+		fw.write(t.encode[n&^63])
+		n &= 63
+	}
+	fw.write(t.encode[n])
+}
+
+// faxChanges returns the changing elements of a packed row of width pixels.
+func faxChanges(row []byte, width int) []int {
+	var changes []int
+	last := byte(faxWhite)
+	for x := 0; x < width; x++ {
+		if c := row[x/8] >> (7 - uint(x%8)) & 1; c != last {
+			changes = append(changes, x)
+			last = c
+		}
+	}
+	return changes
+}
+
+func (fw *faxWriter) row1D(changes []int, width int) {
+	prev, color := 0, faxWhite
+	for _, c := range append(changes, width) {
+		fw.run(c-prev, color)
+		prev, color = c, color^1
+	}
+}
+
+func (fw *faxWriter) row2D(changes, ref []int, width int) {
+	cur := faxRef(changes, width)
+	a0, color := -1, faxWhite
+	for a0 < width {
+		start := a0
+		if start < 0 {
+			start = 0
+		}
+		a := faxNext(cur, a0, color)
+		a1 := cur[a]
+		b := faxNext(ref, a0, color)
+		b1, b2 := ref[b], ref[b+1]
+		switch d := a1 - b1; {
+		case b2 < a1:
+			fw.write(faxModeTable.encode[faxPass])
+			a0 = b2
+		case d >= -3 && d <= 3:
+			fw.write(faxModeTable.encode[faxV0+d])
+			a0 = a1
+			color ^= 1
+		default:
+			a2 := cur[a+1]
+			fw.write(faxModeTable.encode[faxHorizontal])
+			fw.run(a1-start, color)
+			fw.run(a2-a1, color^1)
+			a0 = a2
+		}
+	}
+}
+
+func compCCITT(compression uint16, in []byte, p *CompressionParams) ([]byte, error) {
+	if p == nil || p.Width <= 0 {
+		return nil, CompressionError{"CCITT", "the width of the image is unknown"}
+	}
+	if p.SamplesPerPixel > 1 || p.BitsPerSample > 1 {
+		return nil, CompressionError{"CCITT", "only bilevel images are supported"}
+	}
+	eol := parseFaxBits(faxEOL)
+	rowBytes := (p.Width + 7) / 8
+	fw := &faxWriter{}
+	ref := faxRef(nil, p.Width)
+	for y := 0; (y+1)*rowBytes <= len(in); y++ {
+		changes := faxChanges(in[y*rowBytes:], p.Width)
+		switch compression {
+		case 2:
+			fw.row1D(changes, p.Width)
+			fw.align()
+		case 3:
+			if p.T4Options&faxT4FillBits != 0 {
+				if n := (fw.pos() + int(eol.n)) % 8; n != 0 {
+					fw.write(faxBits{0, uint(8 - n)})
+				}
+			}
+			fw.write(eol)
+			switch {
+			case p.T4Options&faxT4TwoD == 0:
+				fw.row1D(changes, p.Width)
+			case y%faxKFactor == 0:
+				fw.write(faxBits{1, 1})
+				fw.row1D(changes, p.Width)
+			default:
+				fw.write(faxBits{0, 1})
+				fw.row2D(changes, ref, p.Width)
+			}
+		case 4:
+			fw.row2D(changes, ref, p.Width)
+		}
+		ref = faxRef(changes, p.Width)
+	}
+	if compression == 4 {
+		fw.write(eol)
+		fw.write(eol)
+	}
+	fw.align()
+	return fw.out, nil
+}
+
+func newCCITTCompression(id uint16, name string) ParamCompression {
+	return NewParamCompression(id, name,
+		func(in []byte, p *CompressionParams) ([]byte, error) {
+			return compCCITT(id, in, p)
+		},
+		func(in []byte, p *CompressionParams) ([]byte, error) {
+			return decompCCITT(id, in, p)
+		})
+}
+
+func init() {
+	RegisterCompression(newCCITTCompression(2, "CCITT Modified Huffman"))
+	RegisterCompression(newCCITTCompression(3, "CCITT Group 3"))
+	RegisterCompression(newCCITTCompression(4, "CCITT Group 4"))
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// faxTestRows returns height rows of width bilevel pixels, packed MSB first
+// with the padding bits of each row cleared.  The rows mix long runs, short
+// runs, solid rows and rows that repeat the one above, to exercise every
+// 1-dimensional and 2-dimensional code.
+func faxTestRows(width, height int) []byte {
+	rnd := rand.New(rand.NewSource(int64(width*1000 + height)))
+	rowBytes := (width + 7) / 8
+	out := make([]byte, rowBytes*height)
+	for y := 0; y < height; y++ {
+		row := out[y*rowBytes : (y+1)*rowBytes]
+		switch y % 6 {
+		case 0: // All white.
+		case 1:
+			for x := 0; x < width; x++ {
+				putBits(row, x, 1, 1)
+			}
+		case 2:
+			for x := 0; x < width; x++ {
+				putBits(row, x, 1, uint32(x%2))
+			}
+		case 3:
+			copy(row, out[(y-1)*rowBytes:y*rowBytes])
+		default:
+			color := uint32(rnd.Intn(2))
+			for x := 0; x < width; {
+				n := 1 + rnd.Intn(3000)%(width+1)
+				if rnd.Intn(3) > 0 {
+					n = 1 + rnd.Intn(12)
+				}
+				for ; n > 0 && x < width; n, x = n-1, x+1 {
+					putBits(row, x, 1, color)
+				}
+				color ^= 1
+			}
+		}
+	}
+	return out
+}
+
+func TestCCITTRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression uint16
+		t4Options   uint32
+	}{
+		{"MH", 2, 0},
+		{"G3 1-D", 3, 0},
+		{"G3 1-D fill bits", 3, faxT4FillBits},
+		{"G3 2-D", 3, faxT4TwoD},
+		{"G3 2-D fill bits", 3, faxT4TwoD | faxT4FillBits},
+		{"G4", 4, 0},
+	}
+	sizes := []struct{ width, height int }{
+		{1, 1},
+		{8, 3},
+		{75, 23},
+		{1728, 13},
+		{2600, 7},
+	}
+	for _, tt := range tests {
+		comp := GetCompression(tt.compression).(ParamCompression)
+		for _, sz := range sizes {
+			in := faxTestRows(sz.width, sz.height)
+			p := &CompressionParams{Width: sz.width, Height: sz.height, T4Options: tt.t4Options}
+			enc, err := comp.CompressParams(in, p)
+			if err != nil {
+				t.Errorf("%s %dx%d: compress: %v", tt.name, sz.width, sz.height, err)
+				continue
+			}
+			if tt.t4Options&faxT4FillBits != 0 && !faxEOLsAligned(enc) {
+				t.Errorf("%s %dx%d: EOL codes do not end on a byte boundary", tt.name, sz.width, sz.height)
+			}
+			out, err := comp.DecompressParams(enc, p)
+			if err != nil {
+				t.Errorf("%s %dx%d: decompress: %v", tt.name, sz.width, sz.height, err)
+				continue
+			}
+			if !bytes.Equal(out, in) {
+				t.Errorf("%s %dx%d: round trip differs:\ngot  %x\nwant %x", tt.name, sz.width, sz.height, out, in)
+			}
+		}
+	}
+}
+
+// faxEOLsAligned reports whether b has EOL codes and each ends on a byte
+// boundary.  An EOL is the only place where eleven or more 0 bits are
+// followed by a 1 bit, whether or not fill bits come before it.
+func faxEOLsAligned(b []byte) bool {
+	zeros, eols := 0, 0
+	for i := 0; i < 8*len(b); i++ {
+		if getBits(b, i, 1) == 0 {
+			zeros++
+			continue
+		}
+		if zeros >= 11 {
+			if i%8 != 7 {
+				return false
+			}
+			eols++
+		}
+		zeros = 0
+	}
+	return eols > 0
+}
+
+func TestCCITTTruncated(t *testing.T) {
+	const width, height = 75, 23
+	in := faxTestRows(width, height)
+	for _, c := range []uint16{2, 3, 4} {
+		comp := GetCompression(c).(ParamCompression)
+		p := &CompressionParams{Width: width, Height: height}
+		enc, err := comp.CompressParams(in, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := comp.DecompressParams(enc[:len(enc)/2], p)
+		if err != nil {
+			t.Errorf("compression %d: %v", c, err)
+			continue
+		}
+		// See Truncated Data: only complete rows are kept.
+		rowBytes := (width + 7) / 8
+		if len(out)%rowBytes != 0 || len(out) >= len(in) || !bytes.Equal(out, in[:len(out)]) {
+			t.Errorf("compression %d: got %d bytes of %d, not a prefix of complete rows", c, len(out), len(in))
+		}
+	}
+}
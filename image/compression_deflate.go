@@ -0,0 +1,70 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+/* Deflate Compression
+
+Compression
+	Tag 259 (Compression)
+		8 = Adobe Deflate
+		32946 = Deflate (the older, unofficial code for the same scheme)
+
+Notes
+	Both codes hold a zlib stream (RFC 1950) of deflate data (RFC 1951).
+	The level used for encoding is chosen when the Compression is created.
+	To encode a single image with a different level, set
+	Options.DeflateLevel.  Registering a new Compression changes the default
+	level for every encoder in the program:
+		RegisterCompression(NewDeflateCompression(8, zlib.BestCompression))
+*/
+
+// NewDeflateCompression returns a zlib based Compression registered under id
+// that compresses with level (see compress/zlib for the allowed values).
+func NewDeflateCompression(id uint16, level int) Compression {
+	name := "Deflate"
+	if id == 8 {
+		name = "Adobe Deflate"
+	}
+	return NewCompression(id, name,
+		func(in []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			w, err := zlib.NewWriterLevel(&buf, level)
+			if err != nil {
+				return nil, CompressionError{name, err.Error()}
+			}
+			if _, err := w.Write(in); err != nil {
+				return nil, CompressionError{name, err.Error()}
+			}
+			if err := w.Close(); err != nil {
+				return nil, CompressionError{name, err.Error()}
+			}
+			return buf.Bytes(), nil
+		},
+		func(in []byte) ([]byte, error) {
+			r, err := zlib.NewReader(bytes.NewReader(in))
+			if err != nil {
+				return nil, CompressionError{name, err.Error()}
+			}
+			defer r.Close()
+			out, err := io.ReadAll(r)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return nil, CompressionError{name, err.Error()}
+			}
+			// Truncated streams keep what could be decompressed (see
+			// Truncated Data).
+			return out, nil
+		})
+}
+
+func init() {
+	RegisterCompression(NewDeflateCompression(8, zlib.DefaultCompression))
+	RegisterCompression(NewDeflateCompression(32946, zlib.DefaultCompression))
+}
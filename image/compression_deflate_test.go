@@ -0,0 +1,117 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func deflateTestData() []byte {
+	var buf bytes.Buffer
+	for i := 0; i < 200; i++ {
+		buf.WriteString("a fairly repetitive line of text, ")
+		buf.WriteByte(byte(i))
+	}
+	return buf.Bytes()
+}
+
+func TestDeflateLevels(t *testing.T) {
+	in := deflateTestData()
+	sizes := make(map[int]int)
+	for _, level := range []int{zlib.HuffmanOnly, zlib.DefaultCompression, zlib.NoCompression, zlib.BestSpeed, 5, zlib.BestCompression} {
+		for _, id := range []uint16{8, 32946} {
+			out, err := NewDeflateCompression(id, level).Compress(in)
+			if err != nil {
+				t.Errorf("level %d, id %d: %v", level, id, err)
+				continue
+			}
+			sizes[level] = len(out)
+			// Both registered Compressions decode what either encodes.
+			for _, dec := range []uint16{8, 32946} {
+				got, err := GetCompression(dec).Decompress(out)
+				if err != nil {
+					t.Errorf("level %d, id %d, decoded with %d: %v", level, id, dec, err)
+					continue
+				}
+				if !bytes.Equal(got, in) {
+					t.Errorf("level %d, id %d, decoded with %d: the data differs", level, id, dec)
+				}
+			}
+		}
+	}
+	if sizes[zlib.BestCompression] > sizes[zlib.BestSpeed] || sizes[zlib.BestSpeed] >= sizes[zlib.NoCompression] {
+		t.Errorf("compressed sizes by level: %v", sizes)
+	}
+
+	_, err := NewDeflateCompression(8, zlib.BestCompression+1).Compress(in)
+	if _, ok := err.(CompressionError); !ok {
+		t.Errorf("invalid level: got error %v, want a CompressionError", err)
+	}
+}
+
+func TestDeflateTruncated(t *testing.T) {
+	in := deflateTestData()
+	out, err := GetCompression(8).Compress(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// See Truncated Data.
+	got, err := GetCompression(8).Decompress(out[:len(out)/2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || len(got) >= len(in) || !bytes.Equal(got, in[:len(got)]) {
+		t.Errorf("got %d bytes of %d from half of the stream", len(got), len(in))
+	}
+}
+
+func TestEncodeDeflateLevel(t *testing.T) {
+	m := image.NewGray(image.Rect(0, 0, 50, 40))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i / 7)
+	}
+	sizes := make(map[int]int)
+	for _, level := range []int{0, zlib.HuffmanOnly, zlib.BestSpeed, zlib.BestCompression} {
+		for _, id := range []uint16{8, 32946} {
+			var buf bytes.Buffer
+			if err := Encode(&buf, m, &Options{Compression: id, DeflateLevel: level}); err != nil {
+				t.Errorf("level %d, id %d: %v", level, id, err)
+				continue
+			}
+			sizes[level] = buf.Len()
+			got, err := Decode(&buf)
+			if err != nil {
+				t.Errorf("level %d, id %d: %v", level, id, err)
+				continue
+			}
+			for i := range m.Pix {
+				x, y := i%50, i/50
+				if g := color.GrayModel.Convert(got.At(x, y)).(color.Gray); g.Y != m.Pix[i] {
+					t.Errorf("level %d, id %d: pixel (%d, %d) is %d, want %d", level, id, x, y, g.Y, m.Pix[i])
+					break
+				}
+			}
+		}
+	}
+	if sizes[zlib.BestCompression] >= sizes[zlib.HuffmanOnly] {
+		t.Errorf("encoded sizes by level: %v", sizes)
+	}
+
+	for _, level := range []int{zlib.HuffmanOnly - 1, zlib.BestCompression + 1} {
+		err := Encode(&bytes.Buffer{}, m, &Options{Compression: 8, DeflateLevel: level})
+		if err == nil || !strings.Contains(err.Error(), "invalid DeflateLevel") {
+			t.Errorf("level %d: got error %v, want an invalid DeflateLevel", level, err)
+		}
+	}
+	// DeflateLevel is ignored for other compressions.
+	if err := Encode(&bytes.Buffer{}, m, &Options{Compression: 5, DeflateLevel: 100}); err != nil {
+		t.Errorf("LZW with a DeflateLevel: %v", err)
+	}
+}
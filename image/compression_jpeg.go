@@ -0,0 +1,258 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+/* JPEG Compression
+
+Compression
+	Tag 259 (Compression)
+		7 = JPEG
+
+Tables
+	Tag 347 (JPEGTables)
+		Type: Undefined
+		Note: An abbreviated JPEG stream (SOI, DQT and DHT segments, EOI) holding
+			the quantization and Huffman tables shared by every strip or tile.
+
+Notes
+	Each strip or tile is a JPEG stream (ITU-T T.81), usually abbreviated: it
+	leaves out the tables found in JPEGTables.  Before decoding, the tables
+	are merged into the stream so that image/jpeg can decode it.  Only 8 bit
+	samples are supported.
+
+	With a PhotometricInterpretation of YCbCr (6), the decoded samples are
+	returned as data units of YCbCrSubSampling when the CompressionParams ask
+	for YCbCrUnits, so that they are decoded like uncompressed YCbCr data.
+	Otherwise, they are converted to RGB, like the JPEGCOLORMODE_RGB mode of
+	libtiff.  With RGB (2), the samples are used as they are.
+
+	When encoding, the tables of the first chunk are stored in the
+	JPEGTables of the CompressionParams and left out of the streams of every
+	chunk with the same tables, so an IFD has a single copy of them.  Color
+	images are encoded as YCbCr with 4:2:0 subsampling and need a
+	PhotometricInterpretation of YCbCr.
+*/
+
+// JPEG markers.
+const (
+	jpegSOI = 0xd8
+	jpegEOI = 0xd9
+	jpegSOS = 0xda
+	jpegDQT = 0xdb
+	jpegDHT = 0xc4
+)
+
+// mergeJPEGTables returns the stream in with the segments of tables inserted
+// after its SOI marker.
+func mergeJPEGTables(tables, in []byte) []byte {
+	if len(tables) < 4 || len(in) < 2 || tables[0] != 0xff || tables[1] != jpegSOI || in[0] != 0xff || in[1] != jpegSOI {
+		return in
+	}
+	segs := tables[2:]
+	if n := len(segs); n >= 2 && segs[n-2] == 0xff && segs[n-1] == jpegEOI {
+		segs = segs[:n-2]
+	}
+	out := make([]byte, 0, 2+len(segs)+len(in))
+	out = append(out, in[:2]...)
+	out = append(out, segs...)
+	return append(out, in[2:]...)
+}
+
+// splitJPEGTables moves the DQT and DHT segments before the first SOS of the
+// stream in to an abbreviated stream of their own.  It returns the tables and
+// the rest of the stream.
+func splitJPEGTables(in []byte) (tables, rest []byte, ok bool) {
+	if len(in) < 2 || in[0] != 0xff || in[1] != jpegSOI {
+		return nil, nil, false
+	}
+	tables = []byte{0xff, jpegSOI}
+	rest = []byte{0xff, jpegSOI}
+	for i := 2; i+4 <= len(in); {
+		if in[i] != 0xff {
+			return nil, nil, false
+		}
+		marker := in[i+1]
+		if marker == jpegSOS {
+			rest = append(rest, in[i:]...)
+			return append(tables, 0xff, jpegEOI), rest, true
+		}
+		n := 2 + (int(in[i+2])<<8 | int(in[i+3]))
+		if i+n > len(in) {
+			return nil, nil, false
+		}
+		if marker == jpegDQT || marker == jpegDHT {
+			tables = append(tables, in[i:i+n]...)
+		} else {
+			rest = append(rest, in[i:i+n]...)
+		}
+		i += n
+	}
+	return nil, nil, false
+}
+
+func decompJPEG(in []byte, p *CompressionParams) ([]byte, error) {
+	if p == nil {
+		p = &CompressionParams{}
+	}
+	return decodeJPEG(mergeJPEGTables(p.JPEGTables, in), p)
+}
+
+// decodeJPEG decodes the complete JPEG stream and returns its samples, chunky
+// and row by row.
+func decodeJPEG(stream []byte, p *CompressionParams) ([]byte, error) {
+	if p.BitsPerSample != 0 && p.BitsPerSample != 8 {
+		return nil, CompressionError{"JPEG", "only 8 bits per sample are supported"}
+	}
+	m, err := jpeg.Decode(bytes.NewReader(stream))
+	if err != nil {
+		return nil, CompressionError{"JPEG", err.Error()}
+	}
+	if p.YCbCrUnits {
+		ycc, ok := m.(*image.YCbCr)
+		if !ok {
+			return nil, CompressionError{"JPEG", "the JPEG data is not YCbCr"}
+		}
+		h, v := p.YCbCrSubSampling[0], p.YCbCrSubSampling[1]
+		if h == 0 || v == 0 {
+			h, v = 2, 2
+		}
+		return ycbcrUnits(ycc, h, v), nil
+	}
+	bounds := m.Bounds()
+	var spp int
+	switch m.(type) {
+	case *image.Gray:
+		spp = 1
+	case *image.YCbCr, *image.RGBA:
+		spp = 3
+	case *image.CMYK:
+		spp = 4
+	default:
+		return nil, CompressionError{"JPEG", "unsupported JPEG color model"}
+	}
+	if p.SamplesPerPixel != 0 && p.SamplesPerPixel != spp {
+		return nil, CompressionError{"JPEG", "the number of JPEG components does not match SamplesPerPixel"}
+	}
+	out := make([]byte, 0, bounds.Dx()*bounds.Dy()*spp)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		switch m := m.(type) {
+		case *image.Gray:
+			i := m.PixOffset(bounds.Min.X, y)
+			out = append(out, m.Pix[i:i+bounds.Dx()]...)
+		case *image.RGBA:
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				i := m.PixOffset(x, y)
+				out = append(out, m.Pix[i:i+3]...)
+			}
+		case *image.CMYK:
+			i := m.PixOffset(bounds.Min.X, y)
+			out = append(out, m.Pix[i:i+4*bounds.Dx()]...)
+		case *image.YCbCr:
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				yi, ci := m.YOffset(x, y), m.COffset(x, y)
+				if p.Photometric == 2 {
+					// The components were never converted to YCbCr.
+					out = append(out, m.Y[yi], m.Cb[ci], m.Cr[ci])
+					continue
+				}
+				r, g, b := color.YCbCrToRGB(m.Y[yi], m.Cb[ci], m.Cr[ci])
+				out = append(out, r, g, b)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ycbcrUnits returns the samples of m as data units of h by v pixels.  Units
+// past the edges of m repeat its last row and column.
+func ycbcrUnits(m *image.YCbCr, h, v int) []byte {
+	b := m.Bounds()
+	across, down := (b.Dx()+h-1)/h, (b.Dy()+v-1)/v
+	out := make([]byte, 0, across*down*(h*v+2))
+	clamp := func(x, y int) (int, int) {
+		if x >= b.Max.X {
+			x = b.Max.X - 1
+		}
+		if y >= b.Max.Y {
+			y = b.Max.Y - 1
+		}
+		return x, y
+	}
+	for uy := 0; uy < down; uy++ {
+		for ux := 0; ux < across; ux++ {
+			bx, by := b.Min.X+ux*h, b.Min.Y+uy*v
+			for y := 0; y < v; y++ {
+				for x := 0; x < h; x++ {
+					out = append(out, m.Y[m.YOffset(clamp(bx+x, by+y))])
+				}
+			}
+			ci := m.COffset(clamp(bx, by))
+			out = append(out, m.Cb[ci], m.Cr[ci])
+		}
+	}
+	return out
+}
+
+// NewJPEGCompression returns the Compression for JPEG (7) that encodes with
+// quality (see image/jpeg for the allowed values).
+func NewJPEGCompression(quality int) ParamCompression {
+	return NewParamCompression(7, "JPEG",
+		func(in []byte, p *CompressionParams) ([]byte, error) {
+			return compJPEG(in, p, quality)
+		},
+		decompJPEG)
+}
+
+func compJPEG(in []byte, p *CompressionParams, quality int) ([]byte, error) {
+	if p == nil || p.Width <= 0 {
+		return nil, CompressionError{"JPEG", "the width of the image is unknown"}
+	}
+	if p.BitsPerSample != 8 {
+		return nil, CompressionError{"JPEG", "only 8 bits per sample are supported"}
+	}
+	rowBytes := p.Width * p.SamplesPerPixel
+	rect := image.Rect(0, 0, p.Width, len(in)/rowBytes)
+	var m image.Image
+	switch {
+	case p.SamplesPerPixel == 1:
+		m = &image.Gray{Pix: in, Stride: rowBytes, Rect: rect}
+	case p.SamplesPerPixel == 3 && p.Photometric == 6:
+		rgba := image.NewRGBA(rect)
+		for i := 0; i < rect.Dx()*rect.Dy(); i++ {
+			copy(rgba.Pix[4*i:], in[3*i:3*i+3])
+			rgba.Pix[4*i+3] = 0xff
+		}
+		m = rgba
+	default:
+		return nil, CompressionError{"JPEG", "only grayscale and YCbCr images can be encoded"}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, CompressionError{"JPEG", err.Error()}
+	}
+	tables, rest, ok := splitJPEGTables(buf.Bytes())
+	if !ok {
+		return buf.Bytes(), nil
+	}
+	if p.JPEGTables == nil {
+		p.JPEGTables = tables
+	}
+	if !bytes.Equal(p.JPEGTables, tables) {
+		// These tables differ from the shared ones, so keep them.
+		return buf.Bytes(), nil
+	}
+	return rest, nil
+}
+
+func init() {
+	RegisterCompression(NewJPEGCompression(jpeg.DefaultQuality))
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegTestChunk returns the samples of a smooth gradient, which JPEG keeps
+// close to the original, so that any larger error points at the layout of
+// the chunk.
+func jpegTestChunk(spp, w, h int) []byte {
+	b := make([]byte, 0, w*h*spp)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if spp == 1 {
+				b = append(b, uint8(40+4*x+2*y))
+				continue
+			}
+			b = append(b, uint8(30+5*x), uint8(20+6*y), uint8(200-2*x-2*y))
+		}
+	}
+	return b
+}
+
+// maxByteError returns the largest difference between the bytes of a and b,
+// which have the same length.
+func maxByteError(a, b []byte) int {
+	worst := 0
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d < 0 {
+			d = -d
+		}
+		if d > worst {
+			worst = d
+		}
+	}
+	return worst
+}
+
+// hasJPEGMarker reports whether the stream in has a segment with marker
+// before its first SOS.
+func hasJPEGMarker(in []byte, marker byte) bool {
+	for i := 2; i+4 <= len(in) && in[i] == 0xff && in[i+1] != jpegSOS; i += 2 + (int(in[i+2])<<8 | int(in[i+3])) {
+		if in[i+1] == marker {
+			return true
+		}
+	}
+	return false
+}
+
+func TestJPEGRoundTrip(t *testing.T) {
+	// The color chunks are written as YCbCr with 4:2:0 subsampling, which
+	// loses more on the gradient than gray chunks.
+	tests := []struct {
+		name        string
+		spp         int
+		photometric uint16
+		maxErr      int
+	}{
+		{"gray", 1, 1, 4},
+		{"color", 3, 6, 16},
+	}
+	// Neither size is a multiple of 8 or 16, so that the MCUs at the right
+	// and bottom edges are partial.
+	const width, height = 37, 29
+	for _, tt := range tests {
+		p := &CompressionParams{Width: width, Height: height, SamplesPerPixel: tt.spp, BitsPerSample: 8, Photometric: tt.photometric}
+		in := jpegTestChunk(tt.spp, width, height)
+		first, err := compJPEG(in, p, jpeg.DefaultQuality)
+		if err != nil {
+			t.Errorf("%s: compress: %v", tt.name, err)
+			continue
+		}
+		if p.JPEGTables == nil {
+			t.Errorf("%s: no JPEGTables were set", tt.name)
+			continue
+		}
+		// A second chunk shares the tables of the first.
+		tables := append([]byte(nil), p.JPEGTables...)
+		second, err := compJPEG(in, p, jpeg.DefaultQuality)
+		if err != nil {
+			t.Errorf("%s: compress: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(p.JPEGTables, tables) {
+			t.Errorf("%s: the second chunk changed JPEGTables", tt.name)
+		}
+		for i, enc := range [][]byte{first, second} {
+			if hasJPEGMarker(enc, jpegDQT) || hasJPEGMarker(enc, jpegDHT) {
+				t.Errorf("%s: chunk %d keeps the shared tables", tt.name, i)
+			}
+			out, err := decompJPEG(enc, p)
+			if err != nil {
+				t.Errorf("%s: chunk %d: decompress: %v", tt.name, i, err)
+				continue
+			}
+			if len(out) != len(in) {
+				t.Errorf("%s: chunk %d: got %d bytes, want %d", tt.name, i, len(out), len(in))
+				continue
+			}
+			if e := maxByteError(in, out); e > tt.maxErr {
+				t.Errorf("%s: chunk %d: samples off by up to %d, want at most %d", tt.name, i, e, tt.maxErr)
+			}
+		}
+		// Without the tables, the abbreviated stream cannot be decoded.
+		if _, err := decompJPEG(first, &CompressionParams{SamplesPerPixel: tt.spp, Photometric: tt.photometric}); err == nil {
+			t.Errorf("%s: decompressing without JPEGTables succeeded", tt.name)
+		}
+	}
+}
+
+func TestJPEGFullStream(t *testing.T) {
+	// A chunk may carry its own tables, with no JPEGTables at all.
+	const width, height = 21, 11
+	in := jpegTestChunk(1, width, height)
+	m := &image.Gray{Pix: in, Stride: width, Rect: image.Rect(0, 0, width, height)}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err := decompJPEG(buf.Bytes(), &CompressionParams{SamplesPerPixel: 1, BitsPerSample: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(in) {
+		t.Fatalf("got %d bytes, want %d", len(out), len(in))
+	}
+	if e := maxByteError(in, out); e > 4 {
+		t.Errorf("samples off by up to %d, want at most 4", e)
+	}
+	if _, err := decompJPEG(buf.Bytes(), &CompressionParams{SamplesPerPixel: 3}); err == nil {
+		t.Error("a gray stream was accepted for 3 samples per pixel")
+	}
+	if _, err := decompJPEG(buf.Bytes(), &CompressionParams{BitsPerSample: 12}); err == nil {
+		t.Error("12 bits per sample were accepted")
+	}
+}
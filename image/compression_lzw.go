@@ -0,0 +1,212 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+/* LZW Compression
+
+Compression
+	Tag 259 (Compression)
+		5 = LZW
+
+Codes
+	256 = Clear (reset the table and the code width)
+	257 = EndOfInformation
+	258 to 4095 = Table entries
+
+Notes
+	Codes start 9 bits wide and grow to at most 12 bits.  They are packed
+	MSB first.  Unlike the GIF flavor of LZW (as in compress/lzw), the code
+	width grows one code early: as soon as the next table entry is one less
+	than the largest code of the current width.
+
+	Early versions of libtiff wrote codes LSB first and grew the code width
+	without the early change (the "old-style" or "LZW bug" files).  Such data
+	starts with a 0 byte followed by a byte with its low bit set (a Clear code
+	written LSB first), which can never be the start of new-style data.
+*/
+
+const (
+	lzwClear    = 256
+	lzwEOI      = 257
+	lzwFirst    = 258
+	lzwMinWidth = 9
+	lzwMaxWidth = 12
+	lzwMaxCode  = 1<<lzwMaxWidth - 1
+)
+
+// lzwBitReader reads codes from the bits of in, MSB first or, for old-style
+// data, LSB first.
+type lzwBitReader struct {
+	in    []byte
+	lsb   bool
+	bits  uint32
+	nbits uint
+}
+
+func (br *lzwBitReader) read(width uint) (uint16, bool) {
+	for br.nbits < width {
+		if len(br.in) == 0 {
+			return 0, false
+		}
+		if br.lsb {
+			br.bits |= uint32(br.in[0]) << br.nbits
+		} else {
+			br.bits = br.bits<<8 | uint32(br.in[0])
+		}
+		br.in = br.in[1:]
+		br.nbits += 8
+	}
+	var code uint32
+	if br.lsb {
+		code = br.bits & (1<<width - 1)
+		br.bits >>= width
+	} else {
+		code = br.bits >> (br.nbits - width) & (1<<width - 1)
+	}
+	br.nbits -= width
+	return uint16(code), true
+}
+
+// lzwBitWriter writes codes MSB first.
+type lzwBitWriter struct {
+	out   []byte
+	bits  uint32
+	nbits uint
+}
+
+func (bw *lzwBitWriter) write(code uint16, width uint) {
+	bw.bits = bw.bits<<width | uint32(code)
+	bw.nbits += width
+	for bw.nbits >= 8 {
+		bw.out = append(bw.out, byte(bw.bits>>(bw.nbits-8)))
+		bw.nbits -= 8
+	}
+}
+
+func (bw *lzwBitWriter) flush() []byte {
+	if bw.nbits > 0 {
+		bw.out = append(bw.out, byte(bw.bits<<(8-bw.nbits)))
+		bw.nbits = 0
+	}
+	return bw.out
+}
+
+func decompLZW(in []byte) ([]byte, error) {
+	br := &lzwBitReader{in: in}
+	// Old-style data grows the code width one code later.
+	early := uint16(1)
+	if len(in) >= 2 && in[0] == 0 && in[1]&1 == 1 {
+		br.lsb = true
+		early = 0
+	}
+
+	// Each table entry is a run of bytes already in out.  The entry for a
+	// new code is always the entry of the previous code followed by the
+	// first byte of the current code, which is exactly what follows the
+	// previous code in out.
+	type entry struct{ start, length int }
+	var table [lzwMaxCode + 1]entry
+	out := make([]byte, 0, 4*len(in))
+	width := uint(lzwMinWidth)
+	next := uint16(lzwFirst)
+	var prev entry
+	havePrev := false
+	for {
+		code, ok := br.read(width)
+		if !ok || code == lzwEOI {
+			// Some writers omit the EndOfInformation code.
+			return out, nil
+		}
+		if code == lzwClear {
+			width = lzwMinWidth
+			next = lzwFirst
+			havePrev = false
+			continue
+		}
+		start := len(out)
+		switch {
+		case code < lzwClear:
+			out = append(out, byte(code))
+		case code < next:
+			e := table[code]
+			out = append(out, out[e.start:e.start+e.length]...)
+		case code == next && havePrev:
+			out = append(out, out[prev.start:prev.start+prev.length]...)
+			out = append(out, out[prev.start])
+		default:
+			return nil, CompressionError{"LZW", "invalid code in compressed data"}
+		}
+		if havePrev && next <= lzwMaxCode {
+			table[next] = entry{prev.start, prev.length + 1}
+			next++
+			if next+early >= 1<<width && width < lzwMaxWidth {
+				width++
+			}
+		}
+		prev = entry{start, len(out) - start}
+		havePrev = true
+	}
+}
+
+func compLZW(in []byte) ([]byte, error) {
+	bw := &lzwBitWriter{out: make([]byte, 0, len(in)/2+16)}
+	width := uint(lzwMinWidth)
+	bw.write(lzwClear, width)
+	if len(in) == 0 {
+		bw.write(lzwEOI, width)
+		return bw.flush(), nil
+	}
+
+	// The table maps a code followed by a byte to the code of that string.
+	table := make(map[uint32]uint16, 4096)
+	next := uint16(lzwFirst)
+	prefix := uint16(in[0])
+	for _, c := range in[1:] {
+		key := uint32(prefix)<<8 | uint32(c)
+		if code, ok := table[key]; ok {
+			prefix = code
+			continue
+		}
+		bw.write(prefix, width)
+		prefix = uint16(c)
+		table[key] = next
+		next++
+		switch {
+		case next == lzwMaxCode-1:
+			// The table is full.  Start over.
+			bw.write(lzwClear, width)
+			for k := range table {
+				delete(table, k)
+			}
+			width = lzwMinWidth
+			next = lzwFirst
+		case next > 1<<width-1:
+			width++
+		}
+	}
+	bw.write(prefix, width)
+	// The reader adds one more table entry after reading the last code, which
+	// may grow the code width for the EndOfInformation code.
+	next++
+	if next == lzwMaxCode-1 {
+		bw.write(lzwClear, width)
+		width = lzwMinWidth
+	} else if next > 1<<width-1 {
+		width++
+	}
+	bw.write(lzwEOI, width)
+	return bw.flush(), nil
+}
+
+var lzwCompression = &compression{
+	id:         5,
+	name:       "LZW",
+	compress:   compLZW,
+	decompress: decompLZW,
+}
+
+func init() {
+	RegisterCompression(lzwCompression)
+}
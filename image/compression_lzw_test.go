@@ -0,0 +1,92 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"compress/lzw"
+	"math/rand"
+	"testing"
+)
+
+// lzwTestData returns inputs that fill the code table several times over,
+// grow the code width at each step and hit the KwKwK case.
+func lzwTestData() map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 20000)
+	rnd.Read(random)
+	few := make([]byte, 50000)
+	for i := range few {
+		few[i] = byte(rnd.Intn(4))
+	}
+	return map[string][]byte{
+		"empty":  {},
+		"single": {42},
+		"kwkwk":  []byte("abababababababababab"),
+		"run":    bytes.Repeat([]byte{7}, 70000),
+		"text":   bytes.Repeat([]byte("TOBEORNOTTOBEORTOBEORNOT#"), 500),
+		"random": random,
+		"few":    few,
+	}
+}
+
+func TestLZWRoundTrip(t *testing.T) {
+	for name, in := range lzwTestData() {
+		enc, err := compLZW(in)
+		if err != nil {
+			t.Errorf("%s: compress: %v", name, err)
+			continue
+		}
+		out, err := decompLZW(enc)
+		if err != nil {
+			t.Errorf("%s: decompress: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(out, in) {
+			t.Errorf("%s: round trip differs: got %d bytes, want %d", name, len(out), len(in))
+		}
+	}
+}
+
+// Old-style data is the GIF flavor of LZW, which compress/lzw writes when
+// codes are LSB first with 8 bit literals.
+func TestLZWOldStyle(t *testing.T) {
+	for name, in := range lzwTestData() {
+		if len(in) == 0 {
+			// The old-style data is recognized by its first code.
+			continue
+		}
+		var buf bytes.Buffer
+		w := lzw.NewWriter(&buf, lzw.LSB, 8)
+		w.Write(in)
+		w.Close()
+		out, err := decompLZW(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(out, in) {
+			t.Errorf("%s: got %d bytes, want %d", name, len(out), len(in))
+		}
+	}
+}
+
+func TestLZWWithoutEOI(t *testing.T) {
+	in := []byte("TOBEORNOTTOBEORTOBEORNOT")
+	enc, err := compLZW(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drop the EndOfInformation code and the bits after it.  The last
+	// byte holds the end of the last code and the start of EOI.
+	enc = enc[:len(enc)-1]
+	out, err := decompLZW(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(in, out) || len(out) < len(in)-2 {
+		t.Errorf("got %q, want a prefix of %q", out, in)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+
+	"github.com/google/tiff"
+)
+
+/* Old-style JPEG Compression
+
+Compression
+	Tag 259 (Compression)
+		6 = JPEG (old-style, as described by TIFF 6.0 and superseded by 7)
+
+Fields
+	Tag 512 (JPEGProc)
+		1 = Baseline sequential
+		14 = Lossless (not supported)
+	Tag 513 (JPEGInterchangeFormat)
+		Note: The offset of a complete JPEG stream (SOI to EOI) of the image.
+	Tag 514 (JPEGInterchangeFormatLength)
+	Tag 515 (JPEGRestartInterval)
+	Tag 519 (JPEGQTables)
+		Note: For each component, the offset of a 64 byte quantization table
+			in zigzag order.
+	Tag 520 (JPEGDCTables)
+	Tag 521 (JPEGACTables)
+		Note: For each component, the offset of a Huffman table: 16 bytes of
+			code counts followed by the values.
+	Tag 530 (YCbCrSubSampling)
+		Note: The sampling factors of the first component when
+			PhotometricInterpretation is YCbCr (default 2, 2).
+
+Notes
+	This scheme was underspecified, so writers disagreed on how to use it.
+	Like libtiff, decoding is best-effort:
+	- When JPEGInterchangeFormat is present, the stream it points to is decoded
+	  as the whole image and the strips or tiles are ignored.
+	- When a strip or tile starts with an SOI marker, it is decoded as a
+	  complete JPEG stream.
+	- Otherwise, a strip or tile is the entropy-coded data of a scan.  The
+	  tables are built from the table fields and the frame and scan headers
+	  are synthesized from the dimensions of the strip or tile.
+
+	Decoded samples are handled as with JPEG (7), including the data units
+	of YCbCr data.  Encoding is not supported.
+*/
+
+// JPEG markers only needed to synthesize streams.
+const (
+	jpegSOF1 = 0xc1
+	jpegDRI  = 0xdd
+)
+
+// setupOldJPEG prepares r for old-style JPEG data.  With a
+// JPEGInterchangeFormat, the whole image becomes a single strip.  Otherwise,
+// the tables are read for the chunks.
+func (r *raster) setupOldJPEG(bld *bilevelDecoder) error {
+	if bld.JPEGProc != 0 && bld.JPEGProc != 1 {
+		return fmt.Errorf("tiff/image: unsupported JPEGProc value: %d", bld.JPEGProc)
+	}
+	if len(bld.YCbCrSubSampling) == 2 {
+		r.subSampling = [2]int{int(bld.YCbCrSubSampling[0]), int(bld.YCbCrSubSampling[1])}
+	}
+	if off := bld.JPEGInterchangeFormat; off != 0 {
+		n := bld.JPEGInterchangeFormatLength
+		if n == 0 {
+			// Guess that the stream ends with the last chunk.
+			for i := range r.offsets {
+				if i < len(r.byteCounts) && r.offsets[i]+r.byteCounts[i] > off+n {
+					n = r.offsets[i] + r.byteCounts[i] - off
+				}
+			}
+			if n == 0 {
+				return fmt.Errorf("tiff/image: missing value for JPEGInterchangeFormatLength")
+			}
+		}
+		r.tiled, r.planar = false, false
+		r.chunkWidth, r.chunkHeight = r.width, r.height
+		r.offsets, r.byteCounts = []uint64{off}, []uint64{n}
+		return nil
+	}
+	if r.jpegTables != nil || len(bld.JPEGQTables) == 0 {
+		// Hopefully, every chunk is a complete JPEG stream.
+		return nil
+	}
+	tables, err := ojpegTables(r.br, r.chunkSamples(), bld.JPEGQTables, bld.JPEGDCTables, bld.JPEGACTables, bld.JPEGRestartInterval)
+	if err != nil {
+		return err
+	}
+	r.jpegTables = tables
+	return nil
+}
+
+// ojpegTables reads the tables of the JPEGQTables, JPEGDCTables and
+// JPEGACTables offsets and returns them as an abbreviated JPEG stream, like a
+// JPEGTables field.  A table is repeated for components without their own, so
+// that component i can always use table i.  A non-zero restartInterval adds a
+// DRI segment.
+func ojpegTables(br tiff.BReader, spp int, qTables, dcTables, acTables []uint64, restartInterval uint16) ([]byte, error) {
+	if len(qTables) == 0 || len(dcTables) == 0 || len(acTables) == 0 {
+		return nil, fmt.Errorf("tiff/image: old-style JPEG data needs JPEGQTables, JPEGDCTables and JPEGACTables")
+	}
+	if spp > 4 {
+		return nil, fmt.Errorf("tiff/image: unsupported SamplesPerPixel value for old-style JPEG: %d", spp)
+	}
+	table := func(offsets []uint64, i int) uint64 {
+		if i >= len(offsets) {
+			i = len(offsets) - 1
+		}
+		return offsets[i]
+	}
+	out := []byte{0xff, jpegSOI}
+	for i := 0; i < spp; i++ {
+		q := make([]byte, 64)
+		if m, err := br.ReadAt(q, int64(table(qTables, i))); m < len(q) {
+			return nil, err
+		}
+		out = append(out, 0xff, jpegDQT, 0, 67, byte(i))
+		out = append(out, q...)
+	}
+	for class, offsets := range [][]uint64{dcTables, acTables} {
+		for i := 0; i < spp; i++ {
+			off := int64(table(offsets, i))
+			counts := make([]byte, 16)
+			if m, err := br.ReadAt(counts, off); m < len(counts) {
+				return nil, err
+			}
+			n := 0
+			for _, c := range counts {
+				n += int(c)
+			}
+			if n > 256 {
+				return nil, fmt.Errorf("tiff/image: invalid old-style JPEG Huffman table at offset %d", off)
+			}
+			values := make([]byte, n)
+			if m, err := br.ReadAt(values, off+16); m < len(values) {
+				return nil, err
+			}
+			l := 2 + 1 + 16 + n
+			out = append(out, 0xff, jpegDHT, byte(l>>8), byte(l), byte(class<<4|i))
+			out = append(out, counts...)
+			out = append(out, values...)
+		}
+	}
+	if restartInterval != 0 {
+		out = append(out, 0xff, jpegDRI, 0, 4, byte(restartInterval>>8), byte(restartInterval))
+	}
+	return append(out, 0xff, jpegEOI), nil
+}
+
+// ojpegStream synthesizes a complete JPEG stream from the entropy-coded data
+// in, the tables and the CompressionParams of the chunk.
+func ojpegStream(in []byte, p *CompressionParams) []byte {
+	spp := p.SamplesPerPixel
+	if spp == 0 {
+		spp = 1
+	}
+	out := mergeJPEGTables(p.JPEGTables, []byte{0xff, jpegSOI})
+
+	// The frame is extended sequential rather than baseline, since baseline
+	// only allows two tables of each kind.  For 8 bit samples and Huffman
+	// coding, the two are otherwise the same.
+	l := 8 + 3*spp
+	out = append(out, 0xff, jpegSOF1, byte(l>>8), byte(l), 8,
+		byte(p.Height>>8), byte(p.Height), byte(p.Width>>8), byte(p.Width), byte(spp))
+	for i := 0; i < spp; i++ {
+		h, v := 1, 1
+		if i == 0 && spp == 3 && p.Photometric == 6 {
+			h, v = 2, 2
+			if p.YCbCrSubSampling[0] != 0 {
+				h, v = p.YCbCrSubSampling[0], p.YCbCrSubSampling[1]
+			}
+		}
+		out = append(out, byte(i+1), byte(h<<4|v), byte(i))
+	}
+
+	l = 6 + 2*spp
+	out = append(out, 0xff, jpegSOS, byte(l>>8), byte(l), byte(spp))
+	for i := 0; i < spp; i++ {
+		out = append(out, byte(i+1), byte(i<<4|i))
+	}
+	out = append(out, 0, 63, 0)
+	out = append(out, in...)
+	if n := len(in); n < 2 || in[n-2] != 0xff || in[n-1] != jpegEOI {
+		out = append(out, 0xff, jpegEOI)
+	}
+	return out
+}
+
+func decompOJPEG(in []byte, p *CompressionParams) ([]byte, error) {
+	if p == nil {
+		p = &CompressionParams{}
+	}
+	if len(in) >= 2 && in[0] == 0xff && in[1] == jpegSOI {
+		return decodeJPEG(mergeJPEGTables(p.JPEGTables, in), p)
+	}
+	if p.JPEGTables == nil {
+		return nil, CompressionError{"Old-style JPEG", "no tables for the JPEG data"}
+	}
+	return decodeJPEG(ojpegStream(in, p), p)
+}
+
+func init() {
+	RegisterCompression(NewParamCompression(6, "Old-style JPEG",
+		func([]byte, *CompressionParams) ([]byte, error) {
+			return nil, CompressionError{"Old-style JPEG", "encoding is not supported, use JPEG (7)"}
+		},
+		decompOJPEG))
+}
@@ -0,0 +1,171 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// ojpegSource returns a gradient as an image and the JPEG stream of it.
+// Color images are stored as YCbCr with 4:2:0 subsampling.
+func ojpegSource(t *testing.T, spp, w, h int) (image.Image, []byte) {
+	t.Helper()
+	r := image.Rect(0, 0, w, h)
+	var m image.Image
+	if spp == 1 {
+		m = &image.Gray{Pix: jpegTestChunk(1, w, h), Stride: w, Rect: r}
+	} else {
+		rgba := image.NewRGBA(r)
+		src := jpegTestChunk(3, w, h)
+		for i := 0; i < w*h; i++ {
+			copy(rgba.Pix[4*i:], src[3*i:3*i+3])
+			rgba.Pix[4*i+3] = 0xff
+		}
+		m = rgba
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return m, buf.Bytes()
+}
+
+// ojpegParts splits a JPEG stream into the quantization tables and Huffman
+// tables (counts and values) by their class and destination, and the
+// entropy-coded data of its only scan, as old-style JPEG stores them.
+func ojpegParts(t *testing.T, in []byte) (q map[int][]byte, huff map[int][]byte, scan []byte) {
+	t.Helper()
+	q, huff = make(map[int][]byte), make(map[int][]byte)
+	for i := 2; i+4 <= len(in); {
+		marker, l := in[i+1], int(in[i+2])<<8|int(in[i+3])
+		seg := in[i+4 : i+2+l]
+		switch marker {
+		case jpegDQT:
+			for ; len(seg) >= 65; seg = seg[65:] {
+				q[int(seg[0]&0x0f)] = seg[1:65]
+			}
+		case jpegDHT:
+			for len(seg) >= 17 {
+				n := 0
+				for _, c := range seg[1:17] {
+					n += int(c)
+				}
+				huff[int(seg[0])] = seg[1 : 17+n]
+				seg = seg[17+n:]
+			}
+		case jpegSOS:
+			return q, huff, in[i+2+l:]
+		}
+		i += 2 + l
+	}
+	t.Fatal("no SOS in the JPEG stream")
+	return
+}
+
+// checkOJPEG decodes b and compares it with m.
+func checkOJPEG(t *testing.T, name string, b []byte, m image.Image, maxErr int) {
+	t.Helper()
+	got, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	if got.Bounds() != m.Bounds() {
+		t.Errorf("%s: bounds %v, want %v", name, got.Bounds(), m.Bounds())
+		return
+	}
+	worst := 0
+	r := m.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r0, g0, b0, _ := got.At(x, y).RGBA()
+			r1, g1, b1, _ := m.At(x, y).RGBA()
+			if e := maxByteError([]byte{byte(r0 >> 8), byte(g0 >> 8), byte(b0 >> 8)}, []byte{byte(r1 >> 8), byte(g1 >> 8), byte(b1 >> 8)}); e > worst {
+				worst = e
+			}
+		}
+	}
+	if worst > maxErr {
+		t.Errorf("%s: the largest error is %d, want at most %d", name, worst, maxErr)
+	}
+}
+
+// ojpegIFD returns the fields of an old-style JPEG image of spp samples.
+func ojpegIFD(bo binary.ByteOrder, spp, w, h int) *testIFD {
+	ti := newTestIFD(bo).short(259, 6).short(512, 1)
+	if spp == 1 {
+		return ti.image(uint32(w), uint32(h), 1, 8)
+	}
+	return ti.image(uint32(w), uint32(h), 6, 8, 8, 8).short(530, 2, 2)
+}
+
+func TestOldJPEG(t *testing.T) {
+	const w, h = 37, 29
+	for _, spp := range []int{1, 3} {
+		maxErr := 4
+		if spp == 3 {
+			maxErr = 16
+		}
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			m, stream := ojpegSource(t, spp, w, h)
+			name := func(form string) string {
+				if spp == 1 {
+					return form + ", gray, " + bo.String()
+				}
+				return form + ", YCbCr, " + bo.String()
+			}
+
+			// The stream pointed to by JPEGInterchangeFormat, with the
+			// strips ignored.
+			ti := ojpegIFD(bo, spp, w, h).data(513, stream).strips(uint32(h), []byte("ignored"))
+			checkOJPEG(t, name("JPEGInterchangeFormat"), testTIFF(t, ti), m, maxErr)
+
+			// A strip holding a complete stream.
+			ti = ojpegIFD(bo, spp, w, h).strips(uint32(h), stream)
+			checkOJPEG(t, name("complete strip"), testTIFF(t, ti), m, maxErr)
+
+			// The tables in JPEGQTables, JPEGDCTables and JPEGACTables,
+			// and the entropy-coded data in the strip.  The chroma
+			// components share the second set of tables.
+			q, huff, scan := ojpegParts(t, stream)
+			ti = ojpegIFD(bo, spp, w, h).strips(uint32(h), scan)
+			if spp == 1 {
+				ti.data(519, q[0]).data(520, huff[0x00]).data(521, huff[0x10])
+			} else {
+				ti.data(519, q[0], q[1], q[1]).
+					data(520, huff[0x00], huff[0x01], huff[0x01]).
+					data(521, huff[0x10], huff[0x11], huff[0x11])
+			}
+			checkOJPEG(t, name("tables"), testTIFF(t, ti), m, maxErr)
+
+			// The same without the EOI marker at the end of the data.
+			ti.strips(uint32(h), scan[:len(scan)-2])
+			checkOJPEG(t, name("tables without EOI"), testTIFF(t, ti), m, maxErr)
+		}
+	}
+}
+
+func TestOldJPEGErrors(t *testing.T) {
+	bo := binary.BigEndian
+	_, stream := ojpegSource(t, 1, 16, 16)
+	_, _, scan := ojpegParts(t, stream)
+	tests := []struct {
+		name string
+		ti   *testIFD
+	}{
+		{"lossless", ojpegIFD(bo, 1, 16, 16).short(512, 14).strips(16, stream)},
+		{"no tables", ojpegIFD(bo, 1, 16, 16).strips(16, scan)},
+		{"missing Huffman tables", ojpegIFD(bo, 1, 16, 16).strips(16, scan).data(519, make([]byte, 64))},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(bytes.NewReader(testTIFF(t, tt.ti))); err == nil {
+			t.Errorf("%s: decoded without an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"testing"
+)
+
+// seq returns n bytes that are all different from their neighbours.
+func seq(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestPackBits(t *testing.T) {
+	run := func(n int, b byte) []byte { return bytes.Repeat([]byte{b}, n) }
+	tests := []struct {
+		name     string
+		in, want []byte
+	}{
+		{"empty", nil, nil},
+		{"one byte", []byte{5}, []byte{0, 5}},
+		{"pair", []byte{7, 7}, []byte{1, 7, 7}},
+		{"pair in a literal", []byte{1, 2, 2, 3}, []byte{3, 1, 2, 2, 3}},
+		{"pairs", []byte{1, 1, 2, 2, 3, 3}, []byte{5, 1, 1, 2, 2, 3, 3}},
+		{"pair at the end", []byte{5, 6, 6}, []byte{2, 5, 6, 6}},
+		{"three bytes", []byte{4, 4, 4}, []byte{0xfe, 4}},
+		{"literal and run", []byte{1, 2, 3, 3, 3}, []byte{1, 1, 2, 0xfe, 3}},
+		{"run and literal", []byte{3, 3, 3, 1, 2}, []byte{0xfe, 3, 1, 1, 2}},
+		{"run of 128", run(128, 9), []byte{0x81, 9}},
+		{"run of 129", run(129, 9), []byte{0x81, 9, 0, 9}},
+		{"run of 130", run(130, 9), []byte{0x81, 9, 1, 9, 9}},
+		{"run of 131", run(131, 9), []byte{0x81, 9, 0xfe, 9}},
+		{"literal of 128", seq(128), join([]byte{127}, seq(128))},
+		{"literal of 129", seq(129), join([]byte{127}, seq(128), []byte{0, 128})},
+		{"literal of 128 and run", join(seq(128), run(3, 200)), join([]byte{127}, seq(128), []byte{0xfe, 200})},
+	}
+	for _, tt := range tests {
+		got, err := compPackBits(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, got, tt.want)
+		}
+		back, err := decompPackBits(got)
+		if err != nil {
+			t.Errorf("%s: decompress: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(back, tt.in) && len(back)+len(tt.in) > 0 {
+			t.Errorf("%s: decompresses to % x", tt.name, back)
+		}
+	}
+}
+
+func TestPackBitsRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        *CompressionParams
+		in, want []byte
+	}{
+		{"run over two rows", &CompressionParams{Width: 4, SamplesPerPixel: 1, BitsPerSample: 8},
+			bytes.Repeat([]byte{9}, 8), []byte{0xfd, 9, 0xfd, 9}},
+		{"literal over two rows", &CompressionParams{Width: 4, SamplesPerPixel: 1, BitsPerSample: 8},
+			seq(8), []byte{3, 0, 1, 2, 3, 3, 4, 5, 6, 7}},
+		{"run into the next row", &CompressionParams{Width: 2, SamplesPerPixel: 3, BitsPerSample: 8},
+			[]byte{1, 2, 3, 4, 4, 4, 4, 4, 5, 6, 7, 8}, []byte{2, 1, 2, 3, 0xfe, 4, 5, 4, 4, 5, 6, 7, 8}},
+		{"bilevel rows", &CompressionParams{Width: 10, SamplesPerPixel: 1, BitsPerSample: 1},
+			[]byte{0xff, 0xc0, 0xff, 0xc0}, []byte{1, 0xff, 0xc0, 1, 0xff, 0xc0}},
+		{"short last row", &CompressionParams{Width: 3, SamplesPerPixel: 1, BitsPerSample: 8},
+			[]byte{1, 1, 1, 1}, []byte{0xfe, 1, 0, 1}},
+		{"no params", nil, bytes.Repeat([]byte{9}, 8), []byte{0xf9, 9}},
+	}
+	for _, tt := range tests {
+		got, err := compPackBitsRows(tt.in, tt.p)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, got, tt.want)
+		}
+		back, err := decompPackBits(got)
+		if err != nil || !bytes.Equal(back, tt.in) {
+			t.Errorf("%s: decompresses to % x, %v", tt.name, back, err)
+		}
+	}
+}
+
+func TestPackBitsRoundTrip(t *testing.T) {
+	// Runs and literals of every length up to past the limit of 128, in
+	// every order.
+	var in []byte
+	for n := 1; n <= 260; n += 7 {
+		in = append(in, bytes.Repeat([]byte{byte(n)}, n)...)
+		in = append(in, seq(n)...)
+	}
+	for _, size := range []int{1, 2, 3, 127, 128, 129, 1000, len(in)} {
+		got, err := compPackBits(in[:size])
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := decompPackBits(got)
+		if err != nil {
+			t.Errorf("%d bytes: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(back, in[:size]) {
+			t.Errorf("%d bytes: the round trip differs", size)
+		}
+	}
+}
+
+func TestDecompPackBits(t *testing.T) {
+	// -128 is a no-op.
+	got, err := decompPackBits([]byte{0x80, 0, 7, 0x80, 0xff, 8})
+	if err != nil || !bytes.Equal(got, []byte{7, 8, 8}) {
+		t.Errorf("got % x, %v", got, err)
+	}
+	for _, in := range [][]byte{{2, 1, 2}, {0xfe}} {
+		if _, err := decompPackBits(in); err == nil {
+			t.Errorf("% x: decompressed without an error", in)
+		}
+	}
+}
@@ -3,3 +3,609 @@
 // license that can be found in the LICENSE file.
 
 package image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/tiff"
+)
+
+/* Raster Data
+
+Layout
+	The image data of an IFD is split into chunks that are either strips or
+	tiles.  Each chunk is stored compressed at its offset and has its byte
+	count of bytes.  Once decompressed, a chunk is a sequence of rows.  Rows
+	start on a byte boundary and samples are packed MSB first.  Samples of 16
+	bits are stored in the byte order of the file.
+
+Strips
+	Tag 278 (RowsPerStrip)
+	Tag 273 (StripOffsets)
+	Tag 279 (StripByteCounts)
+	Note: A strip is ImageWidth pixels wide and RowsPerStrip rows long.  The
+		last strip holds the remaining rows and may be shorter.
+
+Tiles
+	Tag 322 (TileWidth)
+	Tag 323 (TileLength)
+	Tag 324 (TileOffsets)
+	Tag 325 (TileByteCounts)
+	Note: Tiles are TileWidth pixels wide and TileLength rows long, even at
+		the right and bottom edges of the image where they are padded.  They
+		are ordered left to right and then top to bottom.
+
+Planar Configuration
+	Tag 284 (PlanarConfiguration)
+		1 = Chunky (the samples of each pixel are stored together)
+		2 = Planar (each sample is stored in its own set of chunks)
+	Note: With 2, all of the chunks for the first sample come first, followed
+		by all of the chunks for the second sample, and so on.
+
+	Note: A single plane can be decoded without reading the chunks of the
+		others (see PlaneDecoder).
+
+Fill Order
+	Tag 266 (FillOrder)
+		1 = The highest bit of each byte comes first (default)
+		2 = The lowest bit of each byte comes first
+	Note: This applies to the compressed data.  It is undone before
+		decompressing, except for JPEG data which is always MSB first.
+
+Sample Format
+	See Sample Format for signed, floating point and wide samples.
+
+Truncated Data
+	A chunk whose offset and byte count reach past the end of the file is an
+	error, and nothing is read for it.  When decoding from a stream, whose
+	size is not known up front, it is an error once the stream ends.  When
+	compressed data ends early, a decompressor may fail, but LZW, Deflate
+	and CCITT return the complete rows they decoded without an error, since
+	many writers leave out end codes (i.e. the EndOfInformation code of LZW,
+	or the RTC and EOFB of CCITT data).  The raster then checks that each
+	decompressed chunk holds every row of the image it covers, and a chunk
+	that does not is an error.  Truncated data is therefore always an
+	error, never zero-filled pixels.
+
+Image Size
+	The decoded image is held in memory, so its dimensions are checked before
+	anything is allocated.  Dimensions are rejected when the size in bytes of
+	the image or of a chunk does not fit in an int, or when the image is
+	larger than its chunks can hold.  How much a chunk can hold follows from
+	its byte count and the compression:
+		1 = the byte count
+		5 = 4096 times the byte count (an LZW code of at least 9 bits
+		    gives at most 4096 bytes)
+		8, 32946 = 1032 times the byte count (the limit of Deflate)
+		32773 = 64 times the byte count (two bytes make a run of 128)
+		2, 3, 4 = 8 rows per byte (CCITT rows take at least a bit)
+		7 = 1024 times the byte count (JPEG blocks take at least 2 bits)
+	Other compressions are only checked for overflow.  Subsampled YCbCr data
+	is only required to hold the luma samples.
+
+Defaults
+	Tag 259 (Compression) = 1
+	Tag 277 (SamplesPerPixel) = 1
+	Tag 258 (BitsPerSample) = 1
+	Tag 278 (RowsPerStrip) = 2**32-1 (a single strip)
+	Tag 284 (PlanarConfiguration) = 1
+	Tag 317 (Predictor) = 1
+	Tag 339 (SampleFormat) = 1
+*/
+
+// raster holds everything needed to read the raw samples of an image.
+type raster struct {
+	width           int
+	height          int
+	samplesPerPixel int
+	bitsPerSample   int
+	compression     uint16
+	predictor       uint16
+	planar          bool
+	photometric     uint16
+	fillOrder       uint16
+	t4Options       uint32
+	t6Options       uint32
+	jpegTables      []byte
+	subSampling     [2]int
+	ycbcrUnits      bool
+
+	// See Sample Format.
+	sampleFormat uint16
+	sMin         []float64
+	sMax         []float64
+
+	// The size of each chunk.  For strips, the chunkWidth is the width of the
+	// image.
+	chunkWidth  int
+	chunkHeight int
+	tiled       bool
+	offsets     []uint64
+	byteCounts  []uint64
+
+	br tiff.BReader
+	// size is the size of the file read by br, or -1 if it is unknown.
+	size int64
+}
+
+// newRaster builds a raster from the fields common to all Baseline classes.
+// The bitsPerSample must have one value for each sample or be empty, in
+// which case the default of 1 is used.
+func newRaster(bld *bilevelDecoder, samplesPerPixel uint16, bitsPerSample []uint16) (*raster, error) {
+	r := &raster{
+		width:           int(bld.ImageWidth),
+		height:          int(bld.ImageLength),
+		samplesPerPixel: int(samplesPerPixel),
+		bitsPerSample:   1,
+		compression:     bld.Compression,
+		predictor:       bld.Predictor,
+		photometric:     bld.PhotometricInterpretation,
+		fillOrder:       bld.FillOrder,
+		t4Options:       bld.T4Options,
+		t6Options:       bld.T6Options,
+		jpegTables:      bld.JPEGTables,
+		br:              bld.br,
+	}
+	if r.br == nil {
+		return nil, fmt.Errorf("tiff/image: no BReader available")
+	}
+	var err error
+	if r.size, err = readerSize(r.br); err != nil {
+		return nil, err
+	}
+	if r.width <= 0 || r.height <= 0 {
+		return nil, fmt.Errorf("tiff/image: invalid dimensions %dx%d", r.width, r.height)
+	}
+	if r.samplesPerPixel == 0 {
+		r.samplesPerPixel = 1
+	}
+	if r.compression == 0 {
+		r.compression = 1
+	}
+	switch r.fillOrder {
+	case 0, 1, 2:
+	default:
+		return nil, fmt.Errorf("tiff/image: unsupported FillOrder value: %d", r.fillOrder)
+	}
+	switch bld.PlanarConfiguration {
+	case 0, 1:
+	case 2:
+		r.planar = r.samplesPerPixel > 1
+	default:
+		return nil, fmt.Errorf("tiff/image: unsupported PlanarConfiguration value: %d", bld.PlanarConfiguration)
+	}
+	if len(bitsPerSample) > 0 {
+		if len(bitsPerSample) != r.samplesPerPixel {
+			return nil, fmt.Errorf("tiff/image: %d BitsPerSample values for %d samples per pixel", len(bitsPerSample), r.samplesPerPixel)
+		}
+		for _, bps := range bitsPerSample[1:] {
+			if bps != bitsPerSample[0] {
+				return nil, fmt.Errorf("tiff/image: BitsPerSample %v differ between samples", bitsPerSample)
+			}
+		}
+		r.bitsPerSample = int(bitsPerSample[0])
+	}
+	if err := r.setupSampleFormat(bld); err != nil {
+		return nil, err
+	}
+	if err := checkPredictor(r.predictor, r.sampleFormat, r.bitsPerSample); err != nil {
+		return nil, err
+	}
+
+	if len(bld.TileOffsets) > 0 {
+		r.tiled = true
+		r.chunkWidth = int(bld.TileWidth)
+		r.chunkHeight = int(bld.TileLength)
+		r.offsets, r.byteCounts = bld.TileOffsets, bld.TileByteCounts
+		if r.chunkWidth <= 0 || r.chunkHeight <= 0 {
+			return nil, fmt.Errorf("tiff/image: invalid tile dimensions %dx%d", r.chunkWidth, r.chunkHeight)
+		}
+	} else {
+		r.chunkWidth = r.width
+		r.chunkHeight = int(bld.RowsPerStrip)
+		if r.chunkHeight <= 0 || r.chunkHeight > r.height {
+			r.chunkHeight = r.height
+		}
+		r.offsets, r.byteCounts = bld.StripOffsets, bld.StripByteCounts
+	}
+	if r.compression == 6 {
+		if err := r.setupOldJPEG(bld); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.offsets) != len(r.byteCounts) {
+		return nil, fmt.Errorf("tiff/image: %d chunk offsets, but %d byte counts", len(r.offsets), len(r.byteCounts))
+	}
+	if n := r.chunksAcross() * r.chunksDown() * r.planes(); len(r.offsets) < n {
+		return nil, fmt.Errorf("tiff/image: %d chunks present, but %d needed", len(r.offsets), n)
+	}
+	if err := r.checkSize(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// mul returns a*b for non-negative a and b, and false if it overflows an int.
+func mul(a, b int) (int, bool) {
+	if a != 0 && b > maxInt/a {
+		return 0, false
+	}
+	return a * b, true
+}
+
+// maxExpansion returns how many bytes of decompressed data one byte of r's
+// compressed data can hold at most, or 0 if that is unknown.  See Image Size.
+func (r *raster) maxExpansion() uint64 {
+	switch r.compression {
+	case 1:
+		return 1
+	case 5:
+		return 4096
+	case 8, 32946:
+		return 1032
+	case 32773:
+		return 64
+	case 2, 3, 4:
+		return 8 * uint64(r.chunkRowBytes())
+	case 7:
+		return 1024
+	}
+	return 0
+}
+
+// checkSize checks that the image and its chunks have a size in bytes that
+// fits in an int and that the chunks can hold the image (see Image Size).
+func (r *raster) checkSize() error {
+	size := func(width, samples, height int) (int, bool) {
+		bits, ok := mul(width, samples)
+		if ok {
+			bits, ok = mul(bits, r.bitsPerSample)
+		}
+		if !ok || bits > maxInt-7 {
+			return 0, false
+		}
+		return mul((bits+7)/8, height)
+	}
+	need, ok := size(r.width, r.samplesPerPixel, r.height)
+	if !ok {
+		return fmt.Errorf("tiff/image: image of %dx%d pixels is too large", r.width, r.height)
+	}
+	if r.photometric == 6 && !r.planar {
+		// The chroma samples may be subsampled.
+		need, _ = size(r.width, 1, r.height)
+	}
+	if _, ok := size(r.chunkWidth, r.chunkSamples(), r.chunkHeight); !ok {
+		return fmt.Errorf("tiff/image: chunks of %dx%d pixels are too large", r.chunkWidth, r.chunkHeight)
+	}
+	ratio := r.maxExpansion()
+	if ratio == 0 {
+		return nil
+	}
+	var total uint64
+	for _, n := range r.byteCounts[:r.chunksAcross()*r.chunksDown()*r.planes()] {
+		// Counts reaching past the end of the file are an error when the
+		// chunk is read.
+		if r.size >= 0 && n > uint64(r.size) {
+			n = uint64(r.size)
+		}
+		total += n
+	}
+	if (uint64(need)+ratio-1)/ratio > total {
+		return fmt.Errorf("tiff/image: image of %dx%d pixels is larger than its %d bytes of data can hold", r.width, r.height, total)
+	}
+	return nil
+}
+
+// readerSize returns the size of the file br reads, keeping its position.  The
+// size is -1 when br cannot seek from the end, which is the case when it reads
+// from a stream (see tiff.NewReadAtReadSeeker).
+func readerSize(br tiff.BReader) (int64, error) {
+	cur, err := br.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := br.Seek(0, io.SeekEnd)
+	if err != nil {
+		end = -1
+	}
+	if _, err := br.Seek(cur, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+func (r *raster) byteOrder() binary.ByteOrder {
+	return r.br.ByteOrder()
+}
+
+// rowBytes returns the number of bytes in one row of packed samples of the
+// whole image.
+func (r *raster) rowBytes() int {
+	return (r.width*r.samplesPerPixel*r.bitsPerSample + 7) / 8
+}
+
+// maxValue returns the largest value a sample can have.
+func (r *raster) maxValue() uint32 {
+	return 1<<uint(r.bitsPerSample) - 1
+}
+
+func (r *raster) chunksAcross() int {
+	return (r.width + r.chunkWidth - 1) / r.chunkWidth
+}
+
+func (r *raster) chunksDown() int {
+	return (r.height + r.chunkHeight - 1) / r.chunkHeight
+}
+
+// planes returns the number of sets of chunks.
+func (r *raster) planes() int {
+	if r.planar {
+		return r.samplesPerPixel
+	}
+	return 1
+}
+
+// chunkSamples returns the number of samples per pixel stored in each chunk.
+func (r *raster) chunkSamples() int {
+	if r.planar {
+		return 1
+	}
+	return r.samplesPerPixel
+}
+
+// chunkRowBytes returns the number of bytes in one row of a decompressed chunk.
+func (r *raster) chunkRowBytes() int {
+	return (r.chunkWidth*r.chunkSamples()*r.bitsPerSample + 7) / 8
+}
+
+// compressionParams returns the CompressionParams of chunk i.  The last strip
+// may have fewer rows than the others.
+func (r *raster) compressionParams(i int) *CompressionParams {
+	height := r.chunkHeight
+	if !r.tiled {
+		if rest := r.height - i%r.chunksDown()*r.chunkHeight; rest < height {
+			height = rest
+		}
+	}
+	return &CompressionParams{
+		Width:           r.chunkWidth,
+		Height:          height,
+		SamplesPerPixel: r.chunkSamples(),
+		BitsPerSample:   r.bitsPerSample,
+		Photometric:     r.photometric,
+		FillOrder:       r.fillOrder,
+		T4Options:       r.t4Options,
+		T6Options:       r.t6Options,
+		JPEGTables:      r.jpegTables,
+
+		YCbCrSubSampling: r.subSampling,
+		YCbCrUnits:       r.ycbcrUnits,
+	}
+}
+
+// readChunk reads and decompresses chunk i and undoes any predictor.
+func (r *raster) readChunk(comp Compression, i int) ([]byte, error) {
+	// See Truncated Data.
+	off, n := r.offsets[i], r.byteCounts[i]
+	if r.size >= 0 && (off > uint64(r.size) || n > uint64(r.size)-off) {
+		return nil, fmt.Errorf("%d bytes at offset %d reach past the end of the file (%d bytes)", n, off, r.size)
+	}
+	buf := make([]byte, n)
+	if m, err := r.br.ReadAt(buf, int64(off)); m < len(buf) {
+		return nil, err
+	}
+	if r.fillOrder == 2 && r.compression != 6 && r.compression != 7 {
+		reverseBits(buf)
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if pc, ok := comp.(ParamCompression); ok {
+		data, err = pc.DecompressParams(buf, r.compressionParams(i))
+	} else {
+		data, err = comp.Decompress(buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := unpredict(data, r.predictor, r.chunkRowBytes(), r.chunkSamples(), r.bitsPerSample, r.byteOrder()); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decode reads and decompresses every chunk and returns the chunky rows of the
+// whole image packed one after another.
+func (r *raster) decode() ([]byte, error) {
+	rb := r.rowBytes()
+	out := make([]byte, rb*r.height)
+	for plane := 0; plane < r.planes(); plane++ {
+		err := r.decodeChunks(plane, func(y, x0 int, src []byte, n int) {
+			r.placeRow(out[y*rb:(y+1)*rb], x0, src, n, plane)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// decodePayload decodes the image data of ifd for tiff.Diff (see
+// tiff.PayloadDecoder).  The payload is the samples of every pixel, chunky and
+// row by row, except for YCbCr images which are the Y, Cb and Cr planes one
+// after another, as they are subsampled.
+func decodePayload(ifd tiff.IFD, br tiff.BReader) ([]byte, error) {
+	d := &ycbcrDecoder{grayscaleDecoder: grayscaleDecoder{bilevelDecoder: bilevelDecoder{br: br}}}
+	if err := tiff.UnmarshalIFD(ifd, d); err != nil {
+		return nil, err
+	}
+	if d.PhotometricInterpretation == 6 {
+		p, err := d.planes()
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 0, len(p.y)+len(p.cb)+len(p.cr))
+		return append(append(append(out, p.y...), p.cb...), p.cr...), nil
+	}
+	spp := d.SamplesPerPixel
+	if spp == 0 {
+		spp = uint16(len(d.BitsPerSample))
+	}
+	r, err := newRaster(&d.bilevelDecoder, spp, d.BitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	return r.decode()
+}
+
+// decodePlane returns the rows of sample i of every pixel, packed one after
+// another.  For planar rasters, only the chunks of that sample are read.
+func (r *raster) decodePlane(i int) ([]byte, error) {
+	if i < 0 || i >= r.samplesPerPixel {
+		return nil, fmt.Errorf("tiff/image: no plane %d in an image with %d samples per pixel", i, r.samplesPerPixel)
+	}
+	bps := r.bitsPerSample
+	rb := (r.width*bps + 7) / 8
+	out := make([]byte, rb*r.height)
+	if r.planar {
+		err := r.decodeChunks(i, func(y, x0 int, src []byte, n int) {
+			copySamples(out[y*rb:(y+1)*rb], x0, src, 0, n, bps)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	data, err := r.decode()
+	if err != nil {
+		return nil, err
+	}
+	crb := r.rowBytes()
+	for y := 0; y < r.height; y++ {
+		row := data[y*crb : (y+1)*crb]
+		for x := 0; x < r.width; x++ {
+			copySamples(out[y*rb:], x, row, x*r.samplesPerPixel+i, 1, bps)
+		}
+	}
+	return out, nil
+}
+
+// decodeChunks reads and decompresses every chunk of plane and calls place
+// with each of their rows: n pixels of the image row y, starting at pixel x0.
+func (r *raster) decodeChunks(plane int, place func(y, x0 int, src []byte, n int)) error {
+	comp := GetCompression(r.compression)
+	if comp == nil {
+		return CompressionNotSupported{r.compression}
+	}
+	crb := r.chunkRowBytes()
+	across, down := r.chunksAcross(), r.chunksDown()
+	for cy := 0; cy < down; cy++ {
+		for cx := 0; cx < across; cx++ {
+			i := (plane*down+cy)*across + cx
+			data, err := r.readChunk(comp, i)
+			if err != nil {
+				return fmt.Errorf("tiff/image: chunk %d: %v", i, err)
+			}
+			x0, y0 := cx*r.chunkWidth, cy*r.chunkHeight
+			cols, rows := r.chunkWidth, r.chunkHeight
+			if x0+cols > r.width {
+				cols = r.width - x0
+			}
+			if y0+rows > r.height {
+				rows = r.height - y0
+			}
+			if want := (rows-1)*crb + (cols*r.chunkSamples()*r.bitsPerSample+7)/8; len(data) < want {
+				return fmt.Errorf("tiff/image: chunk %d: got %d bytes of image data, want %d", i, len(data), want)
+			}
+			for y := 0; y < rows; y++ {
+				place(y0+y, x0, data[y*crb:], cols)
+			}
+		}
+	}
+	return nil
+}
+
+// placeRow copies n pixels from one row of a chunk into the row dst of the
+// image, starting at pixel x0.  For planar rasters, the samples in src are all
+// for the given plane.
+func (r *raster) placeRow(dst []byte, x0 int, src []byte, n int, plane int) {
+	bps := r.bitsPerSample
+	spp := r.samplesPerPixel
+	if !r.planar {
+		copySamples(dst, x0*spp, src, 0, n*spp, bps)
+		return
+	}
+	if bps%8 == 0 {
+		b := bps / 8
+		for i := 0; i < n; i++ {
+			copy(dst[((x0+i)*spp+plane)*b:], src[i*b:(i+1)*b])
+		}
+		return
+	}
+	for i := 0; i < n; i++ {
+		putBits(dst, ((x0+i)*spp+plane)*bps, bps, getBits(src, i*bps, bps))
+	}
+}
+
+// copySamples copies n samples of bps bits from src, starting at sample from,
+// to dst, starting at sample to.
+func copySamples(dst []byte, to int, src []byte, from, n, bps int) {
+	if to*bps%8 == 0 && from*bps%8 == 0 {
+		nbits := n * bps
+		d, s := dst[to*bps/8:], src[from*bps/8:]
+		copy(d, s[:nbits/8])
+		if rest := nbits % 8; rest != 0 {
+			// Keep the bits of dst past the last sample.
+			mask := byte(0xff) >> uint(rest)
+			d[nbits/8] = d[nbits/8]&mask | s[nbits/8]&^mask
+		}
+		return
+	}
+	for i := 0; i < n; i++ {
+		putBits(dst, (to+i)*bps, bps, getBits(src, (from+i)*bps, bps))
+	}
+}
+
+// getBits returns the n bits at bit offset off of b, MSB first.
+func getBits(b []byte, off, n int) uint32 {
+	var v uint32
+	for bit := off; bit < off+n; bit++ {
+		v = v<<1 | uint32(b[bit/8]>>(7-uint(bit%8))&1)
+	}
+	return v
+}
+
+// putBits sets the n bits at bit offset off of b to v, MSB first.
+func putBits(b []byte, off, n int, v uint32) {
+	for bit := off + n - 1; bit >= off; bit-- {
+		mask := byte(1) << (7 - uint(bit%8))
+		if v&1 != 0 {
+			b[bit/8] |= mask
+		} else {
+			b[bit/8] &^= mask
+		}
+		v >>= 1
+	}
+}
+
+// sample returns the i'th sample of the packed row.
+func (r *raster) sample(row []byte, i int) uint32 {
+	switch r.bitsPerSample {
+	case 8:
+		return uint32(row[i])
+	case 16:
+		return uint32(r.byteOrder().Uint16(row[2*i:]))
+	case 24:
+		b := row[3*i : 3*i+3]
+		if r.byteOrder() == binary.LittleEndian {
+			return uint32(b[2])<<16 | uint32(b[1])<<8 | uint32(b[0])
+		}
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	}
+	return getBits(row, i*r.bitsPerSample, r.bitsPerSample)
+}
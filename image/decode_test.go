@@ -0,0 +1,276 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+// testIFD builds an IFD for tiff.Write field by field.
+type testIFD struct {
+	bo binary.ByteOrder
+	wi tiff.WritableIFD
+}
+
+func newTestIFD(bo binary.ByteOrder) *testIFD {
+	return &testIFD{bo: bo}
+}
+
+func (ti *testIFD) field(tagID, typeID uint16, count uint32, value []byte) *testIFD {
+	ti.wi.SetField(tiff.NewField(tagID, typeID, count, value, ti.bo, nil, nil))
+	return ti
+}
+
+// short sets tagID to vals as Shorts.
+func (ti *testIFD) short(tagID uint16, vals ...uint32) *testIFD {
+	b := make([]byte, 2*len(vals))
+	for i, v := range vals {
+		ti.bo.PutUint16(b[2*i:], uint16(v))
+	}
+	return ti.field(tagID, 3, uint32(len(vals)), b)
+}
+
+// long sets tagID to vals as Longs.
+func (ti *testIFD) long(tagID uint16, vals ...uint32) *testIFD {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		ti.bo.PutUint32(b[4*i:], v)
+	}
+	return ti.field(tagID, 4, uint32(len(vals)), b)
+}
+
+// rational sets tagID to Rationals, given as numerator and denominator pairs.
+func (ti *testIFD) rational(tagID uint16, vals ...uint32) *testIFD {
+	ti.long(tagID, vals...)
+	return ti.field(tagID, 5, uint32(len(vals)/2), ti.wi.Field(tagID).Value().Bytes())
+}
+
+// data sets the blocks of data of the offset tag tagID.
+func (ti *testIFD) data(tagID uint16, blocks ...[]byte) *testIFD {
+	if ti.wi.Data == nil {
+		ti.wi.Data = make(map[uint16][]*io.SectionReader)
+	}
+	ti.wi.Data[tagID] = nil
+	for _, b := range blocks {
+		ti.wi.Data[tagID] = append(ti.wi.Data[tagID], io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))))
+	}
+	return ti
+}
+
+// image sets the fields every image has: its size, PhotometricInterpretation
+// and BitsPerSample, whose count is the number of samples per pixel.
+func (ti *testIFD) image(width, height, photometric uint32, bitsPerSample ...uint32) *testIFD {
+	ti.long(256, width).long(257, height).short(262, photometric).short(258, bitsPerSample...)
+	return ti.short(277, uint32(len(bitsPerSample)))
+}
+
+// strips sets the strips of the image, each of rowsPerStrip rows.
+func (ti *testIFD) strips(rowsPerStrip uint32, strips ...[]byte) *testIFD {
+	return ti.long(278, rowsPerStrip).data(273, strips...)
+}
+
+// testTIFF writes ifds as a TIFF in the byte order of the first one.
+func testTIFF(t *testing.T, ifds ...*testIFD) []byte {
+	t.Helper()
+	wifds := make([]*tiff.WritableIFD, len(ifds))
+	for i, ti := range ifds {
+		wifds[i] = &ti.wi
+	}
+	var buf bytes.Buffer
+	if err := tiff.Write(&buf, ifds[0].bo, wifds); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkPixels compares every pixel of got with want, which holds the colors
+// of the rows one after another.
+func checkPixels(t *testing.T, name string, got image.Image, width int, want []color.Color) {
+	t.Helper()
+	if b := got.Bounds(); b.Dx() != width || b.Dx()*b.Dy() != len(want) {
+		t.Errorf("%s: bounds %v, want %d pixels per row and %d in total", name, b, width, len(want))
+		return
+	}
+	for i, w := range want {
+		x, y := i%width, i/width
+		if g, w := color.RGBA64Model.Convert(got.At(x, y)), color.RGBA64Model.Convert(w); g != w {
+			t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, g, w)
+		}
+	}
+}
+
+func TestDecodeStrips(t *testing.T) {
+	be, le := binary.BigEndian, binary.LittleEndian
+	black, white := color.Gray{0}, color.Gray{0xff}
+	colorMap := make([]uint32, 3*16)
+	for i := 0; i < 16; i++ {
+		colorMap[i], colorMap[16+i], colorMap[32+i] = uint32(i)*0x1111, 0xffff, 0
+	}
+	tests := []struct {
+		name  string
+		ifd   *testIFD
+		width int
+		typ   image.Image
+		want  []color.Color
+	}{
+		{
+			"bilevel",
+			newTestIFD(be).image(10, 3, 1, 1).strips(2, []byte{0xa0, 0x40, 0xff, 0xc0}, []byte{0x01, 0x80}),
+			10, &image.Gray{},
+			[]color.Color{
+				white, black, white, black, black, black, black, black, black, white,
+				white, white, white, white, white, white, white, white, white, white,
+				black, black, black, black, black, black, black, white, white, black,
+			},
+		},
+		{
+			"bilevel WhiteIsZero",
+			newTestIFD(le).image(10, 1, 0, 1).strips(1, []byte{0xa0, 0x40}),
+			10, &image.Gray{},
+			[]color.Color{black, white, black, white, white, white, white, white, white, black},
+		},
+		{
+			"grayscale 4 bits",
+			newTestIFD(be).image(3, 2, 1, 4).strips(1, []byte{0x0f, 0x80}, []byte{0x12, 0x30}),
+			3, &image.Gray{},
+			[]color.Color{black, white, color.Gray{0x88}, color.Gray{0x11}, color.Gray{0x22}, color.Gray{0x33}},
+		},
+		{
+			"grayscale 8 bits",
+			newTestIFD(le).image(3, 3, 1, 8).strips(2, []byte{0, 1, 2, 3, 4, 5}, []byte{6, 7, 0xff}),
+			3, &image.Gray{},
+			[]color.Color{
+				color.Gray{0}, color.Gray{1}, color.Gray{2},
+				color.Gray{3}, color.Gray{4}, color.Gray{5},
+				color.Gray{6}, color.Gray{7}, color.Gray{0xff},
+			},
+		},
+		{
+			"palette",
+			newTestIFD(be).image(3, 1, 3, 4).short(320, colorMap...).strips(1, []byte{0x0f, 0x50}),
+			3, &image.Paletted{},
+			[]color.Color{
+				color.RGBA64{0, 0xffff, 0, 0xffff},
+				color.RGBA64{0xffff, 0xffff, 0, 0xffff},
+				color.RGBA64{0x5555, 0xffff, 0, 0xffff},
+			},
+		},
+		{
+			"RGB",
+			newTestIFD(le).image(2, 3, 2, 8, 8, 8).strips(2, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, []byte{13, 14, 15, 16, 17, 18}),
+			2, &image.RGBA{},
+			[]color.Color{
+				color.RGBA{1, 2, 3, 0xff}, color.RGBA{4, 5, 6, 0xff},
+				color.RGBA{7, 8, 9, 0xff}, color.RGBA{10, 11, 12, 0xff},
+				color.RGBA{13, 14, 15, 0xff}, color.RGBA{16, 17, 18, 0xff},
+			},
+		},
+		{
+			"RGB planar",
+			newTestIFD(be).image(2, 2, 2, 8, 8, 8).short(284, 2).strips(1,
+				[]byte{1, 4}, []byte{7, 10}, // Red
+				[]byte{2, 5}, []byte{8, 11}, // Green
+				[]byte{3, 6}, []byte{9, 12}), // Blue
+			2, &image.RGBA{},
+			[]color.Color{
+				color.RGBA{1, 2, 3, 0xff}, color.RGBA{4, 5, 6, 0xff},
+				color.RGBA{7, 8, 9, 0xff}, color.RGBA{10, 11, 12, 0xff},
+			},
+		},
+	}
+	for _, tt := range tests {
+		m, err := Decode(bytes.NewReader(testTIFF(t, tt.ifd)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got, want := fmt.Sprintf("%T", m), fmt.Sprintf("%T", tt.typ); got != want {
+			t.Errorf("%s: decoded as %s, want %s", tt.name, got, want)
+		}
+		checkPixels(t, tt.name, m, tt.width, tt.want)
+	}
+}
+
+func TestDecodeSize(t *testing.T) {
+	be := binary.BigEndian
+	small := make([]byte, 10)
+	tests := []struct {
+		name string
+		ifd  *testIFD
+		want string
+	}{
+		// These overflow with an int of 32 bits as well as 64 bits.
+		{"overflow", newTestIFD(be).image(0x7fffffff, 0x7fffffff, 2, 8, 8, 8).strips(0, small), "image of 2147483647x2147483647 pixels is too large"},
+		{"tile overflow", newTestIFD(be).image(16, 16, 2, 8, 8, 8).long(322, 0x7fffffff).long(323, 0x7fffffff).data(324, small), "chunks of 2147483647x2147483647 pixels are too large"},
+		{"uncompressed", newTestIFD(be).image(100, 100, 1, 8).strips(0, small), "larger than its 10 bytes of data can hold"},
+		{"PackBits", newTestIFD(be).image(100, 100, 1, 8).short(259, 32773).strips(0, small), "larger than its 10 bytes"},
+		{"LZW", newTestIFD(be).image(1000, 1000, 1, 8).short(259, 5).strips(0, small), "larger than its 10 bytes"},
+		{"Deflate", newTestIFD(be).image(1000, 1000, 1, 8).short(259, 8).strips(0, small), "larger than its 10 bytes"},
+		{"CCITT", newTestIFD(be).image(1000, 1000, 0, 1).short(259, 4).strips(0, small), "larger than its 10 bytes"},
+		{"tiles", newTestIFD(be).image(100, 100, 1, 8).long(322, 64).long(323, 64).data(324, small, small, small, small), "larger than its 40 bytes"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(testTIFF(t, tt.ifd)))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Subsampled YCbCr data is smaller than the image: two data units of
+	// 2x2 pixels hold 4x2 pixels in 12 bytes.
+	ycc := newTestIFD(be).image(4, 2, 6, 8, 8, 8).short(530, 2, 2).strips(0, make([]byte, 12))
+	if _, err := Decode(bytes.NewReader(testTIFF(t, ycc))); err != nil {
+		t.Errorf("subsampled YCbCr: %v", err)
+	}
+
+	// Byte counts reaching past the end of the file only count up to it.
+	b := testTIFF(t, newTestIFD(be).image(2000, 2000, 1, 8).strips(0, small))
+	tf, err := tiff.Parse(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := getDecoder(tf)
+	if err == nil {
+		gsd := dec.(*grayscaleDecoder)
+		gsd.StripByteCounts = []uint64{1 << 40}
+		_, err = gsd.raster()
+	}
+	if want := fmt.Sprintf("larger than its %d bytes", len(b)); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want one about %q", err, want)
+	}
+}
+
+// streamReader hides every method of its reader but Read.
+type streamReader struct{ r io.Reader }
+
+func (s streamReader) Read(p []byte) (int, error) { return s.r.Read(p) }
+
+func TestDecodeStream(t *testing.T) {
+	be := binary.BigEndian
+	b := testTIFF(t, newTestIFD(be).image(3, 2, 1, 8).strips(1, []byte{1, 2, 3}, []byte{4, 5, 6}))
+	m, err := Decode(streamReader{bytes.NewReader(b)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPixels(t, "stream", m, 3, []color.Color{
+		color.Gray{1}, color.Gray{2}, color.Gray{3},
+		color.Gray{4}, color.Gray{5}, color.Gray{6},
+	})
+
+	// tiff.Write puts the data after the IFDs, so this truncates the
+	// last strip.
+	if _, err := Decode(streamReader{bytes.NewReader(b[:len(b)-1])}); err == nil {
+		t.Error("a truncated stream decoded without an error")
+	}
+}
@@ -0,0 +1,565 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math/big"
+
+	"github.com/google/tiff"
+)
+
+/* Encoding
+
+Image Types
+	*image.Gray     PhotometricInterpretation 1, BitsPerSample 8
+	*image.Gray16   PhotometricInterpretation 1, BitsPerSample 16
+	*image.Paletted PhotometricInterpretation 3, BitsPerSample 4 or 8 (the
+			fewest that hold the palette), with a ColorMap.  A palette of
+			just black and white is written as a bilevel image instead:
+			PhotometricInterpretation 0 or 1, BitsPerSample 1
+	*image.RGBA     PhotometricInterpretation 2, BitsPerSample 8, 8, 8 and,
+			unless opaque, ExtraSamples 1 (associated alpha)
+	*image.NRGBA    As *image.RGBA, but with ExtraSamples 2 (unassociated
+			alpha)
+	*image.RGBA64   As *image.RGBA, with 16 bits per sample
+	*image.NRGBA64  As *image.NRGBA, with 16 bits per sample
+	*image.CMYK     PhotometricInterpretation 5, InkSet 1, BitsPerSample 8,
+			8, 8, 8
+	*image.YCbCr    PhotometricInterpretation 6, BitsPerSample 8, 8, 8, with
+			YCbCrSubSampling from its SubsampleRatio and full range
+			ReferenceBlackWhite
+	Other images are converted to *image.RGBA64 first.
+
+Notes
+	The samples are always chunky (PlanarConfiguration 1).
+
+	With JPEG compression (7), only opaque 8 bit gray and color images can be
+	encoded.  Color images are written as YCbCr (see JPEG Compression).
+
+	A YCbCr image whose bounds do not start at the origin is encoded as RGB,
+	as are the subsample ratios TIFF has no YCbCrSubSampling for.
+*/
+
+// Options are the encoding parameters.  The zero value writes an uncompressed,
+// little endian TIFF in strips.
+type Options struct {
+	// ByteOrder is the byte order of the file.  The default is
+	// binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+
+	// Compression is the ID of a registered Compression.  The default is 1
+	// (uncompressed).
+	Compression uint16
+
+	// Predictor is 1 (no prediction, the default) or 2 (horizontal
+	// differencing, for 8 and 16 bit samples).
+	Predictor uint16
+
+	// RowsPerStrip is the number of rows in each strip.  The default makes
+	// strips of about 8 KiB.
+	RowsPerStrip int
+
+	// TileWidth and TileLength, when both set, write the image in tiles
+	// instead of strips.  They must be multiples of 16.
+	TileWidth, TileLength int
+
+	// XResolution and YResolution are the number of pixels per
+	// ResolutionUnit.  The default is 72.
+	XResolution, YResolution *big.Rat
+
+	// ResolutionUnit is 1 (no absolute unit), 2 (inch, the default) or 3
+	// (centimeter).
+	ResolutionUnit uint16
+
+	// DeflateLevel is the level used with Deflate compression (8 and
+	// 32946), from zlib.BestSpeed to zlib.BestCompression, or
+	// zlib.HuffmanOnly.  The default of 0 uses the level of the registered
+	// Compression, which is zlib.DefaultCompression unless replaced.
+	DeflateLevel int
+}
+
+// encoder holds the layout of the samples of an image being encoded.
+type encoder struct {
+	m           image.Image
+	width       int
+	height      int
+	photometric uint16
+	spp         int
+	bps         int
+	bo          binary.ByteOrder
+	colorMap    []uint16
+	extra       []uint16
+	inkSet      uint16
+	// subSampling is set for YCbCr samples, which are stored in data units.
+	subSampling [2]int
+
+	// putRow packs the samples of n pixels of the image, starting at pixel
+	// (x, y) relative to its bounds, into dst.
+	putRow func(dst []byte, x, y, n int)
+}
+
+// ycbcrSubSampling maps the subsample ratios of image.YCbCr to
+// YCbCrSubSampling values.
+var ycbcrSubSampling = map[image.YCbCrSubsampleRatio][2]int{
+	image.YCbCrSubsampleRatio444: {1, 1},
+	image.YCbCrSubsampleRatio422: {2, 1},
+	image.YCbCrSubsampleRatio420: {2, 2},
+	image.YCbCrSubsampleRatio440: {1, 2},
+	image.YCbCrSubsampleRatio411: {4, 1},
+	image.YCbCrSubsampleRatio410: {4, 2},
+}
+
+func newEncoder(m image.Image, bo binary.ByteOrder, compression uint16) (*encoder, error) {
+	b := m.Bounds()
+	e := &encoder{m: m, width: b.Dx(), height: b.Dy(), bo: bo, bps: 8}
+	if e.width <= 0 || e.height <= 0 {
+		return nil, fmt.Errorf("tiff/image: invalid dimensions %dx%d", e.width, e.height)
+	}
+	jpeg := compression == 7
+
+	switch m := m.(type) {
+	case *image.Gray:
+		e.photometric, e.spp = 1, 1
+		e.putRow = func(dst []byte, x, y, n int) {
+			i := m.PixOffset(b.Min.X+x, b.Min.Y+y)
+			copy(dst, m.Pix[i:i+n])
+		}
+	case *image.Gray16:
+		e.photometric, e.spp, e.bps = 1, 1, 16
+		e.putRow = func(dst []byte, x, y, n int) {
+			i := m.PixOffset(b.Min.X+x, b.Min.Y+y)
+			for j := 0; j < n; j++ {
+				bo.PutUint16(dst[2*j:], binary.BigEndian.Uint16(m.Pix[i+2*j:]))
+			}
+		}
+	case *image.Paletted:
+		if len(m.Palette) > 256 {
+			return nil, fmt.Errorf("tiff/image: palette of %d colors is too large", len(m.Palette))
+		}
+		e.photometric, e.spp = 3, 1
+		if photometric, ok := bilevelPalette(m.Palette); ok {
+			e.photometric, e.bps = photometric, 1
+		} else {
+			if len(m.Palette) <= 16 {
+				e.bps = 4
+			}
+			n := 1 << uint(e.bps)
+			e.colorMap = make([]uint16, 3*n)
+			for i, c := range m.Palette {
+				r, g, b, _ := c.RGBA()
+				e.colorMap[i], e.colorMap[n+i], e.colorMap[2*n+i] = uint16(r), uint16(g), uint16(b)
+			}
+		}
+		e.putRow = func(dst []byte, x, y, n int) {
+			i := m.PixOffset(b.Min.X+x, b.Min.Y+y)
+			if e.bps == 8 {
+				copy(dst, m.Pix[i:i+n])
+				return
+			}
+			for j := 0; j < n; j++ {
+				putBits(dst, j*e.bps, e.bps, uint32(m.Pix[i+j]))
+			}
+		}
+	case *image.RGBA:
+		e.rgb(m.Opaque(), 1, func(y int) []byte {
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			return m.Pix[i : i+4*e.width]
+		})
+	case *image.NRGBA:
+		e.rgb(m.Opaque(), 2, func(y int) []byte {
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			return m.Pix[i : i+4*e.width]
+		})
+	case *image.RGBA64:
+		e.bps = 16
+		e.rgb(m.Opaque(), 1, func(y int) []byte {
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			return m.Pix[i : i+8*e.width]
+		})
+	case *image.NRGBA64:
+		e.bps = 16
+		e.rgb(m.Opaque(), 2, func(y int) []byte {
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			return m.Pix[i : i+8*e.width]
+		})
+	case *image.CMYK:
+		e.photometric, e.spp, e.inkSet = 5, 4, 1
+		e.putRow = func(dst []byte, x, y, n int) {
+			i := m.PixOffset(b.Min.X+x, b.Min.Y+y)
+			copy(dst, m.Pix[i:i+4*n])
+		}
+	case *image.YCbCr:
+		ss, ok := ycbcrSubSampling[m.SubsampleRatio]
+		if jpeg || !ok || b.Min != (image.Point{}) {
+			e.photometric, e.spp = 2, 3
+			e.putRow = func(dst []byte, x, y, n int) {
+				for j := 0; j < n; j++ {
+					c := m.YCbCrAt(b.Min.X+x+j, b.Min.Y+y)
+					dst[3*j], dst[3*j+1], dst[3*j+2] = color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+				}
+			}
+			break
+		}
+		e.photometric, e.spp, e.subSampling = 6, 3, ss
+	default:
+		rgba := image.NewRGBA64(image.Rect(0, 0, e.width, e.height))
+		draw.Draw(rgba, rgba.Rect, m, b.Min, draw.Src)
+		return newEncoder(rgba, bo, compression)
+	}
+
+	if jpeg {
+		switch {
+		case e.photometric == 1 && e.bps == 8:
+		case e.photometric == 2 && e.spp == 3 && e.bps == 8:
+			e.photometric = 6
+		default:
+			return nil, fmt.Errorf("tiff/image: JPEG compression needs an opaque gray or color image with 8 bits per sample")
+		}
+	}
+	return e, nil
+}
+
+// bilevelPalette reports whether p holds just black and white and returns the
+// PhotometricInterpretation that matches the order of the two.
+func bilevelPalette(p color.Palette) (uint16, bool) {
+	if len(p) != 2 {
+		return 0, false
+	}
+	black := color.Gray16{0}
+	white := color.Gray16{0xffff}
+	switch {
+	case color.Gray16Model.Convert(p[0]) == white && color.Gray16Model.Convert(p[1]) == black:
+		return 0, true
+	case color.Gray16Model.Convert(p[0]) == black && color.Gray16Model.Convert(p[1]) == white:
+		return 1, true
+	}
+	return 0, false
+}
+
+// rgb sets e up for RGB samples taken from rows of 8 or 16 bit RGBA pixels,
+// with alpha as an extra sample of kind extra unless the image is opaque.
+func (e *encoder) rgb(opaque bool, extra uint16, row func(y int) []byte) {
+	e.photometric, e.spp = 2, 4
+	if opaque {
+		e.spp = 3
+	} else {
+		e.extra = []uint16{extra}
+	}
+	e.putRow = func(dst []byte, x, y, n int) {
+		src := row(y)
+		if e.bps == 8 {
+			if e.spp == 4 {
+				copy(dst, src[4*x:4*(x+n)])
+				return
+			}
+			for j := 0; j < n; j++ {
+				copy(dst[3*j:3*j+3], src[4*(x+j):])
+			}
+			return
+		}
+		for j := 0; j < n; j++ {
+			for c := 0; c < e.spp; c++ {
+				e.bo.PutUint16(dst[2*(e.spp*j+c):], binary.BigEndian.Uint16(src[8*(x+j)+2*c:]))
+			}
+		}
+	}
+}
+
+// chunkRowBytes returns the number of bytes of a row of samples of a chunk
+// that is width pixels wide.  For YCbCr, a row is a row of data units.
+func (e *encoder) chunkRowBytes(width int) int {
+	if h, v := e.subSampling[0], e.subSampling[1]; h > 0 {
+		return (width + h - 1) / h * (h*v + 2)
+	}
+	return (width*e.spp*e.bps + 7) / 8
+}
+
+// chunk returns the samples of the chunk of width by height pixels at (x0,
+// y0).  Any part of the chunk outside of the image repeats its last row and
+// column, so that lossy codecs do not blur the edges of the image into the
+// padding.  Pixels smaller than a byte are padded with zeros instead.
+func (e *encoder) chunk(x0, y0, width, height int) []byte {
+	if e.subSampling[0] > 0 {
+		return e.ycbcrChunk(x0, y0, width, height)
+	}
+	rb := e.chunkRowBytes(width)
+	buf := make([]byte, rb*height)
+	n := width
+	if x0+n > e.width {
+		n = e.width - x0
+	}
+	pb := 0
+	if bits := e.spp * e.bps; bits%8 == 0 {
+		pb = bits / 8
+	}
+	for y := 0; y < height; y++ {
+		row := buf[y*rb : (y+1)*rb]
+		if y0+y >= e.height {
+			copy(row, buf[(y-1)*rb:y*rb])
+			continue
+		}
+		e.putRow(row, x0, y0+y, n)
+		for x := n; x < width && pb > 0; x++ {
+			copy(row[x*pb:(x+1)*pb], row[(n-1)*pb:n*pb])
+		}
+	}
+	return buf
+}
+
+// ycbcrChunk returns the data units of the YCbCr samples of a chunk.  Each
+// unit holds the luma samples of a block of h by v pixels, row by row,
+// followed by one Cb and one Cr sample.  Blocks past the edges of the image
+// repeat its last row and column.
+func (e *encoder) ycbcrChunk(x0, y0, width, height int) []byte {
+	m := e.m.(*image.YCbCr)
+	h, v := e.subSampling[0], e.subSampling[1]
+	unitsDown := (height + v - 1) / v
+	buf := make([]byte, 0, unitsDown*e.chunkRowBytes(width))
+	clamp := func(x, y int) (int, int) {
+		if x >= e.width {
+			x = e.width - 1
+		}
+		if y >= e.height {
+			y = e.height - 1
+		}
+		return x, y
+	}
+	for uy := 0; uy < unitsDown; uy++ {
+		for ux := 0; ux < (width+h-1)/h; ux++ {
+			bx, by := x0+ux*h, y0+uy*v
+			for y := 0; y < v; y++ {
+				for x := 0; x < h; x++ {
+					buf = append(buf, m.Y[m.YOffset(clamp(bx+x, by+y))])
+				}
+			}
+			ci := m.COffset(clamp(bx, by))
+			buf = append(buf, m.Cb[ci], m.Cr[ci])
+		}
+	}
+	return buf
+}
+
+// Encode writes the image m to w as a TIFF.  A nil opts uses the defaults.
+func Encode(w io.Writer, m image.Image, opts *Options) error {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.ByteOrder == nil {
+		o.ByteOrder = binary.LittleEndian
+	}
+	if o.Compression == 0 {
+		o.Compression = 1
+	}
+	if o.Predictor == 0 {
+		o.Predictor = 1
+	}
+	if o.XResolution == nil {
+		o.XResolution = big.NewRat(72, 1)
+	}
+	if o.YResolution == nil {
+		o.YResolution = big.NewRat(72, 1)
+	}
+	if o.ResolutionUnit == 0 {
+		o.ResolutionUnit = 2
+	}
+	comp := GetCompression(o.Compression)
+	if comp == nil {
+		return CompressionNotSupported{o.Compression}
+	}
+	if o.DeflateLevel != 0 && (o.Compression == 8 || o.Compression == 32946) {
+		if o.DeflateLevel < zlib.HuffmanOnly || o.DeflateLevel > zlib.BestCompression {
+			return fmt.Errorf("tiff/image: invalid DeflateLevel: %d", o.DeflateLevel)
+		}
+		comp = NewDeflateCompression(o.Compression, o.DeflateLevel)
+	}
+
+	e, err := newEncoder(m, o.ByteOrder, o.Compression)
+	if err != nil {
+		return err
+	}
+	switch {
+	case o.Predictor == 1:
+	case o.Predictor == 2 && e.subSampling[0] == 0 && (e.bps == 8 || e.bps == 16):
+	default:
+		return fmt.Errorf("tiff/image: Predictor %d is not supported for this image", o.Predictor)
+	}
+
+	// Lay out the chunks.
+	tiled := o.TileWidth > 0 && o.TileLength > 0
+	var chunkWidth, chunkHeight int
+	if tiled {
+		if o.TileWidth%16 != 0 || o.TileLength%16 != 0 {
+			return fmt.Errorf("tiff/image: tile dimensions %dx%d are not multiples of 16", o.TileWidth, o.TileLength)
+		}
+		chunkWidth, chunkHeight = o.TileWidth, o.TileLength
+	} else {
+		chunkWidth, chunkHeight = e.width, o.RowsPerStrip
+		if chunkHeight <= 0 {
+			chunkHeight = 8192 / e.chunkRowBytes(e.width)
+			if v := e.subSampling[1]; v > 0 {
+				chunkHeight *= v
+			}
+		}
+		if v := e.subSampling[1]; v > 0 && chunkHeight%v != 0 {
+			chunkHeight += v - chunkHeight%v
+		}
+		if chunkHeight < 1 {
+			chunkHeight = 1
+		}
+		if chunkHeight > e.height {
+			chunkHeight = e.height
+		}
+	}
+
+	params := &CompressionParams{
+		Width:           chunkWidth,
+		SamplesPerPixel: e.spp,
+		BitsPerSample:   e.bps,
+		Photometric:     e.photometric,
+		FillOrder:       1,
+	}
+	pc, _ := comp.(ParamCompression)
+	var chunks []*io.SectionReader
+	var byteCounts []uint32
+	for y0 := 0; y0 < e.height; y0 += chunkHeight {
+		for x0 := 0; x0 < e.width; x0 += chunkWidth {
+			rows := chunkHeight
+			if !tiled && y0+rows > e.height {
+				rows = e.height - y0
+			}
+			data := e.chunk(x0, y0, chunkWidth, rows)
+			if err := predict(data, o.Predictor, e.chunkRowBytes(chunkWidth), e.spp, e.bps, e.bo); err != nil {
+				return err
+			}
+			if pc != nil {
+				params.Height = rows
+				data, err = pc.CompressParams(data, params)
+			} else {
+				data, err = comp.Compress(data)
+			}
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))))
+			byteCounts = append(byteCounts, uint32(len(data)))
+		}
+	}
+
+	// Build the IFD.
+	wi := &tiff.WritableIFD{Data: make(map[uint16][]*io.SectionReader)}
+	fb := &fieldBuilder{wi: wi, bo: o.ByteOrder}
+	fb.long(256, uint32(e.width))
+	fb.long(257, uint32(e.height))
+	bps := make([]uint32, e.spp)
+	for i := range bps {
+		bps[i] = uint32(e.bps)
+	}
+	fb.short(258, bps...)
+	fb.short(259, uint32(o.Compression))
+	fb.short(262, uint32(e.photometric))
+	fb.short(277, uint32(e.spp))
+	fb.rational(282, o.XResolution)
+	fb.rational(283, o.YResolution)
+	fb.short(284, 1)
+	fb.short(296, uint32(o.ResolutionUnit))
+	if o.Predictor != 1 {
+		fb.short(317, uint32(o.Predictor))
+	}
+	offsets := make([]uint32, len(chunks))
+	if tiled {
+		fb.long(322, uint32(chunkWidth))
+		fb.long(323, uint32(chunkHeight))
+		fb.long(324, offsets...)
+		fb.long(325, byteCounts...)
+		wi.Data[324] = chunks
+	} else {
+		fb.long(273, offsets...)
+		fb.long(278, uint32(chunkHeight))
+		fb.long(279, byteCounts...)
+		wi.Data[273] = chunks
+	}
+	if e.colorMap != nil {
+		cm := make([]uint32, len(e.colorMap))
+		for i, c := range e.colorMap {
+			cm[i] = uint32(c)
+		}
+		fb.short(320, cm...)
+	}
+	if e.inkSet != 0 {
+		fb.short(332, uint32(e.inkSet))
+	}
+	if e.extra != nil {
+		fb.short(338, uint32(e.extra[0]))
+	}
+	if params.JPEGTables != nil {
+		wi.SetField(tiff.NewField(347, 7, uint32(len(params.JPEGTables)), params.JPEGTables, o.ByteOrder, nil, nil))
+	}
+	if e.photometric == 6 {
+		ss := e.subSampling
+		if ss[0] == 0 {
+			// The subsampling of the JPEG data.
+			ss = [2]int{2, 2}
+		}
+		fb.short(530, uint32(ss[0]), uint32(ss[1]))
+		fb.short(531, 1)
+		var refBW []*big.Rat
+		for _, v := range []int64{0, 255, 128, 255, 128, 255} {
+			refBW = append(refBW, big.NewRat(v, 1))
+		}
+		fb.rational(532, refBW...)
+	}
+	if fb.err != nil {
+		return fb.err
+	}
+	return tiff.Write(w, o.ByteOrder, []*tiff.WritableIFD{wi})
+}
+
+// fieldBuilder adds fields to a WritableIFD.
+type fieldBuilder struct {
+	wi  *tiff.WritableIFD
+	bo  binary.ByteOrder
+	err error
+}
+
+func (fb *fieldBuilder) short(tag uint16, vals ...uint32) {
+	b := make([]byte, 2*len(vals))
+	for i, v := range vals {
+		fb.bo.PutUint16(b[2*i:], uint16(v))
+	}
+	fb.wi.SetField(tiff.NewField(tag, 3, uint32(len(vals)), b, fb.bo, nil, nil))
+}
+
+func (fb *fieldBuilder) long(tag uint16, vals ...uint32) {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		fb.bo.PutUint32(b[4*i:], v)
+	}
+	fb.wi.SetField(tiff.NewField(tag, 4, uint32(len(vals)), b, fb.bo, nil, nil))
+}
+
+func (fb *fieldBuilder) rational(tag uint16, vals ...*big.Rat) {
+	b := make([]byte, 8*len(vals))
+	for i, v := range vals {
+		num, den := v.Num(), v.Denom()
+		if num.Sign() < 0 || !num.IsUint64() || num.Uint64() > 0xFFFFFFFF || den.Uint64() > 0xFFFFFFFF {
+			if fb.err == nil {
+				fb.err = fmt.Errorf("tiff/image: %v does not fit in a Rational", v)
+			}
+			return
+		}
+		fb.bo.PutUint32(b[8*i:], uint32(num.Uint64()))
+		fb.bo.PutUint32(b[8*i+4:], uint32(den.Uint64()))
+	}
+	fb.wi.SetField(tiff.NewField(tag, 5, uint32(len(vals)), b, fb.bo, nil, nil))
+}
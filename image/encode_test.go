@@ -0,0 +1,366 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+// The test images are 37x29, which is not a multiple of any strip or tile
+// size used below.
+const encW, encH = 37, 29
+
+func encodeTestImages() map[string]image.Image {
+	r := image.Rect(0, 0, encW, encH)
+	gray := image.NewGray(r)
+	gray16 := image.NewGray16(r)
+	rgba := image.NewRGBA(r)
+	nrgba := image.NewNRGBA(r)
+	rgba64 := image.NewRGBA64(r)
+	nrgba64 := image.NewNRGBA64(r)
+	opaque := image.NewRGBA(r)
+	cmyk := image.NewCMYK(r)
+	bw := image.NewPaletted(r, color.Palette{color.Black, color.White})
+	wb := image.NewPaletted(r, color.Palette{color.White, color.Black})
+	pal4 := image.NewPaletted(r, color.Palette{color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}})
+	var p256 color.Palette
+	for i := 0; i < 200; i++ {
+		p256 = append(p256, color.RGBA{uint8(i), uint8(255 - i), uint8(i * 7), 255})
+	}
+	pal8 := image.NewPaletted(r, p256)
+	for y := 0; y < encH; y++ {
+		for x := 0; x < encW; x++ {
+			v := uint8(7*x + 3*y)
+			a := uint8(255 - 4*x)
+			gray.SetGray(x, y, color.Gray{v})
+			gray16.SetGray16(x, y, color.Gray16{uint16(1000*x + 37*y)})
+			rgba.SetRGBA(x, y, color.RGBA{v / 2, uint8(y), uint8(x), 0x80})
+			nrgba.SetNRGBA(x, y, color.NRGBA{v, uint8(y), uint8(x), a})
+			rgba64.SetRGBA64(x, y, color.RGBA64{uint16(x) * 100, uint16(y) * 200, 7, 0x8000})
+			nrgba64.SetNRGBA64(x, y, color.NRGBA64{uint16(x) * 1000, uint16(y) * 2000, 0xffff, uint16(a) << 8})
+			opaque.SetRGBA(x, y, color.RGBA{v, uint8(y), uint8(x), 0xff})
+			cmyk.SetCMYK(x, y, color.CMYK{v, uint8(y), uint8(x), uint8(x + y)})
+			bw.SetColorIndex(x, y, uint8((x+y)%2))
+			wb.SetColorIndex(x, y, uint8(x/3%2))
+			pal4.SetColorIndex(x, y, uint8((x+y)%3))
+			pal8.SetColorIndex(x, y, uint8((x*y)%200))
+		}
+	}
+	return map[string]image.Image{
+		"Gray": gray, "Gray16": gray16,
+		"RGBA": rgba, "NRGBA": nrgba, "RGBA64": rgba64, "NRGBA64": nrgba64,
+		"opaque RGBA": opaque, "CMYK": cmyk,
+		"Paletted 1 bit": bw, "Paletted 1 bit WhiteIsZero": wb,
+		"Paletted 4 bits": pal4, "Paletted 8 bits": pal8,
+		"YCbCr 4:2:0": ycbcrTestImage(image.YCbCrSubsampleRatio420),
+		"YCbCr 4:2:2": ycbcrTestImage(image.YCbCrSubsampleRatio422),
+	}
+}
+
+func ycbcrTestImage(ratio image.YCbCrSubsampleRatio) *image.YCbCr {
+	m := image.NewYCbCr(image.Rect(0, 0, encW, encH), ratio)
+	for i := range m.Y {
+		m.Y[i] = uint8(i * 3)
+	}
+	for i := range m.Cb {
+		m.Cb[i], m.Cr[i] = uint8(i*5), uint8(255-i)
+	}
+	return m
+}
+
+// encodedFields returns the fields of the first IFD of the TIFF b.
+func encodedFields(t *testing.T, b []byte) tiff.IFD {
+	t.Helper()
+	tf, err := tiff.Parse(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tf.IFDs()[0]
+}
+
+// shorts returns the values of the Short field with tagID, or nil if ifd has
+// no such field.
+func shorts(ifd tiff.IFD, tagID uint16) []uint16 {
+	if !ifd.HasField(tagID) {
+		return nil
+	}
+	f := ifd.GetField(tagID)
+	b, bo := f.Value().Bytes(), f.Value().Order()
+	vals := make([]uint16, f.Count())
+	for i := range vals {
+		vals[i] = bo.Uint16(b[2*i:])
+	}
+	return vals
+}
+
+// sameImage reports the first pixel where got and want differ.
+func sameImage(got, want image.Image) error {
+	if got.Bounds() != want.Bounds() {
+		return fmt.Errorf("bounds %v, want %v", got.Bounds(), want.Bounds())
+	}
+	r := want.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			g, w := color.RGBA64Model.Convert(got.At(x, y)), color.RGBA64Model.Convert(want.At(x, y))
+			if g != w {
+				return fmt.Errorf("pixel (%d, %d) is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+	return nil
+}
+
+func TestEncodeTypes(t *testing.T) {
+	images := encodeTestImages()
+	tests := []struct {
+		name        string
+		photometric uint16
+		bps         []uint16
+		extra       []uint16
+		decoded     string
+	}{
+		{"Gray", 1, []uint16{8}, nil, "*image.Gray"},
+		{"Gray16", 1, []uint16{16}, nil, "*image.Gray16"},
+		{"Paletted 1 bit", 1, []uint16{1}, nil, "*image.Gray"},
+		{"Paletted 1 bit WhiteIsZero", 0, []uint16{1}, nil, "*image.Gray"},
+		{"Paletted 4 bits", 3, []uint16{4}, nil, "*image.Paletted"},
+		{"Paletted 8 bits", 3, []uint16{8}, nil, "*image.Paletted"},
+		{"opaque RGBA", 2, []uint16{8, 8, 8}, nil, "*image.RGBA"},
+		{"RGBA", 2, []uint16{8, 8, 8, 8}, []uint16{1}, "*image.RGBA"},
+		{"NRGBA", 2, []uint16{8, 8, 8, 8}, []uint16{2}, "*image.NRGBA"},
+		{"RGBA64", 2, []uint16{16, 16, 16, 16}, []uint16{1}, "*image.RGBA64"},
+		{"NRGBA64", 2, []uint16{16, 16, 16, 16}, []uint16{2}, "*image.NRGBA64"},
+		{"CMYK", 5, []uint16{8, 8, 8, 8}, nil, "*image.CMYK"},
+		{"YCbCr 4:2:0", 6, []uint16{8, 8, 8}, nil, "*image.YCbCr"},
+		{"YCbCr 4:2:2", 6, []uint16{8, 8, 8}, nil, "*image.YCbCr"},
+	}
+	for _, tt := range tests {
+		m := images[tt.name]
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, nil); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		ifd := encodedFields(t, buf.Bytes())
+		if got := shorts(ifd, 262); len(got) != 1 || got[0] != tt.photometric {
+			t.Errorf("%s: PhotometricInterpretation %v, want %d", tt.name, got, tt.photometric)
+		}
+		if got := shorts(ifd, 258); fmt.Sprint(got) != fmt.Sprint(tt.bps) {
+			t.Errorf("%s: BitsPerSample %v, want %v", tt.name, got, tt.bps)
+		}
+		if got := shorts(ifd, 338); fmt.Sprint(got) != fmt.Sprint(tt.extra) {
+			t.Errorf("%s: ExtraSamples %v, want %v", tt.name, got, tt.extra)
+		}
+		got, err := Decode(&buf)
+		if err != nil {
+			t.Errorf("%s: decode: %v", tt.name, err)
+			continue
+		}
+		if typ := fmt.Sprintf("%T", got); typ != tt.decoded {
+			t.Errorf("%s: decoded as %s, want %s", tt.name, typ, tt.decoded)
+		}
+		if err := sameImage(got, m); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestEncodeLayouts(t *testing.T) {
+	images := encodeTestImages()
+	layouts := []struct {
+		name string
+		opts Options
+	}{
+		{"default strips", Options{}},
+		{"strips of 5 rows", Options{RowsPerStrip: 5}},
+		{"strips of 100 rows", Options{RowsPerStrip: 100}},
+		{"16x16 tiles", Options{TileWidth: 16, TileLength: 16}},
+		{"32x16 tiles", Options{TileWidth: 32, TileLength: 16}},
+		{"big endian 48x32 tiles", Options{ByteOrder: binary.BigEndian, TileWidth: 48, TileLength: 32}},
+	}
+	for _, name := range []string{"Gray", "Gray16", "Paletted 1 bit", "Paletted 4 bits", "NRGBA", "RGBA64", "CMYK", "YCbCr 4:2:0", "YCbCr 4:2:2"} {
+		m := images[name]
+		for _, compression := range []uint16{1, 5, 8, 32946, 32773} {
+			for _, predictor := range []uint16{1, 2} {
+				if predictor == 2 && (strings.HasPrefix(name, "Paletted") || strings.HasPrefix(name, "YCbCr")) {
+					continue
+				}
+				for _, l := range layouts {
+					opts := l.opts
+					opts.Compression, opts.Predictor = compression, predictor
+					what := fmt.Sprintf("%s, %s, Compression %d, Predictor %d", name, l.name, compression, predictor)
+					var buf bytes.Buffer
+					if err := Encode(&buf, m, &opts); err != nil {
+						t.Errorf("%s: %v", what, err)
+						continue
+					}
+					got, err := Decode(&buf)
+					if err != nil {
+						t.Errorf("%s: decode: %v", what, err)
+						continue
+					}
+					if err := sameImage(got, m); err != nil {
+						t.Errorf("%s: %v", what, err)
+					}
+				}
+			}
+		}
+	}
+
+	// CCITT is for bilevel images only.
+	for _, compression := range []uint16{2, 3, 4} {
+		for _, l := range layouts {
+			opts := l.opts
+			opts.Compression = compression
+			var buf bytes.Buffer
+			if err := Encode(&buf, images["Paletted 1 bit WhiteIsZero"], &opts); err != nil {
+				t.Errorf("Compression %d, %s: %v", compression, l.name, err)
+				continue
+			}
+			got, err := Decode(&buf)
+			if err == nil {
+				err = sameImage(got, images["Paletted 1 bit WhiteIsZero"])
+			}
+			if err != nil {
+				t.Errorf("Compression %d, %s: %v", compression, l.name, err)
+			}
+		}
+	}
+}
+
+func TestEncodeFields(t *testing.T) {
+	m := encodeTestImages()["Gray"]
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, &Options{RowsPerStrip: 10}); err != nil {
+		t.Fatal(err)
+	}
+	ifd := encodedFields(t, buf.Bytes())
+	if !ifd.HasField(273) || ifd.GetField(273).Count() != 3 || ifd.HasField(324) {
+		t.Error("want 3 strips and no tiles")
+	}
+	if got := shorts(ifd, 296); len(got) != 1 || got[0] != 2 {
+		t.Errorf("ResolutionUnit %v, want 2", got)
+	}
+	if ifd.HasField(317) {
+		t.Error("Predictor 1 was written")
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, m, &Options{TileWidth: 32, TileLength: 16, Predictor: 2}); err != nil {
+		t.Fatal(err)
+	}
+	ifd = encodedFields(t, buf.Bytes())
+	if !ifd.HasField(324) || ifd.GetField(324).Count() != 4 || ifd.HasField(273) {
+		t.Error("want 2x2 tiles and no strips")
+	}
+	if got := shorts(ifd, 317); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Predictor %v, want 2", got)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	images := encodeTestImages()
+	tests := []struct {
+		name string
+		m    image.Image
+		opts Options
+		want string
+	}{
+		{"empty", image.NewGray(image.Rect(0, 0, 0, 5)), Options{}, "invalid dimensions"},
+		{"tiles", images["Gray"], Options{TileWidth: 20, TileLength: 16}, "multiples of 16"},
+		{"compression", images["Gray"], Options{Compression: 99}, "99"},
+		{"predictor for a palette", images["Paletted 4 bits"], Options{Predictor: 2}, "Predictor 2"},
+		{"predictor 3", images["Gray"], Options{Predictor: 3}, "Predictor 3"},
+		{"JPEG with alpha", images["RGBA"], Options{Compression: 7}, "opaque"},
+		{"JPEG of 16 bits", images["Gray16"], Options{Compression: 7}, "8 bits"},
+	}
+	for _, tt := range tests {
+		err := Encode(&bytes.Buffer{}, tt.m, &tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// jpegTestImage returns a gradient that JPEG keeps close to the original.
+func jpegTestImage(color bool) image.Image {
+	if !color {
+		return &image.Gray{Pix: jpegTestChunk(1, encW, encH), Stride: encW, Rect: image.Rect(0, 0, encW, encH)}
+	}
+	m := image.NewRGBA(image.Rect(0, 0, encW, encH))
+	src := jpegTestChunk(3, encW, encH)
+	for i := 0; i < encW*encH; i++ {
+		copy(m.Pix[4*i:], src[3*i:3*i+3])
+		m.Pix[4*i+3] = 0xff
+	}
+	return m
+}
+
+// maxPixelError returns the largest difference between the 8 bit samples of
+// the pixels of a and b.
+func maxPixelError(a, b image.Image) int {
+	worst := 0
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r0, g0, b0, _ := a.At(x, y).RGBA()
+			r1, g1, b1, _ := b.At(x, y).RGBA()
+			if e := maxByteError([]byte{byte(r0 >> 8), byte(g0 >> 8), byte(b0 >> 8)}, []byte{byte(r1 >> 8), byte(g1 >> 8), byte(b1 >> 8)}); e > worst {
+				worst = e
+			}
+		}
+	}
+	return worst
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	// The chunks at the right and bottom edges are padded with the last
+	// column and row, so that they decode as close to the image as the
+	// others.
+	for _, colored := range []bool{false, true} {
+		m := jpegTestImage(colored)
+		maxErr := 4
+		if colored {
+			maxErr = 16
+		}
+		for _, opts := range []Options{
+			{Compression: 7},
+			{Compression: 7, RowsPerStrip: 16},
+			{Compression: 7, TileWidth: 16, TileLength: 16},
+			{Compression: 7, TileWidth: 32, TileLength: 16, ByteOrder: binary.BigEndian},
+		} {
+			what := fmt.Sprintf("color %v, %+v", colored, opts)
+			var buf bytes.Buffer
+			if err := Encode(&buf, m, &opts); err != nil {
+				t.Errorf("%s: %v", what, err)
+				continue
+			}
+			ifd := encodedFields(t, buf.Bytes())
+			if !ifd.HasField(347) {
+				t.Errorf("%s: no JPEGTables", what)
+			}
+			got, err := Decode(&buf)
+			if err != nil {
+				t.Errorf("%s: decode: %v", what, err)
+				continue
+			}
+			if got.Bounds() != m.Bounds() {
+				t.Errorf("%s: bounds %v", what, got.Bounds())
+				continue
+			}
+			if e := maxPixelError(got, m); e > maxErr {
+				t.Errorf("%s: the largest error is %d, want at most %d", what, e, maxErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+// Gray32f is an in-memory image of float32 gray values, for samples that
+// must keep their values (see Sample Format).  Values are only converted to
+// colors by At.
+type Gray32f struct {
+	// Pix holds the value of each pixel.  The pixel at (x, y) is at
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Pix []float32
+	// Stride is the Pix stride (in values) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// Min and Max are the values that At maps to black and white.  When Min
+	// is greater than Max, the image is inverted.
+	Min, Max float64
+}
+
+// NewGray32f returns a new Gray32f image with the given bounds, mapping the
+// values 0 to 1 to colors.
+func NewGray32f(r image.Rectangle) *Gray32f {
+	w, h := r.Dx(), r.Dy()
+	return &Gray32f{Pix: make([]float32, w*h), Stride: w, Rect: r, Max: 1}
+}
+
+func (p *Gray32f) ColorModel() color.Model { return color.Gray16Model }
+
+func (p *Gray32f) Bounds() image.Rectangle { return p.Rect }
+
+func (p *Gray32f) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.Gray16{}
+	}
+	return color.Gray16{scaleValue(float64(p.Pix[p.PixOffset(x, y)]), p.Min, p.Max)}
+}
+
+// Gray32fAt returns the value of the pixel at (x, y).
+func (p *Gray32f) Gray32fAt(x, y int) float32 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return 0
+	}
+	return p.Pix[p.PixOffset(x, y)]
+}
+
+// PixOffset returns the index of the element of Pix that corresponds to the
+// pixel at (x, y).
+func (p *Gray32f) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+func (p *Gray32f) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	g := color.Gray16Model.Convert(c).(color.Gray16)
+	p.Pix[p.PixOffset(x, y)] = float32(p.Min + float64(g.Y)/0xffff*(p.Max-p.Min))
+}
+
+// SetGray32f sets the value of the pixel at (x, y).
+func (p *Gray32f) SetGray32f(x, y int, v float32) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = v
+}
+
+// Bands64f is an in-memory image of any number of float64 samples (bands)
+// per pixel, for samples that must keep their values (see Sample Format).
+// Values are only converted to colors by At: a single band is gray and the
+// first three of more bands are red, green and blue.
+type Bands64f struct {
+	// Pix holds the samples of each pixel.  The samples of the pixel at
+	// (x, y) start at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*N].
+	Pix []float64
+	// N is the number of samples per pixel.
+	N int
+	// Stride is the Pix stride (in values) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// Min and Max hold, for each band, the values that At maps to the
+	// lowest and highest intensity.
+	Min, Max []float64
+}
+
+// NewBands64f returns a new Bands64f image with the given bounds and n bands,
+// mapping the values 0 to 1 to colors.
+func NewBands64f(r image.Rectangle, n int) *Bands64f {
+	w, h := r.Dx(), r.Dy()
+	p := &Bands64f{
+		Pix:    make([]float64, w*h*n),
+		N:      n,
+		Stride: w * n,
+		Rect:   r,
+		Min:    make([]float64, n),
+		Max:    make([]float64, n),
+	}
+	for i := range p.Max {
+		p.Max[i] = 1
+	}
+	return p
+}
+
+func (p *Bands64f) ColorModel() color.Model {
+	if p.N >= 3 {
+		return color.RGBA64Model
+	}
+	return color.Gray16Model
+}
+
+func (p *Bands64f) Bounds() image.Rectangle { return p.Rect }
+
+func (p *Bands64f) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		if p.N >= 3 {
+			return color.RGBA64{}
+		}
+		return color.Gray16{}
+	}
+	s := p.Samples(x, y)
+	if p.N >= 3 {
+		return color.RGBA64{
+			scaleValue(s[0], p.Min[0], p.Max[0]),
+			scaleValue(s[1], p.Min[1], p.Max[1]),
+			scaleValue(s[2], p.Min[2], p.Max[2]),
+			0xffff,
+		}
+	}
+	return color.Gray16{scaleValue(s[0], p.Min[0], p.Max[0])}
+}
+
+// Samples returns the samples of the pixel at (x, y), sharing Pix.
+func (p *Bands64f) Samples(x, y int) []float64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return nil
+	}
+	i := p.PixOffset(x, y)
+	return p.Pix[i : i+p.N : i+p.N]
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds
+// to the pixel at (x, y).
+func (p *Bands64f) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*p.N
+}
+
+// scaleValue maps v from the range min to max to 0 to 0xffff, clamping
+// values outside of it.  NaN maps to 0.
+func scaleValue(v, min, max float64) uint16 {
+	if min == max {
+		return 0
+	}
+	f := (v - min) / (max - min)
+	switch {
+	case f >= 1:
+		return 0xffff
+	case f > 0:
+		return uint16(f*0xffff + 0.5)
+	}
+	return 0
+}
@@ -19,6 +19,31 @@ type Decoder interface {
 	Config() (image.Config, error)
 }
 
+// PlaneDecoder is a Decoder that can also decode the samples of a single plane
+// (i.e. one sample of every pixel) without decoding the others.  With a
+// PlanarConfiguration of 2, only the strips or tiles of that plane are read.
+type PlaneDecoder interface {
+	Decoder
+	// Planes returns the number of samples per pixel.
+	Planes() int
+	// Plane decodes sample i of every pixel into an *image.Gray, or an
+	// *image.Gray16 when there are more than 8 bits per sample.  Samples are
+	// scaled to the full range of the image type, as they are stored.
+	// Samples that keep their values are decoded into a *Gray32f or a
+	// *Bands64f (see Sample Format).
+	Plane(i int) (image.Image, error)
+}
+
+// BandDecoder is a PlaneDecoder that describes the extra samples of each
+// pixel, the samples past its color ones (see Extra Samples).  They can be
+// read with Plane: extra sample i is plane Planes()-len(Extras())+i.
+type BandDecoder interface {
+	PlaneDecoder
+	// Extras returns the ExtraSamples value of each extra sample: 0 for
+	// unspecified data, 1 for associated alpha and 2 for unassociated alpha.
+	Extras() []uint16
+}
+
 type TIFFHandler interface {
 	Decoder(tiff.TIFF) (Decoder, error)
 	CanHandle(tiff.TIFF) bool
@@ -68,7 +93,44 @@ func getDecoder(t tiff.TIFF) (dec Decoder, err error) {
 	return new(BaselineHandler).Decoder(ifd0, t.R())
 }
 
+// getIFDDecoder returns a Decoder for the image of a single IFD, from an
+// alternate handler if there is one and from the baseline handler otherwise.
+func getIFDDecoder(ifd tiff.IFD, br tiff.BReader) (Decoder, error) {
+	if handlr := findAlternateIFDHandler(ifd); handlr != nil {
+		return handlr.Decoder(ifd, br)
+	}
+	if !new(BaselineHandler).CanHandle(ifd) {
+		return nil, fmt.Errorf("tiff/image: no handlers available for this IFD")
+	}
+	return new(BaselineHandler).Decoder(ifd, br)
+}
+
+// DecodeOptions are the decoding parameters.  The zero value decodes the
+// pixels in the order they are stored, like Decode, and only includes full
+// resolution images.
+type DecodeOptions struct {
+	// Orient applies the Orientation field, so that the image is upright.
+	Orient bool
+
+	// Subfiles holds the NewSubfileType bits of the subfiles that DecodeAll
+	// and Pages include besides the full resolution images:
+	// SubfileReduced for thumbnails and other reduced resolution versions,
+	// and SubfileMask for transparency masks.  SubfilePage is ignored, as
+	// pages are always included.
+	Subfiles uint32
+}
+
 func Decode(r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, nil)
+}
+
+func DecodeConfig(r io.Reader) (cfg image.Config, err error) {
+	return DecodeConfigWithOptions(r, nil)
+}
+
+// DecodeWithOptions is like Decode with the decoding parameters opts.  A nil
+// opts uses the defaults.
+func DecodeWithOptions(r io.Reader, opts *DecodeOptions) (img image.Image, err error) {
 	var dec Decoder
 	var t tiff.TIFF
 	if t, err = tiff.Parse(tiff.NewReadAtReadSeeker(r), nil, nil); err != nil {
@@ -77,10 +139,13 @@ func Decode(r io.Reader) (img image.Image, err error) {
 	if dec, err = getDecoder(t); err != nil {
 		return
 	}
-	return dec.Image()
+	return decodeImage(dec, opts)
 }
 
-func DecodeConfig(r io.Reader) (cfg image.Config, err error) {
+// DecodeConfigWithOptions is like DecodeConfig with the decoding parameters
+// opts.  When the orientation is applied, the width and height are those of
+// the upright image.
+func DecodeConfigWithOptions(r io.Reader, opts *DecodeOptions) (cfg image.Config, err error) {
 	var dec Decoder
 	var t tiff.TIFF
 	if t, err = tiff.Parse(tiff.NewReadAtReadSeeker(r), nil, nil); err != nil {
@@ -89,7 +154,32 @@ func DecodeConfig(r io.Reader) (cfg image.Config, err error) {
 	if dec, err = getDecoder(t); err != nil {
 		return
 	}
-	return dec.Config()
+	return decodeConfig(dec, opts)
+}
+
+// decodeImage decodes the image of dec with the decoding parameters opts.
+func decodeImage(dec Decoder, opts *DecodeOptions) (image.Image, error) {
+	img, err := dec.Image()
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Orient {
+		img = Upright(img, orientation(dec))
+	}
+	return img, nil
+}
+
+// decodeConfig returns the configuration of the image of dec with the
+// decoding parameters opts.
+func decodeConfig(dec Decoder, opts *DecodeOptions) (image.Config, error) {
+	cfg, err := dec.Config()
+	if err != nil {
+		return cfg, err
+	}
+	if opts != nil && opts.Orient && orientation(dec) >= 5 {
+		cfg.Width, cfg.Height = cfg.Height, cfg.Width
+	}
+	return cfg, nil
 }
 
 func init() {
@@ -99,4 +189,5 @@ func init() {
 	image.RegisterFormat("bigtiff", bigtiff.MagicLitEndian, Decode, DecodeConfig)
 	image.RegisterFormat("tiff85", tiff85.MagicBigEndian, Decode, DecodeConfig)
 	image.RegisterFormat("tiff85", tiff85.MagicLitEndian, Decode, DecodeConfig)
+	tiff.RegisterPayloadDecoder(decodePayload)
 }
@@ -0,0 +1,302 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/big"
+
+	"github.com/google/tiff"
+)
+
+/* L*a*b* Images
+
+Tag 262 (PhotometricInterpretation)
+	8 = CIELab: L* is unsigned and a* and b* are signed (two's complement).
+	9 = ICCLab: L* is unsigned and a* and b* are unsigned with an offset of
+		128 (or 32768 for 16 bits), as in ICC profiles.
+	10 = ITULab: every sample is unsigned and mapped to its range by Decode
+		(ITU-T T.42).
+
+Tag 277 (SamplesPerPixel)
+	1 = L* only
+	3 = L*, a* and b*
+
+Tag 258 (BitsPerSample)
+	8 or 16 for every sample.
+
+Tag 318 (WhitePoint)
+	Type: Rational
+	Note: The chromaticity x and y of the reference white of the colors.
+		Default is D65 (0.3127, 0.3290) for CIELab, and D50 (0.3457,
+		0.3585) for ICCLab and ITULab, which are defined relative to it.
+
+Tag 433 (Decode)
+	Type: SRational
+	Note: For ITULab, the smallest and largest value of L*, then of a*, then
+		of b*, mapped to the smallest and largest sample values.  Default
+		is 0, 100, -85, 85, -75, 125.
+
+Encodings
+	CIELab   L* = v * 100 / (2**n - 1)     a* = signed v (/ 256 for 16 bits)
+	ICCLab   L* = v * 100 / (2**n - 1)     a* = v * 255 / (2**n - 1) - 128
+	ITULab   L* = min + v * (max - min) / (2**n - 1), and so on for a* and b*
+
+Notes
+	The colors are decoded into a *Lab, holding L*, a* and b* with the
+	reference white.  At converts them to sRGB (see LabToSRGB).
+*/
+
+// Standard illuminants, as chromaticity x and y.
+var (
+	whiteD50 = [2]float64{0.3457, 0.3585}
+	whiteD65 = [2]float64{0.3127, 0.3290}
+)
+
+// Lab is an in-memory image of CIE L*a*b* colors.  At converts them to sRGB
+// with LabToSRGB.
+type Lab struct {
+	// Pix holds L*, a* and b* of each pixel.  The pixel at (x, y) starts at
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*3].
+	Pix []float32
+	// Stride is the Pix stride (in values) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// WhitePoint is the chromaticity x and y of the reference white.
+	WhitePoint [2]float64
+}
+
+// NewLab returns a new Lab image with the given bounds, relative to D65.
+func NewLab(r image.Rectangle) *Lab {
+	w, h := r.Dx(), r.Dy()
+	return &Lab{Pix: make([]float32, 3*w*h), Stride: 3 * w, Rect: r, WhitePoint: whiteD65}
+}
+
+func (p *Lab) ColorModel() color.Model { return color.RGBA64Model }
+
+func (p *Lab) Bounds() image.Rectangle { return p.Rect }
+
+func (p *Lab) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	r, g, b := LabToSRGB(p.LabAt(x, y))
+	return color.RGBA64{
+		uint16(r*0xffff + 0.5),
+		uint16(g*0xffff + 0.5),
+		uint16(b*0xffff + 0.5),
+		0xffff,
+	}
+}
+
+// LabAt returns L*, a* and b* of the pixel at (x, y) and the reference white.
+func (p *Lab) LabAt(x, y int) (l, a, b float64, white [2]float64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return 0, 0, 0, p.WhitePoint
+	}
+	i := p.PixOffset(x, y)
+	return float64(p.Pix[i]), float64(p.Pix[i+1]), float64(p.Pix[i+2]), p.WhitePoint
+}
+
+// SetLab sets L*, a* and b* of the pixel at (x, y).
+func (p *Lab) SetLab(x, y int, l, a, b float64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i], p.Pix[i+1], p.Pix[i+2] = float32(l), float32(a), float32(b)
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds
+// to the pixel at (x, y).
+func (p *Lab) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*3
+}
+
+// LabToSRGB converts L*, a* and b* relative to the reference white with the
+// chromaticity white to sRGB components from 0 to 1.  The color is converted
+// to CIE XYZ, adapted to D65 with the Bradford transform when white is
+// another white, and converted to sRGB (IEC 61966-2-1), clamping the
+// components that are out of gamut.
+func LabToSRGB(l, a, b float64, white [2]float64) (r, g, bl float64) {
+	f := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	fy := (l + 16) / 116
+	wx, wy, wz := whiteXYZ(white)
+	x, y, z := wx*f(fy+a/500), wy*f(fy), wz*f(fy-b/200)
+	if white != whiteD65 {
+		x, y, z = adapt(x, y, z, white, whiteD65)
+	}
+	gamma := func(v float64) float64 {
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		return math.Max(0, math.Min(1, v))
+	}
+	r = gamma(3.2404542*x - 1.5371385*y - 0.4985314*z)
+	g = gamma(-0.9692660*x + 1.8760108*y + 0.0415560*z)
+	bl = gamma(0.0556434*x - 0.2040259*y + 1.0572252*z)
+	return r, g, bl
+}
+
+// whiteXYZ returns the XYZ of the white with chromaticity white and Y of 1.
+func whiteXYZ(white [2]float64) (x, y, z float64) {
+	return white[0] / white[1], 1, (1 - white[0] - white[1]) / white[1]
+}
+
+// adapt adapts the color x, y, z from the white from to the white to with
+// the Bradford transform.
+func adapt(x, y, z float64, from, to [2]float64) (float64, float64, float64) {
+	bradford := [3][3]float64{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+	inverse := [3][3]float64{
+		{0.9869929, -0.1470543, 0.1599627},
+		{0.4323053, 0.5183603, 0.0492912},
+		{-0.0085287, 0.0400428, 0.9684867},
+	}
+	mul := func(m [3][3]float64, v [3]float64) [3]float64 {
+		var out [3]float64
+		for i := range m {
+			out[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+		}
+		return out
+	}
+	sx, sy, sz := whiteXYZ(from)
+	dx, dy, dz := whiteXYZ(to)
+	src := mul(bradford, [3]float64{sx, sy, sz})
+	dst := mul(bradford, [3]float64{dx, dy, dz})
+	cone := mul(bradford, [3]float64{x, y, z})
+	for i := range cone {
+		cone[i] *= dst[i] / src[i]
+	}
+	v := mul(inverse, cone)
+	return v[0], v[1], v[2]
+}
+
+type labDecoder struct {
+	grayscaleDecoder `tiff:"ifd"`
+	SamplesPerPixel  uint16     `tiff:"field,tag=277"`
+	WhitePoint       []*big.Rat `tiff:"field,tag=318"`
+	Decode           []*big.Rat `tiff:"field,tag=433"`
+}
+
+// whitePoint returns the reference white of the colors.
+func (ld *labDecoder) whitePoint() [2]float64 {
+	if len(ld.WhitePoint) == 2 {
+		x, _ := ld.WhitePoint[0].Float64()
+		y, _ := ld.WhitePoint[1].Float64()
+		if y > 0 {
+			return [2]float64{x, y}
+		}
+	}
+	if ld.PhotometricInterpretation == 8 {
+		return whiteD65
+	}
+	return whiteD50
+}
+
+// ranges returns the values of L*, a* and b* for the smallest and largest
+// samples of ITULab.
+func (ld *labDecoder) ranges() [6]float64 {
+	rng := [6]float64{0, 100, -85, 85, -75, 125}
+	if len(ld.Decode) == 6 {
+		for i, v := range ld.Decode {
+			rng[i], _ = v.Float64()
+		}
+	}
+	return rng
+}
+
+func (ld *labDecoder) Image() (image.Image, error) {
+	if ld.img == nil {
+		r, err := newRaster(&ld.bilevelDecoder, ld.SamplesPerPixel, ld.BitsPerSample)
+		if err != nil {
+			return nil, err
+		}
+		if r.samplesPerPixel != 1 && r.samplesPerPixel < 3 {
+			return nil, fmt.Errorf("tiff/image: unsupported SamplesPerPixel value for L*a*b*: %d", r.samplesPerPixel)
+		}
+		if r.bitsPerSample != 8 && r.bitsPerSample != 16 || r.keepsValues() {
+			return nil, fmt.Errorf("tiff/image: unsupported BitsPerSample value for L*a*b*: %d", r.bitsPerSample)
+		}
+		data, err := r.decode()
+		if err != nil {
+			return nil, err
+		}
+		img := NewLab(image.Rect(0, 0, r.width, r.height))
+		img.WhitePoint = ld.whitePoint()
+		rb := r.rowBytes()
+		max := float64(r.maxValue())
+		rng := ld.ranges()
+		for y := 0; y < r.height; y++ {
+			row := data[y*rb : (y+1)*rb]
+			for x := 0; x < r.width; x++ {
+				var s [3]float64
+				for c := 0; c < 3 && c < r.samplesPerPixel; c++ {
+					s[c] = float64(r.sample(row, x*r.samplesPerPixel+c))
+				}
+				var l, a, b float64
+				switch ld.PhotometricInterpretation {
+				case 8:
+					l = s[0] * 100 / max
+					if r.samplesPerPixel >= 3 {
+						a, b = float64(int8(s[1])), float64(int8(s[2]))
+						if r.bitsPerSample == 16 {
+							a, b = float64(int16(s[1]))/256, float64(int16(s[2]))/256
+						}
+					}
+				case 9:
+					l = s[0] * 100 / max
+					if r.samplesPerPixel >= 3 {
+						a, b = s[1]*255/max-128, s[2]*255/max-128
+					}
+				default:
+					l = rng[0] + s[0]*(rng[1]-rng[0])/max
+					if r.samplesPerPixel >= 3 {
+						a = rng[2] + s[1]*(rng[3]-rng[2])/max
+						b = rng[4] + s[2]*(rng[5]-rng[4])/max
+					}
+				}
+				img.SetLab(x, y, l, a, b)
+			}
+		}
+		ld.img = img
+	}
+	return ld.img, nil
+}
+
+func (ld *labDecoder) Config() (cfg image.Config, err error) {
+	cfg.Height = int(ld.ImageLength)
+	cfg.Width = int(ld.ImageWidth)
+	cfg.ColorModel = color.RGBA64Model
+	return
+}
+
+type CIELab struct{}
+
+func (CIELab) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
+	labDec := &labDecoder{grayscaleDecoder: grayscaleDecoder{bilevelDecoder: bilevelDecoder{br: br}}}
+	if err = tiff.UnmarshalIFD(ifd, labDec); err != nil {
+		return
+	}
+	return labDec, nil
+}
+
+func (CIELab) CanHandle(ifd tiff.IFD) bool {
+	return new(Grayscale).CanHandle(ifd)
+}
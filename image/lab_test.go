@@ -0,0 +1,163 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestLabToSRGB(t *testing.T) {
+	// The L*a*b* values of the sRGB colors are those of
+	// http://www.brucelindbloom.com, for D65 and, adapted with Bradford,
+	// for D50.
+	tests := []struct {
+		name    string
+		l, a, b float64
+		white   [2]float64
+		want    [3]int
+	}{
+		{"white", 100, 0, 0, whiteD65, [3]int{255, 255, 255}},
+		{"black", 0, 0, 0, whiteD65, [3]int{0, 0, 0}},
+		{"gray", 50, 0, 0, whiteD65, [3]int{119, 119, 119}},
+		{"red", 53.2408, 80.0925, 67.2032, whiteD65, [3]int{255, 0, 0}},
+		{"green", 87.7347, -86.1827, 83.1793, whiteD65, [3]int{0, 255, 0}},
+		{"blue", 32.2970, 79.1875, -107.8602, whiteD65, [3]int{0, 0, 255}},
+		{"white of D50", 100, 0, 0, whiteD50, [3]int{255, 255, 255}},
+		{"gray of D50", 50, 0, 0, whiteD50, [3]int{119, 119, 119}},
+		{"red of D50", 54.2917, 80.8125, 69.8851, whiteD50, [3]int{255, 0, 0}},
+		{"blue of D50", 29.5676, 68.2986, -112.0294, whiteD50, [3]int{0, 0, 255}},
+		{"brighter than white", 120, 0, 0, whiteD65, [3]int{255, 255, 255}},
+	}
+	for _, tt := range tests {
+		r, g, b := LabToSRGB(tt.l, tt.a, tt.b, tt.white)
+		got := [3]float64{r, g, b}
+		for i := range got {
+			if math.Abs(got[i]*255-float64(tt.want[i])) > 1 {
+				t.Errorf("%s: got %.1f, want %v", tt.name, [3]float64{r * 255, g * 255, b * 255}, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// srational sets the SRational field tagID of ti to the num/den pairs vals.
+func srational(ti *testIFD, tagID uint16, vals ...int32) *testIFD {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		ti.bo.PutUint32(b[4*i:], uint32(v))
+	}
+	return ti.field(tagID, 10, uint32(len(vals)/2), b)
+}
+
+func TestLabEncodings(t *testing.T) {
+	type lab [3]float64
+	tests := []struct {
+		name        string
+		photometric uint32
+		bps         uint32
+		spp         int
+		fields      func(ti *testIFD) *testIFD
+		data        func(bo binary.ByteOrder) []byte
+		want        []lab
+		white       [2]float64
+	}{
+		{"CIELab 8 bits", 8, 8, 3, nil,
+			func(bo binary.ByteOrder) []byte { return []byte{255, 0x80, 0x7f, 0, 0xff, 0x01} },
+			[]lab{{100, -128, 127}, {0, -1, 1}}, whiteD65},
+		{"CIELab 16 bits", 8, 16, 3, nil,
+			func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 2, 0xffff, 0xff00, 0x0180) },
+			[]lab{{100, -1, 1.5}}, whiteD65},
+		{"CIELab with WhitePoint", 8, 8, 3,
+			func(ti *testIFD) *testIFD { return ti.rational(318, 3457, 10000, 3585, 10000) },
+			func(bo binary.ByteOrder) []byte { return []byte{255, 0, 0} },
+			[]lab{{100, 0, 0}}, whiteD50},
+		{"CIELab L* only", 8, 8, 1, nil,
+			func(bo binary.ByteOrder) []byte { return []byte{255, 0} },
+			[]lab{{100, 0, 0}, {0, 0, 0}}, whiteD65},
+		{"ICCLab 8 bits", 9, 8, 3, nil,
+			func(bo binary.ByteOrder) []byte { return []byte{255, 128, 0, 0, 255, 128} },
+			[]lab{{100, 0, -128}, {0, 127, 0}}, whiteD50},
+		{"ICCLab 16 bits", 9, 16, 3, nil,
+			func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 2, 0xffff, 0x8080, 0) },
+			[]lab{{100, 0, -128}}, whiteD50},
+		{"ICCLab with WhitePoint", 9, 8, 3,
+			func(ti *testIFD) *testIFD { return ti.rational(318, 3127, 10000, 3290, 10000) },
+			func(bo binary.ByteOrder) []byte { return []byte{255, 128, 128} },
+			[]lab{{100, 0, 0}}, whiteD65},
+		{"ITULab 8 bits", 10, 8, 3, nil,
+			func(bo binary.ByteOrder) []byte { return []byte{255, 0, 255, 0, 255, 0} },
+			[]lab{{100, -85, 125}, {0, 85, -75}}, whiteD50},
+		{"ITULab 16 bits", 10, 16, 3, nil,
+			func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 2, 0, 0xffff, 0) },
+			[]lab{{0, 85, -75}}, whiteD50},
+		{"ITULab with Decode", 10, 8, 3,
+			func(ti *testIFD) *testIFD { return srational(ti, 433, 0, 1, 100, 1, -128, 1, 127, 1, -128, 1, 127, 1) },
+			func(bo binary.ByteOrder) []byte { return []byte{255, 0, 255} },
+			[]lab{{100, -128, 127}}, whiteD50},
+		{"ITULab with WhitePoint", 10, 8, 3,
+			func(ti *testIFD) *testIFD { return ti.rational(318, 3127, 10000, 3290, 10000) },
+			func(bo binary.ByteOrder) []byte { return []byte{0, 0, 0} },
+			[]lab{{0, -85, -75}}, whiteD65},
+	}
+	for _, tt := range tests {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			name := fmt.Sprintf("%s, %v", tt.name, bo)
+			bps := []uint32{tt.bps, tt.bps, tt.bps}[:tt.spp]
+			ti := newTestIFD(bo).image(uint32(len(tt.want)), 1, tt.photometric, bps...)
+			if tt.fields != nil {
+				ti = tt.fields(ti)
+			}
+			m, err := Decode(bytes.NewReader(testTIFF(t, ti.strips(1, tt.data(bo)))))
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			img, ok := m.(*Lab)
+			if !ok {
+				t.Errorf("%s: decoded as %T, want *Lab", name, m)
+				continue
+			}
+			if img.WhitePoint != tt.white {
+				t.Errorf("%s: WhitePoint %v, want %v", name, img.WhitePoint, tt.white)
+			}
+			for x, want := range tt.want {
+				l, a, b, _ := img.LabAt(x, 0)
+				if got := (lab{l, a, b}); math.Abs(got[0]-want[0]) > 1e-4 || math.Abs(got[1]-want[1]) > 1e-4 || math.Abs(got[2]-want[2]) > 1e-4 {
+					t.Errorf("%s: pixel %d is %v, want %v", name, x, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestLabAt(t *testing.T) {
+	// L*a*b* of sRGB red, relative to D65 with CIELab and to D50 with
+	// ICCLab: 53.24, 80.09, 67.20 and 54.29, 80.81, 69.89, as 16 bit
+	// samples.
+	tests := []struct {
+		name string
+		ti   *testIFD
+	}{
+		{"CIELab", newTestIFD(binary.BigEndian).image(1, 1, 8, 16, 16, 16).
+			strips(1, sampleBytes(binary.BigEndian, 2, 34891, 20503, 17203))},
+		{"ICCLab", newTestIFD(binary.LittleEndian).image(1, 1, 9, 16, 16, 16).
+			strips(1, sampleBytes(binary.LittleEndian, 2, 35579, 53664, 50858))},
+	}
+	for _, tt := range tests {
+		m, err := Decode(bytes.NewReader(testTIFF(t, tt.ti)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		r, g, b, a := m.At(0, 0).RGBA()
+		if r < 0xfe00 || g > 0x0200 || b > 0x0200 || a != 0xffff {
+			t.Errorf("%s: got %04x %04x %04x %04x, want red", tt.name, r, g, b, a)
+		}
+	}
+}
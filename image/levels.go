@@ -0,0 +1,154 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/google/tiff"
+)
+
+/* Resolution Levels
+
+Tag 330 (SubIFDs)
+	Type: Long or IFD (Long8 or IFD8 in BigTIFF)
+	Note: The offsets of child IFDs, which are not part of the main chain of
+		IFDs.  Reduced resolution versions of an image are often stored
+		there (NewSubfileType bit 0).
+
+Notes
+	A page may be stored in several resolution levels, as a pyramid.  Level 0
+	is the page itself, and the other levels are its reduced resolution
+	versions (NewSubfileType bit 0 without bit 2), found in two places:
+	- its SubIFDs, as in DNG and some whole-slide images.
+	- the IFDs that directly follow it in the main chain, up to the next
+	  full resolution image that is not a mask, as GDAL overviews and Cloud
+	  Optimized GeoTIFFs are stored.
+	The levels are sorted from the largest to the smallest.  Only their IFDs
+	are read to list them, and decoding a level only reads its own strips or
+	tiles, so a small level of a large file is cheap to decode.
+*/
+
+// Level is a resolution level of a page.
+type Level struct {
+	// IFD is the IFD of the level.
+	IFD tiff.IFD
+	// Width and Height are the dimensions of the level, as it is decoded.
+	Width, Height int
+}
+
+// levelFields holds the fields that describe a level.
+type levelFields struct {
+	subfile     `tiff:"ifd"`
+	ImageWidth  uint32 `tiff:"field,tag=256"`
+	ImageLength uint32 `tiff:"field,tag=257"`
+	Orientation uint16 `tiff:"field,tag=274"`
+}
+
+// isReduced reports whether lf is a reduced resolution version of an image.
+func (lf *levelFields) isReduced() bool {
+	return lf.kind()&(SubfileReduced|SubfileMask) == SubfileReduced
+}
+
+// level returns the level with the IFD ifd and the fields lf.
+func (p *Page) level(ifd tiff.IFD, lf *levelFields) Level {
+	l := Level{IFD: ifd, Width: int(lf.ImageWidth), Height: int(lf.ImageLength)}
+	if p.opts.Orient && lf.Orientation >= 5 && lf.Orientation <= 8 {
+		l.Width, l.Height = l.Height, l.Width
+	}
+	return l
+}
+
+// Levels returns the resolution levels of the page, from the largest to the
+// smallest (see Resolution Levels).  Level 0 is the page itself.
+func (p *Page) Levels() ([]Level, error) {
+	if p.levels != nil {
+		return p.levels, nil
+	}
+	var lf levelFields
+	if err := tiff.UnmarshalIFD(p.IFD, &lf); err != nil {
+		return nil, fmt.Errorf("tiff/image: page %d: %v", p.Index, err)
+	}
+	levels := []Level{p.level(p.IFD, &lf)}
+	subs, err := tiff.ParseSubIFDs(p.t, p.IFD, 330)
+	if err != nil {
+		return nil, err
+	}
+	for _, ifd := range subs {
+		var lf levelFields
+		if err := tiff.UnmarshalIFD(ifd, &lf); err != nil {
+			return nil, fmt.Errorf("tiff/image: page %d: SubIFD: %v", p.Index, err)
+		}
+		if lf.isReduced() {
+			levels = append(levels, p.level(ifd, &lf))
+		}
+	}
+	if !lf.isReduced() {
+		for _, ifd := range p.t.IFDs()[p.Index+1:] {
+			var lf levelFields
+			if err := tiff.UnmarshalIFD(ifd, &lf); err != nil {
+				return nil, fmt.Errorf("tiff/image: page %d: %v", p.Index, err)
+			}
+			if lf.kind()&(SubfileReduced|SubfileMask) == 0 {
+				break
+			}
+			if lf.isReduced() {
+				levels = append(levels, p.level(ifd, &lf))
+			}
+		}
+	}
+	sort.SliceStable(levels[1:], func(i, j int) bool {
+		a, b := levels[1+i], levels[1+j]
+		return a.Width*a.Height > b.Width*b.Height
+	})
+	p.levels = levels
+	return levels, nil
+}
+
+// LevelFor returns the index of the smallest level that is at least width by
+// height, or of level 0 when none is.
+func (p *Page) LevelFor(width, height int) (int, error) {
+	levels, err := p.Levels()
+	if err != nil {
+		return 0, err
+	}
+	best := 0
+	for i, l := range levels {
+		if l.Width >= width && l.Height >= height && l.Width*l.Height < levels[best].Width*levels[best].Height {
+			best = i
+		}
+	}
+	return best, nil
+}
+
+// LevelDecoder returns the Decoder of level i of the page.
+func (p *Page) LevelDecoder(i int) (Decoder, error) {
+	if i == 0 {
+		return p.Decoder()
+	}
+	levels, err := p.Levels()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(levels) {
+		return nil, fmt.Errorf("tiff/image: level %d out of range (%d levels)", i, len(levels))
+	}
+	dec, err := getIFDDecoder(levels[i].IFD, p.t.R())
+	if err != nil {
+		return nil, fmt.Errorf("tiff/image: page %d: level %d: %v", p.Index, i, err)
+	}
+	return dec, nil
+}
+
+// DecodeLevel decodes the image of level i of the page.
+func (p *Page) DecodeLevel(i int) (image.Image, error) {
+	dec, err := p.LevelDecoder(i)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(dec, p.opts)
+}
@@ -0,0 +1,206 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+// subIFDs sets the SubIFDs of ti.
+func (ti *testIFD) subIFDs(subs ...*testIFD) *testIFD {
+	if ti.wi.SubIFDs == nil {
+		ti.wi.SubIFDs = make(map[uint16][]*tiff.WritableIFD)
+	}
+	for _, sub := range subs {
+		ti.wi.SubIFDs[330] = append(ti.wi.SubIFDs[330], &sub.wi)
+	}
+	return ti
+}
+
+// levelIFD returns a gray w by h image whose pixels are all v, with the
+// NewSubfileType kind.
+func levelIFD(bo binary.ByteOrder, w, h int, v byte, kind uint32) *testIFD {
+	return newTestIFD(bo).image(uint32(w), uint32(h), 1, 8).long(254, kind).strips(uint32(h), bytes.Repeat([]byte{v}, w*h))
+}
+
+// levelSizes returns the sizes of levels as "WxH".
+func levelSizes(levels []Level) string {
+	var s []string
+	for _, l := range levels {
+		s = append(s, fmt.Sprintf("%dx%d", l.Width, l.Height))
+	}
+	return fmt.Sprint(s)
+}
+
+// allPages returns the pages of the TIFF b with the decoding parameters opts.
+func allPages(t *testing.T, b []byte, opts *DecodeOptions) []*Page {
+	t.Helper()
+	it, err := Pages(bytes.NewReader(b), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pages []*Page
+	for it.Next() {
+		pages = append(pages, it.Page())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return pages
+}
+
+// checkLevels checks the sizes of the levels of p and that each level decodes
+// into an image of that size whose pixels are the values in want.
+func checkLevels(t *testing.T, name string, p *Page, sizes string, want []byte) {
+	t.Helper()
+	levels, err := p.Levels()
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	if got := levelSizes(levels); got != sizes {
+		t.Errorf("%s: levels %s, want %s", name, got, sizes)
+		return
+	}
+	for i, l := range levels {
+		m, err := p.DecodeLevel(i)
+		if err != nil {
+			t.Errorf("%s: level %d: %v", name, i, err)
+			continue
+		}
+		g := m.(*image.Gray)
+		if g.Rect != image.Rect(0, 0, l.Width, l.Height) || g.Pix[0] != want[i] {
+			t.Errorf("%s: level %d is %v of %d, want %dx%d of %d", name, i, g.Rect, g.Pix[0], l.Width, l.Height, want[i])
+		}
+	}
+}
+
+func TestLevelsSubIFDs(t *testing.T) {
+	for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		// The masks and full resolution images among the SubIFDs are not
+		// levels.
+		page := levelIFD(bo, 64, 48, 1, 0).subIFDs(
+			levelIFD(bo, 16, 12, 3, SubfileReduced),
+			levelIFD(bo, 32, 24, 2, SubfileReduced),
+			levelIFD(bo, 32, 24, 9, SubfileReduced|SubfileMask),
+			levelIFD(bo, 8, 6, 9, 0),
+			levelIFD(bo, 64, 48, 9, SubfileMask),
+		)
+		b := testTIFF(t, page, levelIFD(bo, 10, 10, 5, 0))
+		pages := allPages(t, b, nil)
+		if len(pages) != 2 {
+			t.Fatalf("%v: got %d pages, want 2", bo, len(pages))
+		}
+		checkLevels(t, fmt.Sprintf("%v: SubIFDs", bo), pages[0], "[64x48 32x24 16x12]", []byte{1, 2, 3})
+		checkLevels(t, fmt.Sprintf("%v: no levels", bo), pages[1], "[10x10]", []byte{5})
+	}
+}
+
+func TestLevelsChain(t *testing.T) {
+	for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		// Cloud Optimized GeoTIFF style: the overviews and their masks
+		// follow the full resolution image, up to the next page.
+		b := testTIFF(t,
+			levelIFD(bo, 64, 64, 1, 0),
+			levelIFD(bo, 64, 64, 9, SubfileMask),
+			levelIFD(bo, 16, 16, 3, SubfileReduced),
+			levelIFD(bo, 16, 16, 9, SubfileReduced|SubfileMask),
+			levelIFD(bo, 32, 32, 2, SubfileReduced),
+			levelIFD(bo, 8, 8, 4, SubfileReduced),
+			levelIFD(bo, 40, 40, 5, SubfilePage),
+			levelIFD(bo, 20, 20, 6, SubfileReduced),
+			levelIFD(bo, 30, 30, 7, 0),
+		)
+		pages := allPages(t, b, nil)
+		if len(pages) != 3 {
+			t.Fatalf("%v: got %d pages, want 3", bo, len(pages))
+		}
+		checkLevels(t, fmt.Sprintf("%v: first page", bo), pages[0], "[64x64 32x32 16x16 8x8]", []byte{1, 2, 3, 4})
+		checkLevels(t, fmt.Sprintf("%v: second page", bo), pages[1], "[40x40 20x20]", []byte{5, 6})
+		checkLevels(t, fmt.Sprintf("%v: last page", bo), pages[2], "[30x30]", []byte{7})
+
+		// A reduced resolution subfile that is a page of its own has no
+		// levels in the chain.
+		pages = allPages(t, b, &DecodeOptions{Subfiles: SubfileReduced})
+		if len(pages) != 7 || pages[2].Index != 4 {
+			t.Fatalf("%v: got %d pages, want 7", bo, len(pages))
+		}
+		checkLevels(t, fmt.Sprintf("%v: reduced page", bo), pages[2], "[32x32]", []byte{2})
+	}
+}
+
+func TestLevelsBoth(t *testing.T) {
+	// The levels of both places are sorted together, keeping their order
+	// when they have the same size.
+	bo := binary.BigEndian
+	b := testTIFF(t,
+		levelIFD(bo, 64, 64, 1, 0).subIFDs(levelIFD(bo, 16, 16, 3, SubfileReduced), levelIFD(bo, 32, 32, 2, SubfileReduced)),
+		levelIFD(bo, 8, 8, 5, SubfileReduced),
+		levelIFD(bo, 32, 32, 4, SubfileReduced),
+	)
+	checkLevels(t, "SubIFDs and chain", allPages(t, b, nil)[0], "[64x64 32x32 32x32 16x16 8x8]", []byte{1, 2, 4, 3, 5})
+}
+
+func TestLevelsOrientation(t *testing.T) {
+	bo := binary.LittleEndian
+	b := testTIFF(t,
+		levelIFD(bo, 64, 32, 1, 0).short(274, 6).subIFDs(levelIFD(bo, 32, 16, 2, SubfileReduced).short(274, 6)),
+	)
+	checkLevels(t, "stored", allPages(t, b, nil)[0], "[64x32 32x16]", []byte{1, 2})
+	checkLevels(t, "upright", allPages(t, b, &DecodeOptions{Orient: true})[0], "[32x64 16x32]", []byte{1, 2})
+}
+
+func TestLevelFor(t *testing.T) {
+	bo := binary.BigEndian
+	b := testTIFF(t,
+		levelIFD(bo, 64, 48, 1, 0),
+		levelIFD(bo, 16, 12, 3, SubfileReduced),
+		levelIFD(bo, 32, 24, 2, SubfileReduced),
+	)
+	p := allPages(t, b, nil)[0]
+	tests := []struct {
+		width, height, want int
+	}{
+		{1, 1, 2},
+		{16, 12, 2},
+		{17, 12, 1},
+		{16, 13, 1},
+		{32, 24, 1},
+		{20, 20, 1},
+		{33, 1, 0},
+		{64, 48, 0},
+		{100, 100, 0},
+	}
+	for _, tt := range tests {
+		got, err := p.LevelFor(tt.width, tt.height)
+		if err != nil || got != tt.want {
+			t.Errorf("LevelFor(%d, %d) = %d, %v, want %d", tt.width, tt.height, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeLevelErrors(t *testing.T) {
+	bo := binary.BigEndian
+	b := testTIFF(t, levelIFD(bo, 8, 8, 1, 0), levelIFD(bo, 4, 4, 2, SubfileReduced))
+	p := allPages(t, b, nil)[0]
+	for _, i := range []int{-1, 2, 100} {
+		if _, err := p.DecodeLevel(i); err == nil {
+			t.Errorf("DecodeLevel(%d) decoded a level", i)
+		}
+	}
+	dec, err := p.LevelDecoder(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd, _ := p.Decoder(); dec != pd {
+		t.Error("LevelDecoder(0) is not the Decoder of the page")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"image"
+	"image/draw"
+)
+
+/* Orientation
+
+Tag 274 (Orientation)
+	1 = The 0th row is the visual top and the 0th column the visual left side
+		(default).
+	2 = The 0th row is the visual top and the 0th column the visual right side.
+	3 = The 0th row is the visual bottom and the 0th column the visual right
+		side.
+	4 = The 0th row is the visual bottom and the 0th column the visual left
+		side.
+	5 = The 0th row is the visual left side and the 0th column the visual top.
+	6 = The 0th row is the visual right side and the 0th column the visual top.
+	7 = The 0th row is the visual right side and the 0th column the visual
+		bottom.
+	8 = The 0th row is the visual left side and the 0th column the visual
+		bottom.
+
+Notes
+	Decode and DecodeConfig return the pixels in the order they are stored.
+	DecodeWithOptions and DecodeConfigWithOptions can apply the orientation
+	instead, so that the image is upright: 2 and 4 are mirrored, 3 is
+	rotated by 180 degrees, 6 and 8 are rotated by 90 degrees clockwise and
+	counterclockwise, and 5 and 7 are transposed along either diagonal, which
+	swaps the width and height.
+
+	Upright turns a decoded image upright.  The standard image types and the
+	types of this package keep their type.  An *image.YCbCr, whose chroma
+	may be subsampled, becomes an *image.RGBA, and any other image an
+	*image.RGBA64.
+*/
+
+// OrientedDecoder is a Decoder that knows the orientation of its image.
+type OrientedDecoder interface {
+	Decoder
+	// Orient returns the Orientation value, 1 to 8.
+	Orient() uint16
+}
+
+// Orient returns the Orientation value, which is 1 when it is absent or
+// invalid.
+func (bld *bilevelDecoder) Orient() uint16 {
+	if bld.Orientation < 1 || bld.Orientation > 8 {
+		return 1
+	}
+	return bld.Orientation
+}
+
+// orientation returns the orientation of the image of dec, which is 1 when
+// dec is not an OrientedDecoder.
+func orientation(dec Decoder) uint16 {
+	if od, ok := dec.(OrientedDecoder); ok {
+		return od.Orient()
+	}
+	return 1
+}
+
+// Upright returns the image m, stored with the Orientation value o, turned
+// upright (see Orientation).  The returned image starts at the origin.  When
+// o is 1, or not an Orientation value, m itself is returned.
+func Upright(m image.Image, o uint16) image.Image {
+	if o < 2 || o > 8 {
+		return m
+	}
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	r := image.Rect(0, 0, w, h)
+	if o >= 5 {
+		r = image.Rect(0, 0, h, w)
+	}
+	// each calls f with the offset of every pixel of m and of the same pixel
+	// in the upright image.
+	each := func(src func(x, y int) int, dst func(x, y int) int, f func(s, d int)) {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				dx, dy := orientPoint(o, x, y, w, h)
+				f(src(b.Min.X+x, b.Min.Y+y), dst(dx, dy))
+			}
+		}
+	}
+	switch m := m.(type) {
+	case *image.Gray:
+		img := image.NewGray(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+1], m.Pix[s:]) })
+		return img
+	case *image.Gray16:
+		img := image.NewGray16(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+2], m.Pix[s:]) })
+		return img
+	case *image.Alpha:
+		img := image.NewAlpha(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+1], m.Pix[s:]) })
+		return img
+	case *image.Paletted:
+		img := image.NewPaletted(r, m.Palette)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+1], m.Pix[s:]) })
+		return img
+	case *image.RGBA:
+		img := image.NewRGBA(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+4], m.Pix[s:]) })
+		return img
+	case *image.NRGBA:
+		img := image.NewNRGBA(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+4], m.Pix[s:]) })
+		return img
+	case *image.RGBA64:
+		img := image.NewRGBA64(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+8], m.Pix[s:]) })
+		return img
+	case *image.NRGBA64:
+		img := image.NewNRGBA64(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+8], m.Pix[s:]) })
+		return img
+	case *image.CMYK:
+		img := image.NewCMYK(r)
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+4], m.Pix[s:]) })
+		return img
+	case *Gray32f:
+		img := NewGray32f(r)
+		img.Min, img.Max = m.Min, m.Max
+		each(m.PixOffset, img.PixOffset, func(s, d int) { img.Pix[d] = m.Pix[s] })
+		return img
+	case *Bands64f:
+		return orientBands(m, r, each)
+	case *Inks:
+		return &Inks{Bands64f: *orientBands(&m.Bands64f, r, each), InkSet: m.InkSet, InkNames: m.InkNames}
+	case *Lab:
+		img := NewLab(r)
+		img.WhitePoint = m.WhitePoint
+		each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+3], m.Pix[s:]) })
+		return img
+	case *image.YCbCr:
+		rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgba, rgba.Rect, m, b.Min, draw.Src)
+		return Upright(rgba, o)
+	}
+	rgba64 := image.NewRGBA64(image.Rect(0, 0, w, h))
+	draw.Draw(rgba64, rgba64.Rect, m, b.Min, draw.Src)
+	return Upright(rgba64, o)
+}
+
+// orientBands returns the *Bands64f m turned upright into the bounds r by
+// each (see Upright).
+func orientBands(m *Bands64f, r image.Rectangle, each func(src, dst func(x, y int) int, f func(s, d int))) *Bands64f {
+	img := NewBands64f(r, m.N)
+	copy(img.Min, m.Min)
+	copy(img.Max, m.Max)
+	each(m.PixOffset, img.PixOffset, func(s, d int) { copy(img.Pix[d:d+m.N], m.Pix[s:]) })
+	return img
+}
+
+// orientPoint returns where the pixel at (x, y) of a w by h image stored with
+// the Orientation value o is in the upright image.
+func orientPoint(o uint16, x, y, w, h int) (int, int) {
+	switch o {
+	case 2:
+		return w - 1 - x, y
+	case 3:
+		return w - 1 - x, h - 1 - y
+	case 4:
+		return x, h - 1 - y
+	case 5:
+		return y, x
+	case 6:
+		return h - 1 - y, x
+	case 7:
+		return h - 1 - y, w - 1 - x
+	case 8:
+		return y, w - 1 - x
+	}
+	return x, y
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// uprightPixels holds, for each Orientation value, the stored pixels of a 2x3
+// image, numbered 1 to 6 row by row, in the order they appear in the upright
+// image, row by row.  The upright image is 3x2 for 5 to 8.
+var uprightPixels = map[uint16][]int{
+	1: {1, 2, 3, 4, 5, 6},
+	2: {2, 1, 4, 3, 6, 5},
+	3: {6, 5, 4, 3, 2, 1},
+	4: {5, 6, 3, 4, 1, 2},
+	5: {1, 3, 5, 2, 4, 6},
+	6: {5, 3, 1, 6, 4, 2},
+	7: {6, 4, 2, 5, 3, 1},
+	8: {2, 4, 6, 1, 3, 5},
+}
+
+func TestDecodeOrientation(t *testing.T) {
+	stored := []byte{10, 20, 30, 40, 50, 60}
+	for o := uint16(1); o <= 8; o++ {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			name := fmt.Sprintf("Orientation %d, %v", o, bo)
+			b := testTIFF(t, newTestIFD(bo).image(2, 3, 1, 8).short(274, uint32(o)).strips(3, stored))
+
+			// Decode keeps the stored order.
+			m, err := Decode(bytes.NewReader(b))
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if g := m.(*image.Gray); g.Rect != image.Rect(0, 0, 2, 3) || !bytes.Equal(g.Pix, stored) {
+				t.Errorf("%s: Decode gives %v %v, want the stored pixels", name, g.Rect, g.Pix)
+			}
+			cfg, err := DecodeConfig(bytes.NewReader(b))
+			if err != nil || cfg.Width != 2 || cfg.Height != 3 {
+				t.Errorf("%s: DecodeConfig gives %dx%d, %v", name, cfg.Width, cfg.Height, err)
+			}
+
+			opts := &DecodeOptions{Orient: true}
+			w, h := 2, 3
+			if o >= 5 {
+				w, h = 3, 2
+			}
+			m, err = DecodeWithOptions(bytes.NewReader(b), opts)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			var want []byte
+			for _, i := range uprightPixels[o] {
+				want = append(want, stored[i-1])
+			}
+			if g := m.(*image.Gray); g.Rect != image.Rect(0, 0, w, h) || !bytes.Equal(g.Pix, want) {
+				t.Errorf("%s: got %v %v, want %v %v", name, g.Rect, g.Pix, image.Rect(0, 0, w, h), want)
+			}
+			cfg, err = DecodeConfigWithOptions(bytes.NewReader(b), opts)
+			if err != nil || cfg.Width != w || cfg.Height != h {
+				t.Errorf("%s: DecodeConfigWithOptions gives %dx%d, %v, want %dx%d", name, cfg.Width, cfg.Height, err, w, h)
+			}
+		}
+	}
+}
+
+func TestDecodeOrientationInvalid(t *testing.T) {
+	b := testTIFF(t, newTestIFD(binary.BigEndian).image(2, 3, 1, 8).short(274, 9).strips(3, seq(6)))
+	m, err := DecodeWithOptions(bytes.NewReader(b), &DecodeOptions{Orient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := m.(*image.Gray); !bytes.Equal(g.Pix, seq(6)) {
+		t.Errorf("Orientation 9 gives %v, want the stored pixels", g.Pix)
+	}
+}
+
+// orientTestImages returns 2x3 images of various types, not at the origin,
+// whose pixels all differ.
+func orientTestImages() []image.Image {
+	r := image.Rect(5, 7, 7, 10)
+	gray := image.NewGray(r)
+	gray16 := image.NewGray16(r)
+	pal := image.NewPaletted(r, color.Palette{color.Black, color.White, color.Gray{1}, color.Gray{2}, color.Gray{3}, color.Gray{4}})
+	rgba := image.NewRGBA(r)
+	nrgba64 := image.NewNRGBA64(r)
+	cmyk := image.NewCMYK(r)
+	gray32f := NewGray32f(r)
+	bands := NewBands64f(r, 3)
+	inks := &Inks{Bands64f: *NewBands64f(r, 4), InkSet: 2, InkNames: []string{"a", "b", "c", "d"}}
+	lab := NewLab(r)
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio444)
+	alpha16 := image.NewAlpha16(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			k := (y-r.Min.Y)*2 + x - r.Min.X
+			v := uint8(40 * (k + 1))
+			gray.SetGray(x, y, color.Gray{v})
+			gray16.SetGray16(x, y, color.Gray16{uint16(v) << 8})
+			pal.SetColorIndex(x, y, uint8(k))
+			rgba.SetRGBA(x, y, color.RGBA{v, 255 - v, 0, 255})
+			nrgba64.SetNRGBA64(x, y, color.NRGBA64{uint16(v) << 8, 0, 0, 0x8000})
+			cmyk.SetCMYK(x, y, color.CMYK{v, 0, 255 - v, 0})
+			gray32f.SetGray32f(x, y, float32(v)/255)
+			copy(bands.Samples(x, y), []float64{float64(k) / 6, 0, 1})
+			copy(inks.Samples(x, y), []float64{0, float64(k) / 6, 0, 0})
+			lab.SetLab(x, y, float64(10*k+20), 0, 0)
+			ycbcr.Y[ycbcr.YOffset(x, y)], ycbcr.Cb[ycbcr.COffset(x, y)], ycbcr.Cr[ycbcr.COffset(x, y)] = v, 128, 128
+			alpha16.SetAlpha16(x, y, color.Alpha16{uint16(v) << 8})
+		}
+	}
+	return []image.Image{gray, gray16, pal, rgba, nrgba64, cmyk, gray32f, bands, inks, lab, ycbcr, alpha16}
+}
+
+func TestUpright(t *testing.T) {
+	uprightType := map[string]string{
+		"*image.YCbCr":   "*image.RGBA",
+		"*image.Alpha16": "*image.RGBA64",
+	}
+	for _, m := range orientTestImages() {
+		typ := fmt.Sprintf("%T", m)
+		want := typ
+		if u, ok := uprightType[typ]; ok {
+			want = u
+		}
+		b := m.Bounds()
+		if got := Upright(m, 1); got != m {
+			t.Errorf("%s: Orientation 1 returns a new image", typ)
+		}
+		for o := uint16(2); o <= 8; o++ {
+			got := Upright(m, o)
+			if gt := fmt.Sprintf("%T", got); gt != want {
+				t.Errorf("%s, Orientation %d: got %s, want %s", typ, o, gt, want)
+			}
+			w, h := 2, 3
+			if o >= 5 {
+				w, h = 3, 2
+			}
+			if got.Bounds() != image.Rect(0, 0, w, h) {
+				t.Errorf("%s, Orientation %d: bounds %v", typ, o, got.Bounds())
+				continue
+			}
+			for i, k := range uprightPixels[o] {
+				sx, sy := b.Min.X+(k-1)%2, b.Min.Y+(k-1)/2
+				g := color.RGBA64Model.Convert(got.At(i%w, i/w))
+				s := color.RGBA64Model.Convert(m.At(sx, sy))
+				if g != s {
+					t.Errorf("%s, Orientation %d: pixel (%d, %d) is %v, want %v", typ, o, i%w, i/w, g, s)
+				}
+			}
+		}
+	}
+}
+
+func TestOrientPoint(t *testing.T) {
+	// orientPoint is the inverse of reading the upright pixels.
+	for o, pixels := range uprightPixels {
+		w := 2
+		if o >= 5 {
+			w = 3
+		}
+		for i, k := range pixels {
+			x, y := orientPoint(o, (k-1)%2, (k-1)/2, 2, 3)
+			if x != i%w || y != i/w {
+				t.Errorf("Orientation %d: pixel %d goes to (%d, %d), want (%d, %d)", o, k, x, y, i%w, i/w)
+			}
+		}
+	}
+}
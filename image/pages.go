@@ -0,0 +1,209 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/google/tiff"
+)
+
+/* Pages
+
+Tag 254 (NewSubfileType)
+	Type: Long
+	Bit 0 (SubfileReduced): a reduced resolution version of another image in
+		the file, such as a thumbnail.
+	Bit 1 (SubfilePage): a single page of a multi-page image.
+	Bit 2 (SubfileMask): a transparency mask for another image in the file.
+	Note: Default is 0, a full resolution image.
+
+Tag 255 (SubfileType)
+	1 = Full resolution image data
+	2 = Reduced resolution image data
+	3 = A single page of a multi-page image
+	Note: Replaced by NewSubfileType, and only used without it.
+
+Notes
+	Every IFD of the file is a subfile.  DecodeAll and Pages include the full
+	resolution images and pages, in the order of their IFDs, and skip the
+	reduced resolution images and masks unless DecodeOptions.Subfiles
+	includes them.  Each page is decoded by itself, and only when asked for.
+*/
+
+// The NewSubfileType bits.
+const (
+	SubfileReduced uint32 = 1 << iota
+	SubfilePage
+	SubfileMask
+)
+
+// subfile holds the fields that tell the kind of a subfile.
+type subfile struct {
+	NewSubfileType uint32 `tiff:"field,tag=254"`
+	SubfileType    uint16 `tiff:"field,tag=255"`
+}
+
+// kind returns the NewSubfileType bits of the subfile.
+func (sf *subfile) kind() uint32 {
+	if sf.NewSubfileType == 0 {
+		switch sf.SubfileType {
+		case 2:
+			return SubfileReduced
+		case 3:
+			return SubfilePage
+		}
+	}
+	return sf.NewSubfileType
+}
+
+// Page is a subfile of a TIFF that Pages includes.
+type Page struct {
+	// Index is the index of the IFD of the page in the file.
+	Index int
+	// IFD is the IFD of the page.
+	IFD tiff.IFD
+	// Subfile holds the NewSubfileType bits of the page.
+	Subfile uint32
+
+	t      tiff.TIFF
+	opts   *DecodeOptions
+	dec    Decoder
+	levels []Level
+}
+
+// Decoder returns the Decoder of the page.
+func (p *Page) Decoder() (Decoder, error) {
+	if p.dec == nil {
+		dec, err := getIFDDecoder(p.IFD, p.t.R())
+		if err != nil {
+			return nil, fmt.Errorf("tiff/image: page %d: %v", p.Index, err)
+		}
+		p.dec = dec
+	}
+	return p.dec, nil
+}
+
+// Config returns the color model and dimensions of the page.
+func (p *Page) Config() (image.Config, error) {
+	dec, err := p.Decoder()
+	if err != nil {
+		return image.Config{}, err
+	}
+	return decodeConfig(dec, p.opts)
+}
+
+// Decode decodes the image of the page.
+func (p *Page) Decode() (image.Image, error) {
+	dec, err := p.Decoder()
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(dec, p.opts)
+}
+
+// PageIterator steps through the pages of a TIFF.  Like a bufio.Scanner, Next
+// advances to the next page, which Page returns, and Err reports the error
+// that stopped it, if any:
+//
+//	it, err := Pages(r, nil)
+//	if err != nil {
+//		return err
+//	}
+//	for it.Next() {
+//		img, err := it.Page().Decode()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type PageIterator struct {
+	t    tiff.TIFF
+	opts *DecodeOptions
+	next int
+	page *Page
+	err  error
+}
+
+// Pages returns an iterator over the pages of the TIFF read from r, with the
+// decoding parameters opts.  A nil opts uses the defaults.  The images are
+// only decoded when asked for, so r must stay readable until then.
+func Pages(r io.Reader, opts *DecodeOptions) (*PageIterator, error) {
+	t, err := tiff.Parse(tiff.NewReadAtReadSeeker(r), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateTIFF(t); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = new(DecodeOptions)
+	}
+	return &PageIterator{t: t, opts: opts}, nil
+}
+
+// Next advances to the next page.  It returns false when there are no more
+// pages or on an error.
+func (it *PageIterator) Next() bool {
+	it.page = nil
+	ifds := it.t.IFDs()
+	for it.err == nil && it.next < len(ifds) {
+		i, ifd := it.next, ifds[it.next]
+		it.next++
+		var sf subfile
+		if err := tiff.UnmarshalIFD(ifd, &sf); err != nil {
+			it.err = fmt.Errorf("tiff/image: page %d: %v", i, err)
+			return false
+		}
+		kind := sf.kind()
+		if kind&^(it.opts.Subfiles|SubfilePage) != 0 {
+			continue
+		}
+		it.page = &Page{Index: i, IFD: ifd, Subfile: kind, t: it.t, opts: it.opts}
+		return true
+	}
+	return false
+}
+
+// Page returns the current page.
+func (it *PageIterator) Page() *Page {
+	return it.page
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// DecodeAll decodes the image of every page of the TIFF read from r.
+func DecodeAll(r io.Reader) ([]image.Image, error) {
+	return DecodeAllWithOptions(r, nil)
+}
+
+// DecodeAllWithOptions is like DecodeAll with the decoding parameters opts.  A
+// nil opts uses the defaults.
+func DecodeAllWithOptions(r io.Reader, opts *DecodeOptions) ([]image.Image, error) {
+	it, err := Pages(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var imgs []image.Image
+	for it.Next() {
+		img, err := it.Page().Decode()
+		if err != nil {
+			return nil, err
+		}
+		imgs = append(imgs, img)
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 {
+		return nil, fmt.Errorf("tiff/image: no pages found")
+	}
+	return imgs, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// pageIFD returns a gray w by w image whose pixels are all v.
+func pageIFD(bo binary.ByteOrder, w int, v byte) *testIFD {
+	return newTestIFD(bo).image(uint32(w), uint32(w), 1, 8).strips(uint32(w), bytes.Repeat([]byte{v}, w*w))
+}
+
+// pagesTestFile returns a TIFF whose IFD i is a gray image whose pixels are
+// all i, of these kinds:
+//
+//	0: full resolution, without subfile fields
+//	1: thumbnail (NewSubfileType 1)
+//	2: page (NewSubfileType 2)
+//	3: mask (NewSubfileType 4)
+//	4: reduced resolution page (NewSubfileType 3)
+//	5: reduced resolution (SubfileType 2)
+//	6: page (SubfileType 3)
+//	7: full resolution (SubfileType 1)
+//	8: NewSubfileType 4 takes precedence over SubfileType 1
+func pagesTestFile(t *testing.T, bo binary.ByteOrder) []byte {
+	return testTIFF(t,
+		pageIFD(bo, 8, 0),
+		pageIFD(bo, 2, 1).long(254, 1),
+		pageIFD(bo, 8, 2).long(254, 2),
+		pageIFD(bo, 8, 3).long(254, 4),
+		pageIFD(bo, 4, 4).long(254, 3),
+		pageIFD(bo, 4, 5).short(255, 2),
+		pageIFD(bo, 8, 6).short(255, 3),
+		pageIFD(bo, 8, 7).short(255, 1),
+		pageIFD(bo, 8, 8).long(254, 4).short(255, 1),
+	)
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *DecodeOptions
+		indexes  []int
+		subfiles []uint32
+	}{
+		{"default", nil, []int{0, 2, 6, 7}, []uint32{0, SubfilePage, SubfilePage, 0}},
+		{"zero options", &DecodeOptions{}, []int{0, 2, 6, 7}, []uint32{0, SubfilePage, SubfilePage, 0}},
+		{"reduced", &DecodeOptions{Subfiles: SubfileReduced},
+			[]int{0, 1, 2, 4, 5, 6, 7}, []uint32{0, SubfileReduced, SubfilePage, SubfileReduced | SubfilePage, SubfileReduced, SubfilePage, 0}},
+		{"masks", &DecodeOptions{Subfiles: SubfileMask},
+			[]int{0, 2, 3, 6, 7, 8}, []uint32{0, SubfilePage, SubfileMask, SubfilePage, 0, SubfileMask}},
+		{"everything", &DecodeOptions{Subfiles: SubfileReduced | SubfileMask | SubfilePage},
+			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8}, nil},
+	}
+	for _, tt := range tests {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			name := fmt.Sprintf("%s, %v", tt.name, bo)
+			b := pagesTestFile(t, bo)
+			it, err := Pages(bytes.NewReader(b), tt.opts)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			var indexes []int
+			var subfiles []uint32
+			for it.Next() {
+				p := it.Page()
+				indexes = append(indexes, p.Index)
+				subfiles = append(subfiles, p.Subfile)
+				m, err := p.Decode()
+				if err != nil {
+					t.Errorf("%s: page %d: %v", name, p.Index, err)
+					continue
+				}
+				if got := m.At(0, 0); got != (color.Gray{uint8(p.Index)}) {
+					t.Errorf("%s: page %d has pixel %v", name, p.Index, got)
+				}
+				cfg, err := p.Config()
+				if err != nil || cfg.Width != m.Bounds().Dx() {
+					t.Errorf("%s: page %d: config %+v, %v", name, p.Index, cfg, err)
+				}
+			}
+			if err := it.Err(); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			if it.Next() || it.Page() != nil {
+				t.Errorf("%s: Next continues after the last page", name)
+			}
+			if fmt.Sprint(indexes) != fmt.Sprint(tt.indexes) {
+				t.Errorf("%s: pages %v, want %v", name, indexes, tt.indexes)
+			}
+			if tt.subfiles != nil && fmt.Sprint(subfiles) != fmt.Sprint(tt.subfiles) {
+				t.Errorf("%s: subfiles %v, want %v", name, subfiles, tt.subfiles)
+			}
+
+			imgs, err := DecodeAllWithOptions(bytes.NewReader(b), tt.opts)
+			if err != nil {
+				t.Errorf("%s: DecodeAll: %v", name, err)
+				continue
+			}
+			var got []int
+			for _, m := range imgs {
+				got = append(got, int(m.(*image.Gray).Pix[0]))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.indexes) {
+				t.Errorf("%s: DecodeAll gives the images of %v, want %v", name, got, tt.indexes)
+			}
+		}
+	}
+
+	// DecodeAll is DecodeAllWithOptions with the defaults.
+	imgs, err := DecodeAll(bytes.NewReader(pagesTestFile(t, binary.BigEndian)))
+	if err != nil || len(imgs) != 4 {
+		t.Errorf("DecodeAll gives %d images, %v, want 4", len(imgs), err)
+	}
+}
+
+func TestPagesNone(t *testing.T) {
+	be := binary.BigEndian
+	b := testTIFF(t, pageIFD(be, 2, 1).long(254, 1), pageIFD(be, 8, 3).long(254, 4))
+	it, err := Pages(bytes.NewReader(b), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Next() {
+		t.Errorf("Next found page %d", it.Page().Index)
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	if _, err := DecodeAll(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "no pages found") {
+		t.Errorf("DecodeAll gives error %v, want one about no pages found", err)
+	}
+
+	// Including the thumbnail finds it.
+	imgs, err := DecodeAllWithOptions(bytes.NewReader(b), &DecodeOptions{Subfiles: SubfileReduced})
+	if err != nil || len(imgs) != 1 {
+		t.Errorf("DecodeAllWithOptions gives %d images, %v, want 1", len(imgs), err)
+	}
+}
+
+func TestPagesErrors(t *testing.T) {
+	be := binary.BigEndian
+	// A page that cannot be decoded stops DecodeAll, but not Pages.
+	bad := newTestIFD(be).image(8, 8, 1, 8).short(259, 99).strips(8, make([]byte, 64))
+	b := testTIFF(t, pageIFD(be, 8, 0), bad, pageIFD(be, 8, 2))
+	if _, err := DecodeAll(bytes.NewReader(b)); err == nil {
+		t.Error("DecodeAll decoded a page of an unknown compression")
+	}
+	it, err := Pages(bytes.NewReader(b), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for it.Next() {
+		if _, err := it.Page().Decode(); (err != nil) != (it.Page().Index == 1) {
+			t.Errorf("page %d: got error %v", it.Page().Index, err)
+		}
+		n++
+	}
+	if n != 3 || it.Err() != nil {
+		t.Errorf("got %d pages and error %v, want 3 and none", n, it.Err())
+	}
+}
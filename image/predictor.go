@@ -0,0 +1,190 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/* Predictor
+
+Predictor
+	Tag 317 (Predictor)
+		1 = No prediction (default)
+		2 = Horizontal differencing
+		3 = Floating point horizontal differencing
+
+Notes
+	A predictor is applied to each row of a chunk before compression and
+	undone after decompression, so it works with any compression.
+
+	With 2, each sample is stored as the difference from the same sample of
+	the pixel to its left, using wrapping arithmetic of the sample size.
+	Samples of 8, 16, 32 and 64 bits are supported.  Samples wider than a
+	byte are in the byte order of the file.
+
+	With 3, the bytes of the samples of a row are first split into planes:
+	the most significant byte of every sample, then the next byte of every
+	sample, and so on.  The resulting row of bytes is then differenced byte by
+	byte with a distance of SamplesPerPixel.  This is for floating point
+	samples (SampleFormat 3) of 16, 32 and 64 bits and does not depend on the
+	byte order of the file.  Using it for any other SampleFormat is an error.
+*/
+
+// checkPredictor checks that predictor can be used with samples of
+// sampleFormat and bitsPerSample (see Predictor).
+func checkPredictor(predictor, sampleFormat uint16, bitsPerSample int) error {
+	if predictor == 3 && sampleFormat != 3 {
+		return fmt.Errorf("tiff/image: Predictor 3 is only for floating point samples, not SampleFormat %d", sampleFormat)
+	}
+	return checkPredictorBits(predictor, bitsPerSample)
+}
+
+// checkPredictorBits checks that predictor supports samples of bitsPerSample.
+func checkPredictorBits(predictor uint16, bitsPerSample int) error {
+	switch predictor {
+	case 0, 1:
+		return nil
+	case 2:
+		switch bitsPerSample {
+		case 8, 16, 32, 64:
+			return nil
+		}
+	case 3:
+		switch bitsPerSample {
+		case 16, 32, 64:
+			return nil
+		}
+	default:
+		return fmt.Errorf("tiff/image: unsupported Predictor value: %d", predictor)
+	}
+	return fmt.Errorf("tiff/image: Predictor %d does not support %d bits per sample", predictor, bitsPerSample)
+}
+
+// unpredict undoes predictor in place on each complete row of data.  Each row
+// is rowBytes long and holds pixels of spp samples of bps bits each.
+func unpredict(data []byte, predictor uint16, rowBytes, spp, bps int, bo binary.ByteOrder) error {
+	if err := checkPredictorBits(predictor, bps); err != nil {
+		return err
+	}
+	for ; len(data) >= rowBytes && rowBytes > 0; data = data[rowBytes:] {
+		row := data[:rowBytes]
+		switch predictor {
+		case 2:
+			accumulate(row, spp, bps/8, bo)
+		case 3:
+			for i := spp; i < len(row); i++ {
+				row[i] += row[i-spp]
+			}
+			unshuffle(row, bps/8, bo)
+		}
+	}
+	return nil
+}
+
+// predict applies predictor in place on each complete row of data.  It is the
+// inverse of unpredict.
+func predict(data []byte, predictor uint16, rowBytes, spp, bps int, bo binary.ByteOrder) error {
+	if err := checkPredictorBits(predictor, bps); err != nil {
+		return err
+	}
+	for ; len(data) >= rowBytes && rowBytes > 0; data = data[rowBytes:] {
+		row := data[:rowBytes]
+		switch predictor {
+		case 2:
+			difference(row, spp, bps/8, bo)
+		case 3:
+			shuffle(row, bps/8, bo)
+			for i := len(row) - 1; i >= spp; i-- {
+				row[i] -= row[i-spp]
+			}
+		}
+	}
+	return nil
+}
+
+// accumulate undoes horizontal differencing on the samples of size bytes in
+// row.
+func accumulate(row []byte, spp, size int, bo binary.ByteOrder) {
+	n := len(row) / size
+	switch size {
+	case 1:
+		for i := spp; i < n; i++ {
+			row[i] += row[i-spp]
+		}
+	case 2:
+		for i := spp; i < n; i++ {
+			bo.PutUint16(row[2*i:], bo.Uint16(row[2*i:])+bo.Uint16(row[2*(i-spp):]))
+		}
+	case 4:
+		for i := spp; i < n; i++ {
+			bo.PutUint32(row[4*i:], bo.Uint32(row[4*i:])+bo.Uint32(row[4*(i-spp):]))
+		}
+	case 8:
+		for i := spp; i < n; i++ {
+			bo.PutUint64(row[8*i:], bo.Uint64(row[8*i:])+bo.Uint64(row[8*(i-spp):]))
+		}
+	}
+}
+
+// difference applies horizontal differencing on the samples of size bytes in
+// row.
+func difference(row []byte, spp, size int, bo binary.ByteOrder) {
+	n := len(row) / size
+	switch size {
+	case 1:
+		for i := n - 1; i >= spp; i-- {
+			row[i] -= row[i-spp]
+		}
+	case 2:
+		for i := n - 1; i >= spp; i-- {
+			bo.PutUint16(row[2*i:], bo.Uint16(row[2*i:])-bo.Uint16(row[2*(i-spp):]))
+		}
+	case 4:
+		for i := n - 1; i >= spp; i-- {
+			bo.PutUint32(row[4*i:], bo.Uint32(row[4*i:])-bo.Uint32(row[4*(i-spp):]))
+		}
+	case 8:
+		for i := n - 1; i >= spp; i-- {
+			bo.PutUint64(row[8*i:], bo.Uint64(row[8*i:])-bo.Uint64(row[8*(i-spp):]))
+		}
+	}
+}
+
+// unshuffle turns a row split into byte planes (most significant first) back
+// into samples of size bytes in byte order bo.
+func unshuffle(row []byte, size int, bo binary.ByteOrder) {
+	n := len(row) / size
+	tmp := make([]byte, n*size)
+	copy(tmp, row)
+	for i := 0; i < n; i++ {
+		for b := 0; b < size; b++ {
+			// Plane b holds byte b counting from the most significant.
+			if bo == binary.BigEndian {
+				row[i*size+b] = tmp[b*n+i]
+			} else {
+				row[i*size+size-1-b] = tmp[b*n+i]
+			}
+		}
+	}
+}
+
+// shuffle splits the samples of size bytes in byte order bo of row into byte
+// planes, most significant first.
+func shuffle(row []byte, size int, bo binary.ByteOrder) {
+	n := len(row) / size
+	tmp := make([]byte, n*size)
+	copy(tmp, row)
+	for i := 0; i < n; i++ {
+		for b := 0; b < size; b++ {
+			if bo == binary.BigEndian {
+				row[b*n+i] = tmp[i*size+b]
+			} else {
+				row[b*n+i] = tmp[i*size+size-1-b]
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPredictorLayouts(t *testing.T) {
+	be, le := binary.BigEndian, binary.LittleEndian
+	ff := bytes.Repeat([]byte{0xff}, 8)
+	tests := []struct {
+		name      string
+		predictor uint16
+		spp, bps  int
+		bo        binary.ByteOrder
+		raw, enc  []byte // One row, as decoded and as stored.
+	}{
+		{"8 bits", 2, 1, 8, le, []byte{10, 20, 15, 255}, []byte{10, 10, 251, 240}},
+		{"8 bits RGB", 2, 3, 8, be, []byte{1, 2, 3, 4, 6, 8, 0, 0, 0}, []byte{1, 2, 3, 3, 4, 5, 252, 250, 248}},
+		{"16 bits little endian", 2, 1, 16, le, []byte{0x02, 0x01, 0x04, 0x03, 0x00, 0x01}, []byte{0x02, 0x01, 0x02, 0x02, 0xfc, 0xfd}},
+		{"16 bits big endian", 2, 1, 16, be, []byte{0x01, 0x02, 0x03, 0x04, 0x01, 0x00}, []byte{0x01, 0x02, 0x02, 0x02, 0xfd, 0xfc}},
+		{"16 bits two samples", 2, 2, 16, be, []byte{0x00, 0x01, 0x10, 0x00, 0x00, 0x03, 0x0f, 0x00}, []byte{0x00, 0x01, 0x10, 0x00, 0x00, 0x02, 0xff, 0x00}},
+		{"32 bits little endian", 2, 1, 32, le, []byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0}, []byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff}},
+		{"32 bits big endian", 2, 1, 32, be, []byte{1, 0, 0, 0, 0, 0xff, 0xff, 0xff}, []byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}},
+		{"64 bits little endian", 2, 1, 64, le, append([]byte{1, 0, 0, 0, 0, 0, 0, 0}, make([]byte, 8)...), append([]byte{1, 0, 0, 0, 0, 0, 0, 0}, ff...)},
+		{"64 bits big endian", 2, 1, 64, be, append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, make([]byte, 8)...), append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, ff...)},
+
+		// 1.0 and 2.0: the byte planes are 3f 40, 80 00, 00 00 and 00 00.
+		{"float32 big endian", 3, 1, 32, be, []byte{0x3f, 0x80, 0, 0, 0x40, 0, 0, 0}, []byte{0x3f, 0x01, 0x40, 0x80, 0, 0, 0, 0}},
+		{"float32 little endian", 3, 1, 32, le, []byte{0, 0, 0x80, 0x3f, 0, 0, 0, 0x40}, []byte{0x3f, 0x01, 0x40, 0x80, 0, 0, 0, 0}},
+		// Two pixels of 1.0, 2.0 and -2.0, 0.5: the byte planes are
+		// 3c 40 c0 38 and 00 00 00 00.
+		{"float16 big endian", 3, 2, 16, be, []byte{0x3c, 0, 0x40, 0, 0xc0, 0, 0x38, 0}, []byte{0x3c, 0x40, 0x84, 0xf8, 0x40, 0xc8, 0, 0}},
+		{"float16 little endian", 3, 2, 16, le, []byte{0, 0x3c, 0, 0x40, 0, 0xc0, 0, 0x38}, []byte{0x3c, 0x40, 0x84, 0xf8, 0x40, 0xc8, 0, 0}},
+		// 1.0 is 3ff0000000000000.
+		{"float64 big endian", 3, 1, 64, be, []byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, []byte{0x3f, 0xb1, 0x10, 0, 0, 0, 0, 0}},
+		{"float64 little endian", 3, 1, 64, le, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}, []byte{0x3f, 0xb1, 0x10, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		// Two rows, to check that each row is predicted on its own, and
+		// a partial row that is left alone.
+		rows := func(row []byte) []byte {
+			return append(append(append([]byte(nil), row...), row...), 0xaa)
+		}
+		rowBytes := len(tt.raw)
+		got := rows(tt.raw)
+		if err := predict(got, tt.predictor, rowBytes, tt.spp, tt.bps, tt.bo); err != nil {
+			t.Errorf("%s: predict: %v", tt.name, err)
+			continue
+		}
+		if want := rows(tt.enc); !bytes.Equal(got, want) {
+			t.Errorf("%s: predict gives % x, want % x", tt.name, got, want)
+		}
+		got = rows(tt.enc)
+		if err := unpredict(got, tt.predictor, rowBytes, tt.spp, tt.bps, tt.bo); err != nil {
+			t.Errorf("%s: unpredict: %v", tt.name, err)
+			continue
+		}
+		if want := rows(tt.raw); !bytes.Equal(got, want) {
+			t.Errorf("%s: unpredict gives % x, want % x", tt.name, got, want)
+		}
+	}
+}
+
+func TestCheckPredictor(t *testing.T) {
+	tests := []struct {
+		predictor, sampleFormat uint16
+		bps                     int
+		ok                      bool
+	}{
+		{1, 1, 12, true},
+		{0, 1, 1, true},
+		{2, 1, 8, true},
+		{2, 2, 16, true},
+		{2, 1, 64, true},
+		{2, 3, 32, true},
+		{3, 3, 16, true},
+		{3, 3, 32, true},
+		{3, 3, 64, true},
+		{2, 1, 4, false},
+		{2, 1, 12, false},
+		{2, 1, 24, false},
+		{3, 1, 32, false},
+		{3, 2, 16, false},
+		{3, 3, 24, false},
+		{4, 1, 8, false},
+	}
+	for _, tt := range tests {
+		err := checkPredictor(tt.predictor, tt.sampleFormat, tt.bps)
+		if (err == nil) != tt.ok {
+			t.Errorf("Predictor %d, SampleFormat %d, %d bits: got error %v, want ok %v", tt.predictor, tt.sampleFormat, tt.bps, err, tt.ok)
+		}
+	}
+
+	// A file using Predictor 3 for integer samples is rejected.
+	b := testTIFF(t, newTestIFD(binary.BigEndian).image(2, 1, 1, 16).short(317, 3).strips(1, make([]byte, 4)))
+	if _, err := Decode(bytes.NewReader(b)); err == nil {
+		t.Error("Predictor 3 decoded integer samples")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+/* Sample Format
+
+Fields
+	Tag 339 (SampleFormat)
+		1 = Unsigned integer data (default)
+		2 = Two's complement signed integer data
+		3 = IEEE floating point data
+		4 = Undefined data format (decoded as unsigned integer data)
+		Note: One value for each sample.
+
+	Tag 340 (SMinSampleValue)
+	Tag 341 (SMaxSampleValue)
+		Type: Same as the samples (any integer, Float or Double)
+		Note: The smallest and largest values of the samples, either one for
+			each sample or a single one for all of them.
+
+Bits Per Sample
+	Integers may have 1 to 32 or 64 bits.  Samples of 16, 24, 32 and 64 bits
+	are in the byte order of the file, as with libtiff, others are packed MSB
+	first.  Floats may have 16 (half precision), 32 or 64 bits.
+
+Notes
+	Unsigned integer samples of up to 16 bits, including odd depths such as 12
+	and 14 bits, are decoded into the standard image types, scaled to their
+	full range.  Any other samples keep their values:
+	- A grayscale image is decoded into a *Gray32f when its values fit in a
+	  float32 (floats of up to 32 bits and integers of up to 24 bits), and a
+	  *Bands64f otherwise.
+	- An RGB image is decoded into a *Bands64f with every sample.
+	These are only converted to colors on demand, by At, which maps the range
+	between SMinSampleValue and SMaxSampleValue to the full range of a color.
+	Without these fields, the range of the integer type is used, or, for
+	floats, the range of the decoded values.
+*/
+
+// setupSampleFormat checks the sample format and bits per sample of r and
+// sets its value range.
+func (r *raster) setupSampleFormat(bld *bilevelDecoder) error {
+	r.sampleFormat = 1
+	if len(bld.SampleFormat) > 0 {
+		for _, sf := range bld.SampleFormat[1:] {
+			if sf != bld.SampleFormat[0] {
+				return fmt.Errorf("tiff/image: SampleFormat %v differs between samples", bld.SampleFormat)
+			}
+		}
+		switch sf := bld.SampleFormat[0]; sf {
+		case 1, 4:
+		case 2, 3:
+			r.sampleFormat = sf
+		default:
+			return fmt.Errorf("tiff/image: unsupported SampleFormat value: %d", sf)
+		}
+	}
+	bps := r.bitsPerSample
+	switch {
+	case r.sampleFormat == 3 && bps != 16 && bps != 32 && bps != 64:
+		return fmt.Errorf("tiff/image: unsupported BitsPerSample value for floating point data: %d", bps)
+	case bps == 0 || bps > 32 && bps != 64:
+		return fmt.Errorf("tiff/image: unsupported BitsPerSample value: %d", bps)
+	}
+	r.sMin, r.sMax = bld.SMinSampleValue, bld.SMaxSampleValue
+	return nil
+}
+
+// keepsValues reports whether the samples of r are decoded with their values
+// instead of being scaled to a standard image type.
+func (r *raster) keepsValues() bool {
+	return keepsValues(r.sampleFormat, r.bitsPerSample)
+}
+
+func keepsValues(sampleFormat uint16, bitsPerSample int) bool {
+	return sampleFormat == 2 || sampleFormat == 3 || bitsPerSample > 16
+}
+
+// keepsValues reports whether the samples of bld are decoded with their
+// values, for Config.
+func (bld *bilevelDecoder) keepsValues(bitsPerSample []uint16) bool {
+	var sf uint16
+	if len(bld.SampleFormat) > 0 {
+		sf = bld.SampleFormat[0]
+	}
+	bps := 1
+	if len(bitsPerSample) > 0 {
+		bps = int(bitsPerSample[0])
+	}
+	return keepsValues(sf, bps)
+}
+
+// value returns the i'th sample of the packed row as a float64.
+func (r *raster) value(row []byte, i int) float64 {
+	bo := r.byteOrder()
+	bps := r.bitsPerSample
+	switch r.sampleFormat {
+	case 3:
+		switch bps {
+		case 16:
+			return halfToFloat(bo.Uint16(row[2*i:]))
+		case 32:
+			return float64(math.Float32frombits(bo.Uint32(row[4*i:])))
+		}
+		return math.Float64frombits(bo.Uint64(row[8*i:]))
+	case 2:
+		switch bps {
+		case 8:
+			return float64(int8(row[i]))
+		case 16:
+			return float64(int16(bo.Uint16(row[2*i:])))
+		case 32:
+			return float64(int32(bo.Uint32(row[4*i:])))
+		case 64:
+			return float64(int64(bo.Uint64(row[8*i:])))
+		}
+		// Sign extend the bits.
+		v := r.sample(row, i)
+		return float64(int32(v<<uint(32-bps)) >> uint(32-bps))
+	}
+	switch bps {
+	case 32:
+		return float64(bo.Uint32(row[4*i:]))
+	case 64:
+		return float64(bo.Uint64(row[8*i:]))
+	}
+	return float64(r.sample(row, i))
+}
+
+// halfToFloat converts an IEEE half precision float.
+func halfToFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h >> 10 & 0x1f)
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(1024+frac, exp-25)
+}
+
+// valueRange returns the values of band that At maps to black and white.
+// The values are only scanned for floats without SMinSampleValue and
+// SMaxSampleValue.
+func (r *raster) valueRange(band int, values func(func(float64))) (min, max float64) {
+	pick := func(vs []float64) (float64, bool) {
+		switch {
+		case band < len(vs):
+			return vs[band], true
+		case len(vs) == 1:
+			return vs[0], true
+		}
+		return 0, false
+	}
+	min, okMin := pick(r.sMin)
+	max, okMax := pick(r.sMax)
+	if okMin && okMax {
+		return min, max
+	}
+	var lo, hi float64
+	switch bps := uint(r.bitsPerSample); r.sampleFormat {
+	case 3:
+		lo, hi = math.Inf(1), math.Inf(-1)
+		values(func(v float64) {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return
+			}
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		})
+		if lo > hi {
+			lo, hi = 0, 1
+		}
+	case 2:
+		lo, hi = -math.Ldexp(1, int(bps-1)), math.Ldexp(1, int(bps-1))-1
+	default:
+		lo, hi = 0, math.Ldexp(1, int(bps))-1
+	}
+	if !okMin {
+		min = lo
+	}
+	if !okMax {
+		max = hi
+	}
+	return min, max
+}
+
+// values returns a function that calls f with the value of band of each
+// pixel of the decoded rows data, for valueRange.
+func (r *raster) values(data []byte, band int) func(func(float64)) {
+	rb := r.rowBytes()
+	return func(f func(float64)) {
+		for y := 0; y < r.height; y++ {
+			row := data[y*rb : (y+1)*rb]
+			for x := 0; x < r.width; x++ {
+				f(r.value(row, x*r.samplesPerPixel+band))
+			}
+		}
+	}
+}
+
+// decodeValues decodes the first n samples of each pixel of the decoded rows
+// data, keeping their values (see Sample Format).  When invert is set, the
+// range of the first band is inverted.
+func decodeValues(r *raster, data []byte, n int, invert bool) image.Image {
+	fitsFloat32 := r.bitsPerSample <= 32
+	if r.sampleFormat != 3 {
+		fitsFloat32 = r.bitsPerSample <= 24
+	}
+	if n == 1 && fitsFloat32 {
+		img := NewGray32f(image.Rect(0, 0, r.width, r.height))
+		rb := r.rowBytes()
+		for y := 0; y < r.height; y++ {
+			row := data[y*rb : (y+1)*rb]
+			pix := img.Pix[y*img.Stride:]
+			for x := 0; x < r.width; x++ {
+				pix[x] = float32(r.value(row, x*r.samplesPerPixel))
+			}
+		}
+		img.Min, img.Max = r.valueRange(0, r.values(data, 0))
+		if invert {
+			img.Min, img.Max = img.Max, img.Min
+		}
+		return img
+	}
+	img := decodeBands(r, data, n)
+	if invert {
+		img.Min[0], img.Max[0] = img.Max[0], img.Min[0]
+	}
+	return img
+}
+
+// decodeBands decodes the values of the first n samples of each pixel of the
+// decoded rows data into a *Bands64f.
+func decodeBands(r *raster, data []byte, n int) *Bands64f {
+	img := NewBands64f(image.Rect(0, 0, r.width, r.height), n)
+	rb := r.rowBytes()
+	for y := 0; y < r.height; y++ {
+		row := data[y*rb : (y+1)*rb]
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < r.width; x++ {
+			for i := 0; i < n; i++ {
+				pix[x*n+i] = r.value(row, x*r.samplesPerPixel+i)
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		img.Min[i], img.Max[i] = r.valueRange(i, r.values(data, i))
+	}
+	return img
+}
@@ -0,0 +1,137 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// sampleBytes returns vals as samples of size bytes in byte order bo.
+func sampleBytes(bo binary.ByteOrder, size int, vals ...uint64) []byte {
+	var b []byte
+	for _, v := range vals {
+		for i := 0; i < size; i++ {
+			shift := uint(8 * (size - 1 - i))
+			if bo == binary.LittleEndian {
+				shift = uint(8 * i)
+			}
+			b = append(b, byte(v>>shift))
+		}
+	}
+	return b
+}
+
+func TestSampleFormats(t *testing.T) {
+	f32 := func(f float32) uint64 { return uint64(math.Float32bits(f)) }
+	tests := []struct {
+		name         string
+		sampleFormat uint32
+		bps          uint32
+		data         func(bo binary.ByteOrder) []byte
+		want         []float64
+		typ          string
+	}{
+		{"int8", 2, 8, func(bo binary.ByteOrder) []byte { return []byte{0x80, 0xff, 0x7f} },
+			[]float64{-128, -1, 127}, "*image.Gray32f"},
+		{"int16", 2, 16, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 2, 0x8000, 0xffff, 0x7fff) },
+			[]float64{-32768, -1, 32767}, "*image.Gray32f"},
+		{"int32", 2, 32, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 4, 0x80000000, 0xfffffffe, 0x01020304) },
+			[]float64{-2147483648, -2, 0x01020304}, "*image.Bands64f"},
+		{"float16", 3, 16, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 2, 0x3c00, 0xc000, 0x3800) },
+			[]float64{1, -2, 0.5}, "*image.Gray32f"},
+		{"float32", 3, 32, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 4, f32(1.5), f32(-0.25), f32(1e6)) },
+			[]float64{1.5, -0.25, 1e6}, "*image.Gray32f"},
+		{"float64", 3, 64, func(bo binary.ByteOrder) []byte {
+			return sampleBytes(bo, 8, math.Float64bits(1), math.Float64bits(-2), math.Float64bits(1e-300))
+		}, []float64{1, -2, 1e-300}, "*image.Bands64f"},
+		// Samples of 12 bits are packed MSB first in either byte order.
+		{"int12", 2, 12, func(bo binary.ByteOrder) []byte { return []byte{0xff, 0xf0, 0x05, 0x80, 0x00} },
+			[]float64{-1, 5, -2048}, "*image.Gray32f"},
+		{"uint24", 1, 24, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 3, 0x010203, 0xfffffe, 0) },
+			[]float64{0x010203, 0xfffffe, 0}, "*image.Gray32f"},
+		{"int24", 2, 24, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 3, 0x010203, 0xfffffe, 0x800000) },
+			[]float64{0x010203, -2, -8388608}, "*image.Gray32f"},
+	}
+	for _, tt := range tests {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			name := fmt.Sprintf("%s, %v", tt.name, bo)
+			ti := newTestIFD(bo).image(uint32(len(tt.want)), 1, 1, tt.bps).short(339, tt.sampleFormat).strips(1, tt.data(bo))
+			m, err := Decode(bytes.NewReader(testTIFF(t, ti)))
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if typ := fmt.Sprintf("%T", m); typ != tt.typ {
+				t.Errorf("%s: decoded as %s, want %s", name, typ, tt.typ)
+				continue
+			}
+			for x, want := range tt.want {
+				var got float64
+				switch m := m.(type) {
+				case *Gray32f:
+					got = float64(m.Gray32fAt(x, 0))
+				case *Bands64f:
+					got = m.Samples(x, 0)[0]
+				}
+				if got != want {
+					t.Errorf("%s: sample %d is %v, want %v", name, x, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSampleFormatScaled(t *testing.T) {
+	// Unsigned samples of up to 16 bits are scaled to the full range of the
+	// standard image types.
+	tests := []struct {
+		name string
+		bps  uint32
+		data func(bo binary.ByteOrder) []byte
+		want []uint16
+	}{
+		{"uint12", 12, func(bo binary.ByteOrder) []byte { return []byte{0xff, 0xf0, 0x00} }, []uint16{0xffff, 0}},
+		{"uint16", 16, func(bo binary.ByteOrder) []byte { return sampleBytes(bo, 2, 0x1234, 0xfedc) }, []uint16{0x1234, 0xfedc}},
+	}
+	for _, tt := range tests {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			name := fmt.Sprintf("%s, %v", tt.name, bo)
+			ti := newTestIFD(bo).image(uint32(len(tt.want)), 1, 1, tt.bps).strips(1, tt.data(bo))
+			m, err := Decode(bytes.NewReader(testTIFF(t, ti)))
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			for x, want := range tt.want {
+				if got := color.Gray16Model.Convert(m.At(x, 0)).(color.Gray16).Y; got != want {
+					t.Errorf("%s: sample %d is %#x, want %#x", name, x, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSampleFormatErrors(t *testing.T) {
+	be := binary.BigEndian
+	tests := []struct {
+		name string
+		ti   *testIFD
+	}{
+		{"float of 24 bits", newTestIFD(be).image(1, 1, 1, 24).short(339, 3).strips(1, make([]byte, 3))},
+		{"48 bits", newTestIFD(be).image(1, 1, 1, 48).strips(1, make([]byte, 6))},
+		{"unknown SampleFormat", newTestIFD(be).image(1, 1, 1, 8).short(339, 5).strips(1, make([]byte, 1))},
+		{"mixed SampleFormats", newTestIFD(be).image(1, 1, 2, 8, 8, 8).short(339, 1, 2, 1).strips(1, make([]byte, 3))},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(bytes.NewReader(testTIFF(t, tt.ti))); err == nil {
+			t.Errorf("%s: decoded without an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/google/tiff"
+)
+
+/* Separated (usually CMYK) Images
+
+Tag 262 (PhotometricInterpretation)
+	5 = Separated (each sample is the amount of one ink)
+
+Tag 332 (InkSet)
+	1 = CMYK (default).  The inks are cyan, magenta, yellow and black, in that
+		order, and InkNames is not used.
+	2 = Not CMYK.  See InkNames.
+
+Tag 333 (InkNames)
+	Type: ASCII
+	Note: The NUL separated names of the inks, in the order of the samples.
+
+Tag 334 (NumberOfInks)
+	Note: Default is 4.  Samples past the inks are extra samples.
+
+Tag 336 (DotRange)
+	Type: Byte or Short
+	Note: The sample values for 0% and 100% dot, either a single pair for
+		every ink or one pair for each ink.  Default is 0 and 2**BitsPerSample-1.
+
+Notes
+	Four CMYK inks of up to 8 bits are decoded into an *image.CMYK, with
+	DotRange mapped to 0 to 255.  Any other inks, including 16 bit CMYK, are
+	decoded into an *Inks with their values, where DotRange gives the range
+	of each ink.
+*/
+
+// Inks is an in-memory image of any number of inks per pixel, holding the
+// values of the samples of a separated image.  At shows the first four inks
+// as cyan, magenta, yellow and black, which only gives the right colors for
+// CMYK (InkSet 1).
+type Inks struct {
+	Bands64f
+	// InkSet is the value of the InkSet field.
+	InkSet uint16
+	// InkNames holds the name of each ink, when known.
+	InkNames []string
+}
+
+func (p *Inks) ColorModel() color.Model { return color.CMYKModel }
+
+func (p *Inks) At(x, y int) color.Color {
+	s := p.Samples(x, y)
+	var c [4]uint8
+	for i := 0; i < len(s) && i < 4; i++ {
+		c[i] = uint8(scaleValue(s[i], p.Min[i], p.Max[i]) >> 8)
+	}
+	return color.CMYK{c[0], c[1], c[2], c[3]}
+}
+
+type separatedDecoder struct {
+	grayscaleDecoder `tiff:"ifd"`
+	SamplesPerPixel  uint16   `tiff:"field,tag=277"`
+	InkSet           uint16   `tiff:"field,tag=332"`
+	InkNames         string   `tiff:"field,tag=333"`
+	NumberOfInks     uint16   `tiff:"field,tag=334"`
+	DotRange         []uint16 `tiff:"field,tag=336"`
+}
+
+// inks returns the number of inks of an image with spp samples per pixel.
+func (sd *separatedDecoder) inks(spp int) (int, error) {
+	switch {
+	case sd.NumberOfInks > 0:
+		if int(sd.NumberOfInks) > spp {
+			return 0, fmt.Errorf("tiff/image: NumberOfInks %d exceeds SamplesPerPixel %d", sd.NumberOfInks, spp)
+		}
+		return int(sd.NumberOfInks), nil
+	case len(sd.ExtraSamples) > 0 && len(sd.ExtraSamples) < spp:
+		return spp - len(sd.ExtraSamples), nil
+	case sd.InkSet <= 1 && spp >= 4:
+		return 4, nil
+	}
+	return spp, nil
+}
+
+// dotRange returns the DotRange of ink i, if any.
+func (sd *separatedDecoder) dotRange(i int) (lo, hi float64, ok bool) {
+	switch {
+	case 2*i+1 < len(sd.DotRange):
+		return float64(sd.DotRange[2*i]), float64(sd.DotRange[2*i+1]), true
+	case len(sd.DotRange) == 2:
+		return float64(sd.DotRange[0]), float64(sd.DotRange[1]), true
+	}
+	return 0, 0, false
+}
+
+// inkNames returns the names of the InkNames field.
+func (sd *separatedDecoder) inkNames() []string {
+	names := strings.TrimRight(sd.InkNames, "\x00")
+	if names == "" {
+		return nil
+	}
+	return strings.Split(names, "\x00")
+}
+
+func (sd *separatedDecoder) Image() (image.Image, error) {
+	if sd.img == nil {
+		r, err := newRaster(&sd.bilevelDecoder, sd.SamplesPerPixel, sd.BitsPerSample)
+		if err != nil {
+			return nil, err
+		}
+		inks, err := sd.inks(r.samplesPerPixel)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.decode()
+		if err != nil {
+			return nil, err
+		}
+		if sd.InkSet <= 1 && inks == 4 && r.bitsPerSample <= 8 && !r.keepsValues() {
+			sd.img = sd.decodeCMYK(r, data)
+			return sd.img, nil
+		}
+		img := &Inks{Bands64f: *decodeBands(r, data, inks), InkSet: sd.InkSet, InkNames: sd.inkNames()}
+		if img.InkSet == 0 {
+			img.InkSet = 1
+		}
+		for i := 0; i < inks; i++ {
+			if lo, hi, ok := sd.dotRange(i); ok {
+				img.Min[i], img.Max[i] = lo, hi
+			}
+		}
+		sd.img = img
+	}
+	return sd.img, nil
+}
+
+// decodeCMYK decodes the first four samples of each pixel of the decoded rows
+// data into an *image.CMYK.
+func (sd *separatedDecoder) decodeCMYK(r *raster, data []byte) *image.CMYK {
+	var lo, hi [4]float64
+	for i := range lo {
+		var ok bool
+		if lo[i], hi[i], ok = sd.dotRange(i); !ok {
+			lo[i], hi[i] = 0, float64(r.maxValue())
+		}
+	}
+	img := image.NewCMYK(image.Rect(0, 0, r.width, r.height))
+	rb := r.rowBytes()
+	spp := r.samplesPerPixel
+	for y := 0; y < r.height; y++ {
+		row := data[y*rb : (y+1)*rb]
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < r.width; x++ {
+			for c := 0; c < 4; c++ {
+				v := float64(r.sample(row, x*spp+c))
+				pix[4*x+c] = uint8(scaleValue(v, lo[c], hi[c]) >> 8)
+			}
+		}
+	}
+	return img
+}
+
+func (sd *separatedDecoder) Config() (cfg image.Config, err error) {
+	cfg.Height = int(sd.ImageLength)
+	cfg.Width = int(sd.ImageWidth)
+	cfg.ColorModel = color.CMYKModel
+	return
+}
+
+type Separated struct{}
+
+func (Separated) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
+	sepDec := &separatedDecoder{grayscaleDecoder: grayscaleDecoder{bilevelDecoder: bilevelDecoder{br: br}}}
+	if err = tiff.UnmarshalIFD(ifd, sepDec); err != nil {
+		return
+	}
+	return sepDec, nil
+}
+
+func (Separated) CanHandle(ifd tiff.IFD) bool {
+	return new(Grayscale).CanHandle(ifd) && ifd.HasField(277)
+}
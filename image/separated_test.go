@@ -0,0 +1,120 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSeparatedCMYK(t *testing.T) {
+	tests := []struct {
+		name string
+		ti   *testIFD
+		want []color.CMYK
+	}{
+		{"default", newTestIFD(binary.BigEndian).image(2, 1, 5, 8, 8, 8, 8).
+			strips(1, []byte{0, 64, 128, 255, 1, 2, 3, 4}),
+			[]color.CMYK{{0, 64, 128, 255}, {1, 2, 3, 4}}},
+		{"InkSet 1", newTestIFD(binary.LittleEndian).image(1, 1, 5, 8, 8, 8, 8).short(332, 1).
+			strips(1, []byte{10, 20, 30, 40}),
+			[]color.CMYK{{10, 20, 30, 40}}},
+		{"DotRange for every ink", newTestIFD(binary.BigEndian).image(2, 1, 5, 8, 8, 8, 8).short(336, 16, 235).
+			strips(1, []byte{16, 235, 0, 255, 16, 16, 235, 235}),
+			[]color.CMYK{{0, 255, 0, 255}, {0, 0, 255, 255}}},
+		{"DotRange of Bytes", newTestIFD(binary.LittleEndian).image(1, 1, 5, 8, 8, 8, 8).field(336, 1, 2, []byte{16, 235}).
+			strips(1, []byte{16, 235, 0, 255}),
+			[]color.CMYK{{0, 255, 0, 255}}},
+		{"DotRange for each ink", newTestIFD(binary.BigEndian).image(1, 1, 5, 8, 8, 8, 8).short(336, 0, 100, 0, 200, 50, 250, 0, 255).
+			strips(1, []byte{50, 100, 150, 255}),
+			[]color.CMYK{{128, 128, 128, 255}}},
+		{"4 bits", newTestIFD(binary.BigEndian).image(1, 1, 5, 4, 4, 4, 4).
+			strips(1, []byte{0x0f, 0x50}),
+			[]color.CMYK{{0, 255, 85, 0}}},
+	}
+	for _, tt := range tests {
+		m, err := Decode(bytes.NewReader(testTIFF(t, tt.ti)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		cmyk, ok := m.(*image.CMYK)
+		if !ok {
+			t.Errorf("%s: decoded as %T, want *image.CMYK", tt.name, m)
+			continue
+		}
+		for x, want := range tt.want {
+			if got := cmyk.CMYKAt(x, 0); got != want {
+				t.Errorf("%s: pixel %d is %v, want %v", tt.name, x, got, want)
+			}
+		}
+	}
+}
+
+func TestSeparatedInks(t *testing.T) {
+	names := []byte("Red\x00Green\x00Blue\x00")
+	tests := []struct {
+		name     string
+		ti       *testIFD
+		inkSet   uint16
+		inkNames []string
+		samples  [][]float64
+		min, max []float64
+	}{
+		{"16 bit CMYK", newTestIFD(binary.LittleEndian).image(1, 1, 5, 16, 16, 16, 16).
+			strips(1, []byte{0x01, 0x00, 0x00, 0x10, 0xff, 0xff, 0x00, 0x00}),
+			1, nil, [][]float64{{1, 0x1000, 0xffff, 0}}, []float64{0, 0, 0, 0}, []float64{0xffff, 0xffff, 0xffff, 0xffff}},
+		{"16 bit CMYK with DotRange", newTestIFD(binary.BigEndian).image(1, 1, 5, 16, 16, 16, 16).short(336, 100, 1000).
+			strips(1, make([]byte, 8)),
+			1, nil, [][]float64{{0, 0, 0, 0}}, []float64{100, 100, 100, 100}, []float64{1000, 1000, 1000, 1000}},
+		{"InkSet 2 with names", newTestIFD(binary.BigEndian).image(2, 1, 5, 8, 8, 8).short(332, 2).short(334, 3).
+			field(333, 2, uint32(len(names)), names).short(336, 0, 100, 0, 200, 0, 255).
+			strips(1, []byte{1, 2, 3, 4, 5, 6}),
+			2, []string{"Red", "Green", "Blue"}, [][]float64{{1, 2, 3}, {4, 5, 6}}, []float64{0, 0, 0}, []float64{100, 200, 255}},
+		{"InkSet 2 with an extra sample", newTestIFD(binary.LittleEndian).image(1, 1, 5, 8, 8, 8).short(332, 2).short(334, 2).
+			strips(1, []byte{7, 8, 9}),
+			2, nil, [][]float64{{7, 8}}, []float64{0, 0}, []float64{255, 255}},
+		{"InkSet 2 without NumberOfInks", newTestIFD(binary.BigEndian).image(1, 1, 5, 8, 8, 8, 8, 8).short(332, 2).
+			strips(1, []byte{1, 2, 3, 4, 5}),
+			2, nil, [][]float64{{1, 2, 3, 4, 5}}, []float64{0, 0, 0, 0, 0}, []float64{255, 255, 255, 255, 255}},
+	}
+	for _, tt := range tests {
+		m, err := Decode(bytes.NewReader(testTIFF(t, tt.ti)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		inks, ok := m.(*Inks)
+		if !ok {
+			t.Errorf("%s: decoded as %T, want *Inks", tt.name, m)
+			continue
+		}
+		if inks.InkSet != tt.inkSet {
+			t.Errorf("%s: InkSet %d, want %d", tt.name, inks.InkSet, tt.inkSet)
+		}
+		if fmt.Sprint(inks.InkNames) != fmt.Sprint(tt.inkNames) {
+			t.Errorf("%s: InkNames %q, want %q", tt.name, inks.InkNames, tt.inkNames)
+		}
+		for x, want := range tt.samples {
+			if got := inks.Samples(x, 0); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%s: pixel %d is %v, want %v", tt.name, x, got, want)
+			}
+		}
+		if fmt.Sprint(inks.Min, inks.Max) != fmt.Sprint(tt.min, tt.max) {
+			t.Errorf("%s: ranges %v to %v, want %v to %v", tt.name, inks.Min, inks.Max, tt.min, tt.max)
+		}
+	}
+}
+
+func TestSeparatedErrors(t *testing.T) {
+	ti := newTestIFD(binary.BigEndian).image(1, 1, 5, 8, 8, 8, 8).short(334, 5).strips(1, make([]byte, 4))
+	if _, err := Decode(bytes.NewReader(testTIFF(t, ti))); err == nil {
+		t.Error("NumberOfInks larger than SamplesPerPixel decoded without an error")
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/big"
+
+	"github.com/google/tiff"
+)
+
+/* YCbCr Images
+
+Tag 262 (PhotometricInterpretation)
+	6 = YCbCr
+
+Tag 277 (SamplesPerPixel)
+	3 = Y, Cb and Cr
+
+Tag 258 (BitsPerSample)
+	8, 8, 8
+
+Tag 529 (YCbCrCoefficients)
+	Type: Rational
+	Note: LumaRed, LumaGreen and LumaBlue, the coefficients of R, G and B in
+		Y.  Default is 299/1000, 587/1000 and 114/1000 (ITU-R BT.601).
+
+Tag 530 (YCbCrSubSampling)
+	Note: The horizontal and vertical subsampling of the chroma samples, each
+		1, 2 or 4.  Default is 2, 2.
+	Note: TIFF 6.0 does not allow a vertical subsampling greater than the
+		horizontal one, but such images are decoded as well.
+
+Tag 531 (YCbCrPositioning)
+	1 = Centered (default): each chroma sample is at the center of the luma
+		samples it covers.
+	2 = Cosited: each chroma sample is at its first luma sample.
+
+Tag 532 (ReferenceBlackWhite)
+	Type: Rational
+	Note: The codes of the reference black and white of Y, then of Cb, then
+		of Cr.  Default is 0, 255, 128, 255, 128, 255 (full range).
+
+Data Units
+	With PlanarConfiguration 1, the samples are stored as data units that
+	each cover a block of YCbCrSubSampling pixels: the luma samples of the
+	block row by row, followed by one Cb and one Cr sample.  The data units
+	of a strip or tile are stored row by row.  Strips and tiles are padded to
+	whole data units.
+
+	With PlanarConfiguration 2, every plane is stored at full size and
+	YCbCrSubSampling is not used, as with libtiff.
+
+Notes
+	Compressed data is decompressed into data units, including JPEG (6 and
+	7) data.  ReferenceBlackWhite is then applied, so that the codes are full
+	range.  An image whose subsampling has an image.YCbCrSubsampleRatio and
+	whose YCbCrCoefficients are those of BT.601 is decoded into an
+	*image.YCbCr, which always assumes centered chroma samples.  Any other
+	image is converted to an *image.RGBA, interpolating the chroma samples
+	from their YCbCrPositioning.
+*/
+
+// ycbcrRatios maps YCbCrSubSampling values to the subsample ratios of
+// image.YCbCr.
+var ycbcrRatios = map[[2]int]image.YCbCrSubsampleRatio{
+	{1, 1}: image.YCbCrSubsampleRatio444,
+	{2, 1}: image.YCbCrSubsampleRatio422,
+	{2, 2}: image.YCbCrSubsampleRatio420,
+	{1, 2}: image.YCbCrSubsampleRatio440,
+	{4, 1}: image.YCbCrSubsampleRatio411,
+	{4, 2}: image.YCbCrSubsampleRatio410,
+}
+
+// ycbcrPlanes holds the decoded planes of a YCbCr image.  The chroma planes
+// are cw by ch samples, each covering a block of h by v pixels.
+type ycbcrPlanes struct {
+	y, cb, cr []byte
+	w, ht     int
+	cw, ch    int
+	h, v      int
+}
+
+type ycbcrDecoder struct {
+	grayscaleDecoder    `tiff:"ifd"`
+	SamplesPerPixel     uint16     `tiff:"field,tag=277"`
+	YCbCrCoefficients   []*big.Rat `tiff:"field,tag=529"`
+	YCbCrPositioning    uint16     `tiff:"field,tag=531"`
+	ReferenceBlackWhite []*big.Rat `tiff:"field,tag=532"`
+}
+
+// subSampling returns the YCbCrSubSampling values.
+func (yd *ycbcrDecoder) subSampling() (h, v int, err error) {
+	h, v = 2, 2
+	if len(yd.YCbCrSubSampling) == 2 {
+		h, v = int(yd.YCbCrSubSampling[0]), int(yd.YCbCrSubSampling[1])
+	}
+	valid := func(n int) bool { return n == 1 || n == 2 || n == 4 }
+	if !valid(h) || !valid(v) {
+		return 0, 0, fmt.Errorf("tiff/image: unsupported YCbCrSubSampling value: %v", yd.YCbCrSubSampling)
+	}
+	return h, v, nil
+}
+
+// coefficients returns LumaRed, LumaGreen and LumaBlue.
+func (yd *ycbcrDecoder) coefficients() (lr, lg, lb float64) {
+	if len(yd.YCbCrCoefficients) != 3 {
+		return 0.299, 0.587, 0.114
+	}
+	lr, _ = yd.YCbCrCoefficients[0].Float64()
+	lg, _ = yd.YCbCrCoefficients[1].Float64()
+	lb, _ = yd.YCbCrCoefficients[2].Float64()
+	return lr, lg, lb
+}
+
+// standard reports whether the image can be decoded into an *image.YCbCr.
+func (yd *ycbcrDecoder) standard() bool {
+	h, v, err := yd.subSampling()
+	if err != nil {
+		return false
+	}
+	if _, ok := ycbcrRatios[[2]int{h, v}]; !ok {
+		return false
+	}
+	lr, lg, lb := yd.coefficients()
+	return math.Abs(lr-0.299) < 1e-3 && math.Abs(lg-0.587) < 1e-3 && math.Abs(lb-0.114) < 1e-3
+}
+
+// planes decodes the Y, Cb and Cr planes, as they are stored.
+func (yd *ycbcrDecoder) planes() (*ycbcrPlanes, error) {
+	r, err := newRaster(&yd.bilevelDecoder, yd.SamplesPerPixel, yd.BitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	if r.samplesPerPixel != 3 {
+		return nil, fmt.Errorf("tiff/image: unsupported SamplesPerPixel value for YCbCr: %d", r.samplesPerPixel)
+	}
+	if r.bitsPerSample != 8 || r.keepsValues() {
+		return nil, fmt.Errorf("tiff/image: unsupported BitsPerSample value for YCbCr: %d", r.bitsPerSample)
+	}
+	h, v, err := yd.subSampling()
+	if err != nil {
+		return nil, err
+	}
+	if r.planar {
+		h, v = 1, 1
+	}
+	p := &ycbcrPlanes{
+		w: r.width, ht: r.height,
+		cw: (r.width + h - 1) / h, ch: (r.height + v - 1) / v,
+		h: h, v: v,
+	}
+	p.y = make([]byte, p.w*p.ht)
+	p.cb = make([]byte, p.cw*p.ch)
+	p.cr = make([]byte, p.cw*p.ch)
+	if r.planar {
+		data, err := r.decode()
+		if err != nil {
+			return nil, err
+		}
+		for i := range p.y {
+			p.y[i], p.cb[i], p.cr[i] = data[3*i], data[3*i+1], data[3*i+2]
+		}
+		return p, nil
+	}
+	if r.predictor > 1 && (h != 1 || v != 1) {
+		return nil, fmt.Errorf("tiff/image: Predictor %d does not support subsampled YCbCr data", r.predictor)
+	}
+	r.subSampling, r.ycbcrUnits = [2]int{h, v}, true
+	if err := p.decodeUnits(r); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// decodeUnits reads the data units of every chunk of r into the planes.
+func (p *ycbcrPlanes) decodeUnits(r *raster) error {
+	comp := GetCompression(r.compression)
+	if comp == nil {
+		return CompressionNotSupported{r.compression}
+	}
+	size := p.h*p.v + 2
+	across, down := r.chunksAcross(), r.chunksDown()
+	for cy := 0; cy < down; cy++ {
+		for cx := 0; cx < across; cx++ {
+			i := cy*across + cx
+			data, err := r.readChunk(comp, i)
+			if err != nil {
+				return fmt.Errorf("tiff/image: chunk %d: %v", i, err)
+			}
+			x0, y0 := cx*r.chunkWidth, cy*r.chunkHeight
+			rows := r.chunkHeight
+			if !r.tiled && y0+rows > r.height {
+				rows = r.height - y0
+			}
+			unitsAcross, unitsDown := (r.chunkWidth+p.h-1)/p.h, (rows+p.v-1)/p.v
+			if want := unitsAcross * unitsDown * size; len(data) < want {
+				return fmt.Errorf("tiff/image: chunk %d: got %d bytes of image data, want %d", i, len(data), want)
+			}
+			for uy := 0; uy < unitsDown; uy++ {
+				for ux := 0; ux < unitsAcross; ux++ {
+					unit := data[(uy*unitsAcross+ux)*size:]
+					bx, by := x0+ux*p.h, y0+uy*p.v
+					for y := 0; y < p.v && by+y < p.ht; y++ {
+						for x := 0; x < p.h && bx+x < p.w; x++ {
+							p.y[(by+y)*p.w+bx+x] = unit[y*p.h+x]
+						}
+					}
+					if cx, cy := bx/p.h, by/p.v; cx < p.cw && cy < p.ch {
+						p.cb[cy*p.cw+cx] = unit[p.h*p.v]
+						p.cr[cy*p.cw+cx] = unit[p.h*p.v+1]
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyReferenceBlackWhite maps the codes of the planes to full range codes.
+func (yd *ycbcrDecoder) applyReferenceBlackWhite(p *ycbcrPlanes) {
+	if len(yd.ReferenceBlackWhite) != 6 {
+		return
+	}
+	var rbw [6]float64
+	for i, v := range yd.ReferenceBlackWhite {
+		rbw[i], _ = v.Float64()
+	}
+	if rbw == [6]float64{0, 255, 128, 255, 128, 255} {
+		return
+	}
+	code := func(plane []byte, black, white, codeRange, offset float64) {
+		if white == black {
+			return
+		}
+		for i, c := range plane {
+			v := (float64(c)-black)*codeRange/(white-black) + offset
+			plane[i] = uint8(math.Max(0, math.Min(255, math.Floor(v+0.5))))
+		}
+	}
+	code(p.y, rbw[0], rbw[1], 255, 0)
+	code(p.cb, rbw[2], rbw[3], 127, 128)
+	code(p.cr, rbw[4], rbw[5], 127, 128)
+}
+
+func (yd *ycbcrDecoder) Image() (image.Image, error) {
+	if yd.img == nil {
+		p, err := yd.planes()
+		if err != nil {
+			return nil, err
+		}
+		yd.applyReferenceBlackWhite(p)
+		if yd.standard() {
+			yd.img = &image.YCbCr{
+				Y:              p.y,
+				Cb:             p.cb,
+				Cr:             p.cr,
+				YStride:        p.w,
+				CStride:        p.cw,
+				SubsampleRatio: ycbcrRatios[[2]int{p.h, p.v}],
+				Rect:           image.Rect(0, 0, p.w, p.ht),
+			}
+		} else {
+			yd.img = yd.toRGBA(p)
+		}
+	}
+	return yd.img, nil
+}
+
+// toRGBA converts the planes to RGB with the YCbCrCoefficients, linearly
+// interpolating the chroma samples between their positions.
+func (yd *ycbcrDecoder) toRGBA(p *ycbcrPlanes) *image.RGBA {
+	lr, lg, lb := yd.coefficients()
+	cosited := yd.YCbCrPositioning == 2
+	// chroma returns the two chroma samples around luma sample i and the
+	// weight of the second one, along an axis with n chroma samples of
+	// size luma samples each.
+	chroma := func(i, size, n int) (i0, i1 int, f float64) {
+		t := float64(i)
+		if !cosited {
+			t -= float64(size-1) / 2
+		}
+		t /= float64(size)
+		if t < 0 {
+			t = 0
+		}
+		i0 = int(t)
+		f = t - float64(i0)
+		i1 = i0 + 1
+		if i0 >= n-1 {
+			i0, i1, f = n-1, n-1, 0
+		}
+		return i0, i1, f
+	}
+	at := func(plane []byte, x0, x1, y0, y1 int, fx, fy float64) float64 {
+		top := float64(plane[y0*p.cw+x0])*(1-fx) + float64(plane[y0*p.cw+x1])*fx
+		bottom := float64(plane[y1*p.cw+x0])*(1-fx) + float64(plane[y1*p.cw+x1])*fx
+		return top*(1-fy) + bottom*fy - 128
+	}
+	clamp := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Floor(v+0.5))))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, p.w, p.ht))
+	for y := 0; y < p.ht; y++ {
+		y0, y1, fy := chroma(y, p.v, p.ch)
+		for x := 0; x < p.w; x++ {
+			x0, x1, fx := chroma(x, p.h, p.cw)
+			yy := float64(p.y[y*p.w+x])
+			cb := at(p.cb, x0, x1, y0, y1, fx, fy)
+			cr := at(p.cr, x0, x1, y0, y1, fx, fy)
+			r := yy + cr*(2-2*lr)
+			b := yy + cb*(2-2*lb)
+			g := (yy - lb*b - lr*r) / lg
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = clamp(r), clamp(g), clamp(b), 0xff
+		}
+	}
+	return img
+}
+
+func (yd *ycbcrDecoder) Config() (cfg image.Config, err error) {
+	cfg.Height = int(yd.ImageLength)
+	cfg.Width = int(yd.ImageWidth)
+	cfg.ColorModel = color.RGBAModel
+	if yd.standard() {
+		cfg.ColorModel = color.YCbCrModel
+	}
+	return
+}
+
+func (yd *ycbcrDecoder) Planes() int {
+	return 3
+}
+
+// Plane returns the Y (0), Cb (1) or Cr (2) plane as it is stored.  The
+// chroma planes are subsampled.
+func (yd *ycbcrDecoder) Plane(i int) (image.Image, error) {
+	p, err := yd.planes()
+	if err != nil {
+		return nil, err
+	}
+	switch i {
+	case 0:
+		return &image.Gray{Pix: p.y, Stride: p.w, Rect: image.Rect(0, 0, p.w, p.ht)}, nil
+	case 1:
+		return &image.Gray{Pix: p.cb, Stride: p.cw, Rect: image.Rect(0, 0, p.cw, p.ch)}, nil
+	case 2:
+		return &image.Gray{Pix: p.cr, Stride: p.cw, Rect: image.Rect(0, 0, p.cw, p.ch)}, nil
+	}
+	return nil, fmt.Errorf("tiff/image: no plane %d in an image with 3 samples per pixel", i)
+}
+
+type YCbCr struct{}
+
+func (YCbCr) Decoder(ifd tiff.IFD, br tiff.BReader) (dec Decoder, err error) {
+	ycbcrDec := &ycbcrDecoder{grayscaleDecoder: grayscaleDecoder{bilevelDecoder: bilevelDecoder{br: br}}}
+	if err = tiff.UnmarshalIFD(ifd, ycbcrDec); err != nil {
+		return
+	}
+	return ycbcrDec, nil
+}
+
+func (YCbCr) CanHandle(ifd tiff.IFD) bool {
+	return new(Grayscale).CanHandle(ifd) && ifd.HasField(277)
+}
@@ -0,0 +1,184 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ycbcrStrips packs the planes of a w by ht image, whose chroma planes are
+// subsampled by h and v, into strips of data units of rows rows each.  Luma
+// samples past the edges are padded with 0xee.
+func ycbcrStrips(y, cb, cr []byte, w, ht, h, v, rows int) [][]byte {
+	cw := (w + h - 1) / h
+	var strips [][]byte
+	for y0 := 0; y0 < ht; y0 += rows {
+		var strip []byte
+		for by := y0; by < y0+rows && by < ht; by += v {
+			for bx := 0; bx < w; bx += h {
+				for j := 0; j < v; j++ {
+					for i := 0; i < h; i++ {
+						if bx+i < w && by+j < ht {
+							strip = append(strip, y[(by+j)*w+bx+i])
+						} else {
+							strip = append(strip, 0xee)
+						}
+					}
+				}
+				c := by/v*cw + bx/h
+				strip = append(strip, cb[c], cr[c])
+			}
+		}
+		strips = append(strips, strip)
+	}
+	return strips
+}
+
+func TestYCbCrSubSampling(t *testing.T) {
+	const w, ht = 7, 5
+	tests := []struct {
+		h, v  int
+		ratio image.YCbCrSubsampleRatio
+	}{
+		{1, 1, image.YCbCrSubsampleRatio444},
+		{2, 1, image.YCbCrSubsampleRatio422},
+		{2, 2, image.YCbCrSubsampleRatio420},
+		{4, 2, image.YCbCrSubsampleRatio410},
+	}
+	for _, tt := range tests {
+		cw, ch := (w+tt.h-1)/tt.h, (ht+tt.v-1)/tt.v
+		y, cb, cr := make([]byte, w*ht), make([]byte, cw*ch), make([]byte, cw*ch)
+		for i := range y {
+			y[i] = byte(3 * i)
+		}
+		for i := range cb {
+			cb[i], cr[i] = byte(100+i), byte(200-i)
+		}
+		for _, rows := range []int{ht, tt.v} {
+			for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+				name := fmt.Sprintf("%dx%d, %d rows per strip, %v", tt.h, tt.v, rows, bo)
+				ti := newTestIFD(bo).image(w, ht, 6, 8, 8, 8).short(530, uint32(tt.h), uint32(tt.v)).
+					strips(uint32(rows), ycbcrStrips(y, cb, cr, w, ht, tt.h, tt.v, rows)...)
+				m, err := Decode(bytes.NewReader(testTIFF(t, ti)))
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+					continue
+				}
+				got, ok := m.(*image.YCbCr)
+				if !ok {
+					t.Errorf("%s: decoded as %T, want *image.YCbCr", name, m)
+					continue
+				}
+				if got.SubsampleRatio != tt.ratio {
+					t.Errorf("%s: subsample ratio %v, want %v", name, got.SubsampleRatio, tt.ratio)
+				}
+				if got.Rect != image.Rect(0, 0, w, ht) {
+					t.Errorf("%s: bounds %v", name, got.Rect)
+				}
+				if !bytes.Equal(got.Y, y) || !bytes.Equal(got.Cb, cb) || !bytes.Equal(got.Cr, cr) {
+					t.Errorf("%s: got planes % x, % x, % x, want % x, % x, % x", name, got.Y, got.Cb, got.Cr, y, cb, cr)
+				}
+			}
+		}
+	}
+}
+
+func TestYCbCrReferenceBlackWhite(t *testing.T) {
+	tests := []struct {
+		name       string
+		rbw        []uint32
+		y, cb, cr  []byte
+		wy, wb, wr []byte
+	}{
+		// Video range: Y from 16 to 235 and chroma from 16 to 240.
+		{"video range", []uint32{16, 1, 235, 1, 128, 1, 240, 1, 128, 1, 240, 1},
+			[]byte{16, 235, 126}, []byte{128, 240, 16}, []byte{16, 128, 240},
+			[]byte{0, 255, 128}, []byte{128, 255, 1}, []byte{1, 128, 255}},
+		{"clamped", []uint32{16, 1, 235, 1, 128, 1, 240, 1, 128, 1, 240, 1},
+			[]byte{0, 255, 16}, []byte{0, 255, 128}, []byte{128, 128, 128},
+			[]byte{0, 255, 0}, []byte{0, 255, 128}, []byte{128, 128, 128}},
+		{"default", []uint32{0, 1, 255, 1, 128, 1, 255, 1, 128, 1, 255, 1},
+			[]byte{16, 235, 126}, []byte{128, 240, 16}, []byte{16, 128, 240},
+			[]byte{16, 235, 126}, []byte{128, 240, 16}, []byte{16, 128, 240}},
+	}
+	for _, tt := range tests {
+		ti := newTestIFD(binary.BigEndian).image(3, 1, 6, 8, 8, 8).short(530, 1, 1).rational(532, tt.rbw...).
+			strips(1, ycbcrStrips(tt.y, tt.cb, tt.cr, 3, 1, 1, 1, 1)...)
+		m, err := Decode(bytes.NewReader(testTIFF(t, ti)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, ok := m.(*image.YCbCr)
+		if !ok {
+			t.Errorf("%s: decoded as %T, want *image.YCbCr", tt.name, m)
+			continue
+		}
+		if !bytes.Equal(got.Y, tt.wy) || !bytes.Equal(got.Cb, tt.wb) || !bytes.Equal(got.Cr, tt.wr) {
+			t.Errorf("%s: got planes %v, %v, %v, want %v, %v, %v", tt.name, got.Y, got.Cb, got.Cr, tt.wy, tt.wb, tt.wr)
+		}
+	}
+}
+
+func TestYCbCrModel(t *testing.T) {
+	be := binary.BigEndian
+	gray := ycbcrStrips([]byte{100, 100, 100, 100}, []byte{128}, []byte{128}, 4, 1, 4, 1, 1)
+	tests := []struct {
+		name  string
+		ti    *testIFD
+		model color.Model
+		want  []color.RGBA
+	}{
+		{"BT.601", newTestIFD(be).image(1, 1, 6, 8, 8, 8).short(530, 1, 1).
+			rational(529, 299, 1000, 587, 1000, 114, 1000).strips(1, []byte{100, 128, 50}),
+			color.YCbCrModel, nil},
+		{"planar", newTestIFD(be).image(2, 1, 6, 8, 8, 8).short(284, 2).
+			strips(1, []byte{10, 20}, []byte{128, 128}, []byte{128, 128}),
+			color.YCbCrModel, nil},
+		// BT.709 coefficients with Cr 78 below 128: r = 100 - 78*(2-2*0.2126)
+		// is clamped to 0, and g = (100 - 0.0722*100 - 0.2126*r) / 0.7152.
+		{"BT.709", newTestIFD(be).image(1, 1, 6, 8, 8, 8).short(530, 1, 1).
+			rational(529, 2126, 10000, 7152, 10000, 722, 10000).strips(1, []byte{100, 128, 50}),
+			color.RGBAModel, []color.RGBA{{0, 137, 100, 0xff}}},
+		{"4x1 of BT.709", newTestIFD(be).image(4, 1, 6, 8, 8, 8).short(530, 4, 1).
+			rational(529, 2126, 10000, 7152, 10000, 722, 10000).strips(1, gray...),
+			color.RGBAModel, []color.RGBA{{100, 100, 100, 0xff}, {100, 100, 100, 0xff}, {100, 100, 100, 0xff}, {100, 100, 100, 0xff}}},
+		{"no subsample ratio", newTestIFD(be).image(4, 1, 6, 8, 8, 8).short(530, 4, 4).
+			strips(1, ycbcrStrips([]byte{100, 100, 100, 100}, []byte{128}, []byte{128}, 4, 1, 4, 4, 4)...),
+			color.RGBAModel, []color.RGBA{{100, 100, 100, 0xff}, {100, 100, 100, 0xff}, {100, 100, 100, 0xff}, {100, 100, 100, 0xff}}},
+	}
+	for _, tt := range tests {
+		b := testTIFF(t, tt.ti)
+		cfg, err := DecodeConfig(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: config: %v", tt.name, err)
+			continue
+		}
+		if cfg.ColorModel != tt.model {
+			t.Errorf("%s: config has color model %v, want %v", tt.name, cfg.ColorModel, tt.model)
+		}
+		m, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if m.ColorModel() != tt.model {
+			t.Errorf("%s: decoded as %T", tt.name, m)
+			continue
+		}
+		if rgba, ok := m.(*image.RGBA); ok {
+			for x, want := range tt.want {
+				if got := rgba.RGBAAt(x, 0); got != want {
+					t.Errorf("%s: pixel %d is %v, want %v", tt.name, x, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+/*
+JSON Schema
+  ExportJSON converts a whole TIFF into the JSONTIFF structure below and
+  ImportJSON rebuilds a TIFF from it.  The structure is meant to be used with
+  encoding/json.  Only the keys listed here are used.
+
+  TIFF (JSONTIFF):
+    "order":       "II" or "MM".
+    "version":     The version from the header (i.e. 42).
+    "offsetSize":  The size of offsets in bytes (informational only).
+    "firstOffset": The offset of the first IFD (informational only).
+    "ifds":        The list of IFDs in the main chain, in order.
+
+  IFD (JSONIFD):
+    "tagSpace":    The name of the TagSpace the fields were looked up in.
+    "fields":      The list of fields, sorted by tag.
+    "subIFDs":     An object mapping the id of each sub-IFD tag (i.e. "330" or
+                   "34665") to the list of IFDs it points to.
+
+  Field (JSONField):
+    "tag":         The numeric tag id.  This is the only key used to
+                   identify the tag when importing.
+    "name":        The tag name from the TagSpace (informational only).
+    "typeID":      The numeric field type id.
+    "type":        The field type name (informational only).
+    "count":       The number of values.
+    Exactly one of the following holds the value:
+    "text":        For ASCII.  The text without its terminating NUL.  Any
+                   other NUL, separating multiple strings or padding the
+                   text, is kept.  The length of the text must be "count"
+                   or one less, for the terminating NUL.
+    "values":      For integer, rational and floating point types.  A list of
+                   numbers, except rationals which are strings of the form
+                   "n/d" and floating point values that are not finite, which
+                   are the strings "NaN", "+Inf" and "-Inf".
+    "bytes":       For Undefined and any other type, the raw bytes in base64.
+    Fields of offset tags (i.e. StripOffsets, TileOffsets or JPEGQTables)
+    also have:
+    "data":        The list of blocks the offsets point to.
+
+  Block (JSONBlock):
+    "offset":      The offset of the block in the original file.
+    "length":      The length of the block in bytes.
+    "base64":      The block itself, only present when data is included.
+
+  When importing, the values of offset tags, their byte count tags and sub-IFD
+  tags are rebuilt from "data" and "subIFDs".  Blocks without "base64" are read
+  from the source given to ImportJSON at their "offset", or filled with zeros
+  when there is no source.  Only version 42 TIFFs can be rebuilt, so importing
+  any other version (i.e. an exported BigTIFF) fails.
+*/
+
+type JSONTIFF struct {
+	Order       string     `json:"order"`
+	Version     uint16     `json:"version"`
+	OffsetSize  uint16     `json:"offsetSize,omitempty"`
+	FirstOffset uint64     `json:"firstOffset,omitempty"`
+	IFDs        []*JSONIFD `json:"ifds"`
+}
+
+type JSONIFD struct {
+	TagSpace string                `json:"tagSpace"`
+	Fields   []*JSONField          `json:"fields"`
+	SubIFDs  map[uint16][]*JSONIFD `json:"subIFDs,omitempty"`
+}
+
+type JSONField struct {
+	Tag    uint16            `json:"tag"`
+	Name   string            `json:"name,omitempty"`
+	TypeID uint16            `json:"typeID"`
+	Type   string            `json:"type,omitempty"`
+	Count  uint64            `json:"count"`
+	Text   *string           `json:"text,omitempty"`
+	Values []json.RawMessage `json:"values,omitempty"`
+	Bytes  []byte            `json:"bytes,omitempty"`
+	Data   []*JSONBlock      `json:"data,omitempty"`
+}
+
+type JSONBlock struct {
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+	Base64 []byte `json:"base64,omitempty"`
+}
+
+// hasJSONValues reports whether the values of ft are represented as a list of
+// "values" as opposed to "text" or "bytes".
+func hasJSONValues(ft FieldType) bool {
+	if ft.ID() == 7 { // Undefined
+		return false
+	}
+	switch ft.ReflectType().Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(ft.ReflectType().Size()) == ft.Size()
+	case reflect.Float32:
+		return ft.Size() == 4
+	case reflect.Float64:
+		return ft.Size() == 8
+	}
+	return ft.ReflectType() == bigRatType && ft.Size() == 8
+}
+
+func jsonValue(ft FieldType, b []byte, bo binary.ByteOrder) (json.RawMessage, error) {
+	var v interface{}
+	rv := ft.Valuer()(b, bo)
+	switch rv.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v = rv.Uint()
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v = rv.Int()
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case math.IsNaN(f):
+			v = "NaN"
+		case math.IsInf(f, 1):
+			v = "+Inf"
+		case math.IsInf(f, -1):
+			v = "-Inf"
+		case rv.Kind() == reflect.Float32:
+			// Keep the shortest representation of the float32.
+			return json.RawMessage(strconv.FormatFloat(f, 'g', -1, 32)), nil
+		default:
+			v = f
+		}
+	default:
+		// Rationals are taken directly from the representation to keep
+		// values with a denominator of 0.
+		v = ft.Repr()(b, bo)
+	}
+	return json.Marshal(v)
+}
+
+func exportJSONField(f Field, withData bool, ifd IFD, r io.ReaderAt) (*JSONField, error) {
+	ft := f.Type()
+	jf := &JSONField{
+		Tag:    f.Tag().ID(),
+		Name:   f.Tag().Name(),
+		TypeID: ft.ID(),
+		Type:   ft.Name(),
+		Count:  f.Count(),
+	}
+	size := ft.Size()
+	b := f.Value().Bytes()
+	if uint64(len(b)) > size*f.Count() {
+		b = b[:size*f.Count()]
+	}
+	switch {
+	case ft.ReflectType().Kind() == reflect.String:
+		text := string(bytes.TrimSuffix(b, []byte{0}))
+		jf.Text = &text
+	case hasJSONValues(ft):
+		for ; uint64(len(b)) >= size && size > 0; b = b[size:] {
+			v, err := jsonValue(ft, b[:size], f.Value().Order())
+			if err != nil {
+				return nil, err
+			}
+			jf.Values = append(jf.Values, v)
+		}
+	default:
+		jf.Bytes = append([]byte{}, b...)
+	}
+
+	bcTagID, ok := GetByteCountTag(jf.Tag)
+	if ok && !ifd.HasField(bcTagID) || !ok && !isJPEGTableTag(jf.Tag) {
+		return jf, nil
+	}
+	offsets, counts, err := offsetBlocks(r, ifd, jf.Tag)
+	if err != nil {
+		return nil, err
+	}
+	jf.Data = make([]*JSONBlock, len(offsets))
+	for i := range offsets {
+		blk := &JSONBlock{Offset: offsets[i], Length: counts[i]}
+		if withData {
+			blk.Base64 = make([]byte, counts[i])
+			if _, err := r.ReadAt(blk.Base64, int64(offsets[i])); err != nil {
+				return nil, fmt.Errorf("tiff: unable to read block %d for tag %d: %v", i, jf.Tag, err)
+			}
+		}
+		jf.Data[i] = blk
+	}
+	return jf, nil
+}
+
+func exportJSONIFD(t TIFF, ifd IFD, tsp TagSpace, withData bool, depth int) (*JSONIFD, error) {
+	ji := &JSONIFD{TagSpace: tsp.Name()}
+	for _, f := range ifd.Fields() {
+		jf, err := exportJSONField(f, withData, ifd, t.R())
+		if err != nil {
+			return nil, err
+		}
+		ji.Fields = append(ji.Fields, jf)
+	}
+	if depth >= maxSubIFDDepth {
+		return ji, nil
+	}
+	for _, tagID := range ListSubIFDTags() {
+		if !ifd.HasField(tagID) {
+			continue
+		}
+		subs, err := ParseSubIFDs(t, ifd, tagID)
+		if err != nil {
+			return nil, err
+		}
+		subTSP, _ := GetSubIFDTagSpace(tagID)
+		for _, sub := range subs {
+			jsub, err := exportJSONIFD(t, sub, subTSP, withData, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if ji.SubIFDs == nil {
+				ji.SubIFDs = make(map[uint16][]*JSONIFD, 1)
+			}
+			ji.SubIFDs[tagID] = append(ji.SubIFDs[tagID], jsub)
+		}
+	}
+	return ji, nil
+}
+
+// ExportJSON converts t into a JSONTIFF (see JSON Schema).  The tsp is the
+// TagSpace t was parsed with.  If tsp is nil, the DefaultTagSpace is assumed.
+// The blocks of image data are included when withData is true.
+func ExportJSON(t TIFF, tsp TagSpace, withData bool) (*JSONTIFF, error) {
+	if tsp == nil {
+		tsp = DefaultTagSpace
+	}
+	jt := &JSONTIFF{
+		Order:       t.Order(),
+		Version:     t.Version(),
+		OffsetSize:  t.OffsetSize(),
+		FirstOffset: t.FirstOffset(),
+	}
+	for _, ifd := range t.IFDs() {
+		ji, err := exportJSONIFD(t, ifd, tsp, withData, 0)
+		if err != nil {
+			return nil, err
+		}
+		jt.IFDs = append(jt.IFDs, ji)
+	}
+	return jt, nil
+}
+
+// zeroReaderAt reads zeros for any offset.
+type zeroReaderAt struct{}
+
+func (zeroReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func importJSONField(jf *JSONField, bo binary.ByteOrder, ftsp FieldTypeSpace) ([]byte, error) {
+	ft := ftsp.GetFieldType(jf.TypeID)
+	size := ft.Size() * jf.Count
+	switch {
+	case jf.Text != nil:
+		n := uint64(len(*jf.Text))
+		if n != jf.Count && n+1 != jf.Count {
+			return nil, fmt.Errorf("tiff: field %d has %d bytes of text, but a count of %d", jf.Tag, n, jf.Count)
+		}
+		b := make([]byte, size)
+		copy(b, *jf.Text)
+		return b, nil
+	case jf.Values != nil:
+		if uint64(len(jf.Values)) != jf.Count {
+			return nil, fmt.Errorf("tiff: field %d has %d values, but a count of %d", jf.Tag, len(jf.Values), jf.Count)
+		}
+		out := make([]byte, 0, size)
+		for _, raw := range jf.Values {
+			text := string(raw)
+			if len(raw) > 0 && raw[0] == '"' {
+				if err := json.Unmarshal(raw, &text); err != nil {
+					return nil, err
+				}
+			}
+			b, err := encodeOneFieldValue(ft, text, bo)
+			if err != nil {
+				return nil, fmt.Errorf("tiff: field %d: %v", jf.Tag, err)
+			}
+			out = append(out, b...)
+		}
+		return out, nil
+	}
+	if uint64(len(jf.Bytes)) != size {
+		return nil, fmt.Errorf("tiff: field %d has %d bytes, but needs %d", jf.Tag, len(jf.Bytes), size)
+	}
+	return jf.Bytes, nil
+}
+
+func importJSONIFD(ji *JSONIFD, bo binary.ByteOrder, src io.ReaderAt, tsp TagSpace, depth int) (*WritableIFD, error) {
+	if depth > maxSubIFDDepth {
+		return nil, fmt.Errorf("tiff: sub-IFDs nested deeper than %d", maxSubIFDDepth)
+	}
+	if ji.TagSpace != "" && ji.TagSpace != tsp.Name() {
+		if named := GetTagSpace(ji.TagSpace); named != nil {
+			tsp = named
+		}
+	}
+	wi := &WritableIFD{
+		TagSpace: tsp,
+		Data:     make(map[uint16][]*io.SectionReader, 1),
+		SubIFDs:  make(map[uint16][]*WritableIFD, 1),
+	}
+	for _, jf := range ji.Fields {
+		b, err := importJSONField(jf, bo, DefaultFieldTypeSpace)
+		if err != nil {
+			return nil, err
+		}
+		wi.Fields = append(wi.Fields, NewField(jf.Tag, jf.TypeID, uint32(jf.Count), b, bo, tsp, nil))
+		if jf.Data == nil {
+			continue
+		}
+		blocks := make([]*io.SectionReader, len(jf.Data))
+		for i, blk := range jf.Data {
+			switch {
+			case blk.Base64 != nil:
+				if uint64(len(blk.Base64)) != blk.Length {
+					return nil, fmt.Errorf("tiff: block %d of field %d has %d bytes, but a length of %d", i, jf.Tag, len(blk.Base64), blk.Length)
+				}
+				blocks[i] = io.NewSectionReader(bytes.NewReader(blk.Base64), 0, int64(blk.Length))
+			case src != nil:
+				blocks[i] = io.NewSectionReader(src, int64(blk.Offset), int64(blk.Length))
+			default:
+				blocks[i] = io.NewSectionReader(zeroReaderAt{}, 0, int64(blk.Length))
+			}
+		}
+		wi.Data[jf.Tag] = blocks
+	}
+	for tagID, subs := range ji.SubIFDs {
+		subTSP, ok := GetSubIFDTagSpace(tagID)
+		if !ok {
+			subTSP = DefaultTagSpace
+		}
+		for _, jsub := range subs {
+			wsub, err := importJSONIFD(jsub, bo, src, subTSP, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			wi.SubIFDs[tagID] = append(wi.SubIFDs[tagID], wsub)
+		}
+	}
+	return wi, nil
+}
+
+// ImportJSON rebuilds a TIFF from jt (see JSON Schema).  Blocks of image data
+// that are not included in jt are read from src, or filled with zeros if src is
+// nil.  A version other than 42 returns an ErrUnsuppTIFFVersion.  The TIFF is written to memory and parsed again with the
+// DefaultTagSpace and DefaultFieldTypeSpace.
+func ImportJSON(jt *JSONTIFF, src io.ReaderAt) (TIFF, error) {
+	if len(jt.Order) != 2 {
+		return nil, fmt.Errorf("tiff: invalid byte order %q", jt.Order)
+	}
+	bo := GetByteOrder(binary.BigEndian.Uint16([]byte(jt.Order)))
+	if bo == nil {
+		return nil, ErrInvalidByteOrder{[2]byte{jt.Order[0], jt.Order[1]}}
+	}
+	if jt.Version != Version {
+		return nil, ErrUnsuppTIFFVersion{jt.Version}
+	}
+	wifds := make([]*WritableIFD, 0, len(jt.IFDs))
+	for _, ji := range jt.IFDs {
+		wi, err := importJSONIFD(ji, bo, src, DefaultTagSpace, 0)
+		if err != nil {
+			return nil, err
+		}
+		wifds = append(wifds, wi)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, bo, wifds); err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(buf.Bytes()), nil, nil)
+}
@@ -0,0 +1,192 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// jsonTestFile writes a TIFF with a field of each kind of JSON value, two
+// strips and a SubIFD.
+func jsonTestFile(t *testing.T) TIFF {
+	t.Helper()
+	bo := binary.BigEndian
+	field := func(tagID, typeID uint16, count uint32, value ...byte) Field {
+		return NewField(tagID, typeID, count, value, bo, nil, nil)
+	}
+	double := func(f float64) []byte {
+		var b [8]byte
+		bo.PutUint64(b[:], math.Float64bits(f))
+		return b[:]
+	}
+	var doubles []byte
+	for _, f := range []float64{1.5, math.NaN(), math.Inf(-1)} {
+		doubles = append(doubles, double(f)...)
+	}
+	sub := &WritableIFD{Fields: []Field{
+		field(254, 4, 1, 0, 0, 0, 1),
+		field(256, 3, 1, 0, 2),
+	}}
+	wi := &WritableIFD{
+		Fields: []Field{
+			field(256, 3, 1, 0, 4),
+			field(258, 3, 3, 0, 8, 0, 8, 0, 8),
+			field(269, 2, 8, []byte("one\x00two\x00")...),
+			field(270, 2, 12, []byte("description\x00")...),
+			field(282, 5, 1, 0, 0, 1, 44, 0, 0, 0, 1),
+			field(433, 10, 1, 0xff, 0xff, 0xff, 0xfe, 0, 0, 0, 3),
+			field(347, 7, 3, 0xff, 0xd8, 0),
+			field(65000, 12, 3, doubles...),
+			field(65001, 11, 1, 0x3f, 0xc0, 0, 0),
+			field(65002, 8, 2, 0xff, 0xfe, 0, 2),
+		},
+		Data: map[uint16][]*io.SectionReader{
+			273: {
+				io.NewSectionReader(strings.NewReader("first strip"), 0, 11),
+				io.NewSectionReader(strings.NewReader("second"), 0, 6),
+			},
+		},
+		SubIFDs: map[uint16][]*WritableIFD{330: {sub}},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, bo, []*WritableIFD{wi}); err != nil {
+		t.Fatal(err)
+	}
+	tf, err := Parse(bytes.NewReader(buf.Bytes()), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tf
+}
+
+func marshalJSON(t *testing.T, jt *JSONTIFF) []byte {
+	t.Helper()
+	b, err := json.MarshalIndent(jt, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(b, '\n')
+}
+
+func TestExportJSONGolden(t *testing.T) {
+	jt, err := ExportJSON(jsonTestFile(t), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := marshalJSON(t, jt)
+	golden := filepath.Join("testdata", "export.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("the export differs from %s (run with -update to see why):\n%s", golden, got)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	src := jsonTestFile(t)
+	for _, withData := range []bool{true, false} {
+		jt, err := ExportJSON(src, nil, withData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exported := marshalJSON(t, jt)
+
+		// Import what was exported, as if it came from a file.
+		var back JSONTIFF
+		if err := json.Unmarshal(exported, &back); err != nil {
+			t.Fatal(err)
+		}
+		var from io.ReaderAt
+		if !withData {
+			from = src.R()
+		}
+		imported, err := ImportJSON(&back, from)
+		if err != nil {
+			t.Fatalf("withData %v: %v", withData, err)
+		}
+		jt, err = ExportJSON(imported, nil, withData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again := marshalJSON(t, jt); !bytes.Equal(again, exported) {
+			t.Errorf("withData %v: the import exports as\n%s\nwant\n%s", withData, again, exported)
+		}
+	}
+}
+
+func TestImportJSONWithoutData(t *testing.T) {
+	jt, err := ExportJSON(jsonTestFile(t), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportJSON(jt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without a source, the strips keep their sizes but are zeros.
+	ifd := imported.IFDs()[0]
+	offsets, sizes, err := offsetBlocks(imported.R(), ifd, 273)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != 11 || sizes[1] != 6 {
+		t.Fatalf("the strips have sizes %v, want [11 6]", sizes)
+	}
+	b := make([]byte, sizes[0])
+	imported.R().ReadAt(b, int64(offsets[0]))
+	if !bytes.Equal(b, make([]byte, sizes[0])) {
+		t.Errorf("the first strip is %q, want zeros", b)
+	}
+}
+
+func TestImportJSONErrors(t *testing.T) {
+	text := func(s string) *string { return &s }
+	ifd := func(fields ...*JSONField) []*JSONIFD {
+		return []*JSONIFD{{Fields: fields}}
+	}
+	tests := []struct {
+		name string
+		jt   JSONTIFF
+		want string
+	}{
+		{"byte order", JSONTIFF{Order: "XX", Version: 42}, "byte order"},
+		{"BigTIFF", JSONTIFF{Order: "II", Version: 43}, "unsupported version 43"},
+		{"no version", JSONTIFF{Order: "II"}, "unsupported version 0"},
+		{"text too long", JSONTIFF{Order: "MM", Version: 42, IFDs: ifd(&JSONField{Tag: 270, TypeID: 2, Count: 3, Text: text("abcd")})}, "4 bytes of text"},
+		{"text too short", JSONTIFF{Order: "MM", Version: 42, IFDs: ifd(&JSONField{Tag: 270, TypeID: 2, Count: 6, Text: text("abcd")})}, "4 bytes of text"},
+		{"values", JSONTIFF{Order: "MM", Version: 42, IFDs: ifd(&JSONField{Tag: 258, TypeID: 3, Count: 3, Values: []json.RawMessage{json.RawMessage("8")}})}, "1 values"},
+		{"value", JSONTIFF{Order: "MM", Version: 42, IFDs: ifd(&JSONField{Tag: 258, TypeID: 3, Count: 1, Values: []json.RawMessage{json.RawMessage("70000")}})}, "invalid value"},
+		{"bytes", JSONTIFF{Order: "MM", Version: 42, IFDs: ifd(&JSONField{Tag: 347, TypeID: 7, Count: 3, Bytes: []byte{1}})}, "1 bytes"},
+		{"block", JSONTIFF{Order: "MM", Version: 42, IFDs: ifd(&JSONField{Tag: 273, TypeID: 4, Count: 1, Values: []json.RawMessage{json.RawMessage("0")},
+			Data: []*JSONBlock{{Length: 2, Base64: []byte{1}}}})}, "a length of 2"},
+	}
+	for _, tt := range tests {
+		_, err := ImportJSON(&tt.jt, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := ImportJSON(&JSONTIFF{Order: "II", Version: 43}, nil); err != (ErrUnsuppTIFFVersion{43}) {
+		t.Errorf("BigTIFF: got error %#v, want an ErrUnsuppTIFFVersion", err)
+	}
+}
@@ -66,6 +66,13 @@ func (b *buffer) ReadAt(p []byte, off int64) (int, error) {
 	}
 
 	err := b.fill(end)
+	// When b.r ends early, only the bytes that were read are returned.
+	if end > len(b.buf) {
+		end = len(b.buf)
+	}
+	if o > end {
+		return 0, err
+	}
 	return copy(p, b.buf[o:end]), err
 }
 
@@ -0,0 +1,237 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Sub-IFDs
+  Some tags do not hold data themselves, but instead hold offsets to other IFDs
+  that are not part of the main chain of IFDs.  The SubIFDs tag (id 330) is the
+  most common, but Exif (id 34665), GPS (id 34853) and Interoperability (id
+  40965) IFDs work the same way.  Each of those IFDs may use a different
+  TagSpace.  Packages that define such tags register them with
+  RegisterSubIFDTag so that the IFD tree of a TIFF can be walked.
+
+IFD Paths
+  An IFD path identifies a single IFD in the tree of IFDs of a TIFF.
+    1. The first element is the index of an IFD in TIFF.IFDs().
+    2. Each following element is separated by a '/' and has the form
+       "tag" or "tag:idx" where tag is the id of a registered sub-IFD tag in
+       the parent IFD and idx is the index of the offset within that field.
+       The absence of idx assumes a value of 0.
+  Examples:
+    "0"         The first IFD.
+    "0/34665"   The Exif IFD of the first IFD.
+    "1/330:2"   The third SubIFD of the second IFD.
+*/
+
+var subIFDTags = struct {
+	mu   sync.RWMutex
+	list map[uint16]TagSpace
+}{
+	list: make(map[uint16]TagSpace, 1),
+}
+
+// RegisterSubIFDTag registers tagID as a tag whose values are offsets to IFDs.
+// The fields of those IFDs are looked up in tsp.  If tsp is nil, the
+// DefaultTagSpace is used.
+func RegisterSubIFDTag(tagID uint16, tsp TagSpace) {
+	subIFDTags.mu.Lock()
+	subIFDTags.list[tagID] = tsp
+	subIFDTags.mu.Unlock()
+}
+
+// GetSubIFDTagSpace returns the TagSpace registered for the sub-IFD tag tagID.
+// The bool is false when tagID was never registered as a sub-IFD tag.
+func GetSubIFDTagSpace(tagID uint16) (TagSpace, bool) {
+	subIFDTags.mu.RLock()
+	defer subIFDTags.mu.RUnlock()
+	tsp, ok := subIFDTags.list[tagID]
+	if ok && tsp == nil {
+		tsp = DefaultTagSpace
+	}
+	return tsp, ok
+}
+
+// ListSubIFDTags returns the sorted ids of all registered sub-IFD tags.
+func ListSubIFDTags() []uint16 {
+	subIFDTags.mu.RLock()
+	defer subIFDTags.mu.RUnlock()
+	ids := make([]uint16, 0, len(subIFDTags.list))
+	for id := range subIFDTags.list {
+		ids = append(ids, id)
+	}
+	sort.Sort(uint16Slice(ids))
+	return ids
+}
+
+// SubIFDOffsets returns the offsets held by the field with tagID in ifd.  It
+// returns nil if ifd does not have such a field.
+func SubIFDOffsets(ifd IFD, tagID uint16) ([]uint64, error) {
+	if !ifd.HasField(tagID) {
+		return nil, nil
+	}
+	return fieldUints(ifd.GetField(tagID))
+}
+
+// ParseSubIFDs parses each of the IFDs pointed to by the field with tagID in
+// ifd.  The IFDParser registered for the version of t is used along with the
+// TagSpace registered for tagID.
+func ParseSubIFDs(t TIFF, ifd IFD, tagID uint16) ([]IFD, error) {
+	offsets, err := SubIFDOffsets(ifd, tagID)
+	if err != nil {
+		return nil, err
+	}
+	tsp, ok := GetSubIFDTagSpace(tagID)
+	if !ok {
+		tsp = DefaultTagSpace
+	}
+	ip := GetIFDParser(t.Version())
+	if ip == nil {
+		ip = ParseIFD
+	}
+	ifds := make([]IFD, 0, len(offsets))
+	for _, off := range offsets {
+		sub, err := ip(t.R(), off, tsp, nil)
+		if err != nil {
+			return nil, fmt.Errorf("tiff: unable to parse sub-IFD for tag %d at offset %d: %v", tagID, off, err)
+		}
+		ifds = append(ifds, sub)
+	}
+	return ifds, nil
+}
+
+// IFDWalkFunc is called by WalkIFDs for each IFD in the tree of a TIFF.  The
+// path identifies the IFD (see IFD Paths above) and tsp is the TagSpace that
+// its fields were looked up in.  Returning a non-nil error stops the walk.
+type IFDWalkFunc func(path string, ifd IFD, tsp TagSpace) error
+
+// maxSubIFDDepth limits how deep WalkIFDs descends into sub-IFDs.  This
+// protects against malformed files where sub-IFDs point back to their parents.
+const maxSubIFDDepth = 8
+
+// WalkIFDs calls fn for each IFD in t followed by each of its sub-IFDs (depth
+// first).  The tsp is the TagSpace t was parsed with.  If tsp is nil, the
+// DefaultTagSpace is assumed.
+func WalkIFDs(t TIFF, tsp TagSpace, fn IFDWalkFunc) error {
+	if tsp == nil {
+		tsp = DefaultTagSpace
+	}
+	for i, ifd := range t.IFDs() {
+		if err := walkIFD(t, strconv.Itoa(i), ifd, tsp, fn, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkIFD(t TIFF, path string, ifd IFD, tsp TagSpace, fn IFDWalkFunc, depth int) error {
+	if err := fn(path, ifd, tsp); err != nil {
+		return err
+	}
+	if depth >= maxSubIFDDepth {
+		return nil
+	}
+	for _, tagID := range ListSubIFDTags() {
+		if !ifd.HasField(tagID) {
+			continue
+		}
+		subs, err := ParseSubIFDs(t, ifd, tagID)
+		if err != nil {
+			return err
+		}
+		subTSP, _ := GetSubIFDTagSpace(tagID)
+		for j, sub := range subs {
+			subPath := fmt.Sprintf("%s/%d", path, tagID)
+			if len(subs) > 1 {
+				subPath = fmt.Sprintf("%s:%d", subPath, j)
+			}
+			if err := walkIFD(t, subPath, sub, subTSP, fn, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IFDPathElem is a single element of a parsed IFD path.  For the first
+// element, Tag is 0 and Index is the index into TIFF.IFDs().
+type IFDPathElem struct {
+	Tag   uint16
+	Index int
+}
+
+// ParseIFDPath parses an IFD path as described above.
+func ParseIFDPath(path string) ([]IFDPathElem, error) {
+	parts := strings.Split(path, "/")
+	elems := make([]IFDPathElem, 0, len(parts))
+	idx, err := strconv.Atoi(parts[0])
+	if err != nil || idx < 0 {
+		return nil, fmt.Errorf("tiff: invalid IFD index %q in path %q", parts[0], path)
+	}
+	elems = append(elems, IFDPathElem{Index: idx})
+	for _, p := range parts[1:] {
+		var e IFDPathElem
+		tagText, idxText, hasIdx := p, "", false
+		if i := strings.Index(p, ":"); i >= 0 {
+			tagText, idxText, hasIdx = p[:i], p[i+1:], true
+		}
+		tag64, err := strconv.ParseUint(tagText, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("tiff: invalid sub-IFD tag %q in path %q", tagText, path)
+		}
+		e.Tag = uint16(tag64)
+		if hasIdx {
+			if e.Index, err = strconv.Atoi(idxText); err != nil || e.Index < 0 {
+				return nil, fmt.Errorf("tiff: invalid sub-IFD index %q in path %q", idxText, path)
+			}
+		}
+		elems = append(elems, e)
+	}
+	return elems, nil
+}
+
+// FindIFD returns the IFD in t identified by path along with the TagSpace its
+// fields were looked up in.  The tsp is the TagSpace t was parsed with.  If tsp
+// is nil, the DefaultTagSpace is assumed.
+func FindIFD(t TIFF, tsp TagSpace, path string) (IFD, TagSpace, error) {
+	elems, err := ParseIFDPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tsp == nil {
+		tsp = DefaultTagSpace
+	}
+	if elems[0].Index >= len(t.IFDs()) {
+		return nil, nil, fmt.Errorf("tiff: IFD index %d out of range (%d IFDs)", elems[0].Index, len(t.IFDs()))
+	}
+	ifd := t.IFDs()[elems[0].Index]
+	for _, e := range elems[1:] {
+		if !ifd.HasField(e.Tag) {
+			return nil, nil, fmt.Errorf("tiff: no field for sub-IFD tag %d in path %q", e.Tag, path)
+		}
+		subs, err := ParseSubIFDs(t, ifd, e.Tag)
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.Index >= len(subs) {
+			return nil, nil, fmt.Errorf("tiff: sub-IFD index %d out of range for tag %d (%d IFDs)", e.Index, e.Tag, len(subs))
+		}
+		ifd = subs[e.Index]
+		tsp, _ = GetSubIFDTagSpace(e.Tag)
+	}
+	return ifd, tsp, nil
+}
+
+func init() {
+	RegisterSubIFDTag(330, DefaultTagSpace) // SubIFDs
+}
@@ -0,0 +1,247 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rawEntry is an IFD entry whose value fits in the entry itself.  For two
+// Shorts, the first one is in the upper half of value.
+type rawEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    uint32
+}
+
+// rawTIFF builds a classic TIFF byte by byte, so that tests can lay out IFDs
+// that no writer would produce.
+type rawTIFF struct {
+	bo  binary.ByteOrder
+	buf []byte
+}
+
+func newRawTIFF(bo binary.ByteOrder, first uint32) *rawTIFF {
+	rt := &rawTIFF{bo: bo, buf: make([]byte, 8)}
+	if bo == binary.BigEndian {
+		copy(rt.buf, MagicBigEndian)
+	} else {
+		copy(rt.buf, MagicLitEndian)
+	}
+	bo.PutUint32(rt.buf[4:], first)
+	return rt
+}
+
+func (rt *rawTIFF) grow(n int) {
+	if len(rt.buf) < n {
+		rt.buf = append(rt.buf, make([]byte, n-len(rt.buf))...)
+	}
+}
+
+// ifd writes an IFD holding entries at off, followed by the offset next.
+func (rt *rawTIFF) ifd(off, next uint32, entries ...rawEntry) {
+	rt.grow(int(off) + 2 + 12*len(entries) + 4)
+	b := rt.buf[off:]
+	rt.bo.PutUint16(b, uint16(len(entries)))
+	for i, e := range entries {
+		eb := b[2+12*i:]
+		rt.bo.PutUint16(eb, e.tag)
+		rt.bo.PutUint16(eb[2:], e.typ)
+		rt.bo.PutUint32(eb[4:], e.count)
+		switch {
+		case e.typ == 3 && e.count == 2:
+			rt.bo.PutUint16(eb[8:], uint16(e.value>>16))
+			rt.bo.PutUint16(eb[10:], uint16(e.value))
+		case e.typ == 3:
+			rt.bo.PutUint16(eb[8:], uint16(e.value))
+		default:
+			rt.bo.PutUint32(eb[8:], e.value)
+		}
+	}
+	rt.bo.PutUint32(b[2+12*len(entries):], next)
+}
+
+// longs writes vals as Longs at off.
+func (rt *rawTIFF) longs(off uint32, vals ...uint32) {
+	rt.grow(int(off) + 4*len(vals))
+	for i, v := range vals {
+		rt.bo.PutUint32(rt.buf[int(off)+4*i:], v)
+	}
+}
+
+func (rt *rawTIFF) parse(t *testing.T) TIFF {
+	t.Helper()
+	tf, err := Parse(bytes.NewReader(rt.buf), nil, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return tf
+}
+
+// markerTag is an unregistered tag that is only used to tell IFDs apart.
+const markerTag = 65000
+
+func marker(v uint32) rawEntry {
+	return rawEntry{tag: markerTag, typ: 4, count: 1, value: v}
+}
+
+func markerOf(t *testing.T, ifd IFD) uint32 {
+	t.Helper()
+	vals, err := fieldUints(ifd.GetField(markerTag))
+	if err != nil || len(vals) != 1 {
+		t.Fatalf("no marker in IFD: %v", err)
+	}
+	return uint32(vals[0])
+}
+
+func TestParseIFDPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []IFDPathElem
+	}{
+		{"0", []IFDPathElem{{0, 0}}},
+		{"3", []IFDPathElem{{0, 3}}},
+		{"0/34665", []IFDPathElem{{0, 0}, {34665, 0}}},
+		{"1/330:2", []IFDPathElem{{0, 1}, {330, 2}}},
+		{"0/330:1/34665/40965:0", []IFDPathElem{{0, 0}, {330, 1}, {34665, 0}, {40965, 0}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseIFDPath(tt.path)
+		if err != nil {
+			t.Errorf("ParseIFDPath(%q): %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIFDPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	for _, path := range []string{"", "x", "-1", " 0", "0/", "0//330", "0/abc", "0/70000", "0/-330", "0/330:", "0/330:x", "0/330:-1", "0/330:1:2"} {
+		if got, err := ParseIFDPath(path); err == nil {
+			t.Errorf("ParseIFDPath(%q) = %v, want an error", path, got)
+		}
+	}
+}
+
+// subIFDTestFile returns a TIFF with two IFDs in the main chain.  The first
+// one has two SubIFDs, marked 10 and 11, and the second one has none.
+func subIFDTestFile(bo binary.ByteOrder) *rawTIFF {
+	rt := newRawTIFF(bo, 8)
+	rt.ifd(8, 100, marker(0), rawEntry{tag: 330, typ: 4, count: 2, value: 200})
+	rt.ifd(100, 0, marker(1))
+	rt.longs(200, 300, 400)
+	rt.ifd(300, 0, marker(10))
+	rt.ifd(400, 0, marker(11))
+	return rt
+}
+
+func TestWalkIFDs(t *testing.T) {
+	for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		tf := subIFDTestFile(bo).parse(t)
+		var got []string
+		err := WalkIFDs(tf, nil, func(path string, ifd IFD, tsp TagSpace) error {
+			if tsp != DefaultTagSpace {
+				t.Errorf("%v: %s: walked with TagSpace %q", bo, path, tsp.Name())
+			}
+			got = append(got, fmt.Sprintf("%s=%d", path, markerOf(t, ifd)))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", bo, err)
+		}
+		want := []string{"0=0", "0/330:0=10", "0/330:1=11", "1=1"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: walked %v, want %v", bo, got, want)
+		}
+	}
+}
+
+func TestWalkIFDsStops(t *testing.T) {
+	tf := subIFDTestFile(binary.BigEndian).parse(t)
+	stop := errors.New("stop")
+	n := 0
+	err := WalkIFDs(tf, nil, func(path string, ifd IFD, tsp TagSpace) error {
+		n++
+		if path == "0/330:0" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 2 {
+		t.Errorf("walk returned %v after %d IFDs, want %v after 2", err, n, stop)
+	}
+}
+
+func TestFindIFD(t *testing.T) {
+	tf := subIFDTestFile(binary.LittleEndian).parse(t)
+	for path, want := range map[string]uint32{"0": 0, "1": 1, "0/330": 10, "0/330:0": 10, "0/330:1": 11} {
+		ifd, tsp, err := FindIFD(tf, nil, path)
+		if err != nil {
+			t.Errorf("FindIFD(%q): %v", path, err)
+			continue
+		}
+		if got := markerOf(t, ifd); got != want {
+			t.Errorf("FindIFD(%q) found IFD %d, want %d", path, got, want)
+		}
+		if tsp != DefaultTagSpace {
+			t.Errorf("FindIFD(%q) returned TagSpace %q", path, tsp.Name())
+		}
+	}
+	for _, path := range []string{"2", "1/330", "0/330:2", "0/34665", "0/330:", "x"} {
+		if _, _, err := FindIFD(tf, nil, path); err == nil {
+			t.Errorf("FindIFD(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestWalkIFDsSelfReference(t *testing.T) {
+	// The SubIFDs field of the only IFD points back at the IFD itself.
+	rt := newRawTIFF(binary.BigEndian, 8)
+	rt.ifd(8, 0, marker(0), rawEntry{tag: 330, typ: 4, count: 1, value: 8})
+	tf := rt.parse(t)
+	var paths []string
+	if err := WalkIFDs(tf, nil, func(path string, ifd IFD, tsp TagSpace) error {
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != maxSubIFDDepth+1 {
+		t.Fatalf("walked %d IFDs, want %d: %v", len(paths), maxSubIFDDepth+1, paths)
+	}
+	deepest := "0" + strings.Repeat("/330", maxSubIFDDepth)
+	if last := paths[len(paths)-1]; last != deepest {
+		t.Errorf("deepest path is %q, want %q", last, deepest)
+	}
+	// FindIFD follows explicit paths, so it is not limited.
+	if _, _, err := FindIFD(tf, nil, deepest+"/330"); err != nil {
+		t.Errorf("FindIFD past the walk depth: %v", err)
+	}
+}
+
+func TestWalkIFDsCycle(t *testing.T) {
+	// Two sub-IFDs point at each other through their SubIFDs fields.
+	rt := newRawTIFF(binary.LittleEndian, 8)
+	rt.ifd(8, 0, marker(0), rawEntry{tag: 330, typ: 4, count: 1, value: 100})
+	rt.ifd(100, 0, marker(1), rawEntry{tag: 330, typ: 4, count: 1, value: 200})
+	rt.ifd(200, 0, marker(2), rawEntry{tag: 330, typ: 4, count: 1, value: 100})
+	tf := rt.parse(t)
+	var markers []uint32
+	if err := WalkIFDs(tf, nil, func(path string, ifd IFD, tsp TagSpace) error {
+		markers = append(markers, markerOf(t, ifd))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{0, 1, 2, 1, 2, 1, 2, 1, 2}
+	if !reflect.DeepEqual(markers, want) {
+		t.Errorf("walked %v, want %v", markers, want)
+	}
+}
@@ -43,6 +43,29 @@ func (t *tag) Interpreter() FieldInterpreter {
 	return t.fi
 }
 
+// TypedTag is a Tag that knows the field types its values may be stored with.
+type TypedTag interface {
+	Tag
+	// FieldTypes returns the ids of the allowed field types.  The first one
+	// is the one to use for new fields.
+	FieldTypes() []uint16
+}
+
+// NewTypedTag is like NewTag for a tag whose values may only be stored with
+// the field types fieldTypes.  The first one is preferred.
+func NewTypedTag(id uint16, name string, fi FieldInterpreter, fieldTypes ...uint16) Tag {
+	return &typedTag{tag{id: id, name: name, fi: fi}, fieldTypes}
+}
+
+type typedTag struct {
+	tag
+	fieldTypes []uint16
+}
+
+func (t *typedTag) FieldTypes() []uint16 {
+	return t.fieldTypes
+}
+
 type FieldInterpreter func(Field) string
 
 func defaultFieldInterpreter(f Field) string {
@@ -4,47 +4,45 @@
 
 package tiff
 
-// TODO: Pass in the slice of valid FieldType for each tag.
-
 var BaselineTags = NewTagSet("Baseline", 1, 64999)
 
 func init() {
-	BaselineTags.Register(NewTag(254, "NewSubfileType", nil))
-	BaselineTags.Register(NewTag(255, "SubfileType", nil))
-	BaselineTags.Register(NewTag(256, "ImageWidth", nil))
-	BaselineTags.Register(NewTag(257, "ImageLength", nil))
-	BaselineTags.Register(NewTag(258, "BitsPerSample", nil))
-	BaselineTags.Register(NewTag(259, "Compression", nil))
-	BaselineTags.Register(NewTag(262, "PhotometricInterpretation", nil))
-	BaselineTags.Register(NewTag(263, "Threshholding", nil))
-	BaselineTags.Register(NewTag(264, "CellWidth", nil))
-	BaselineTags.Register(NewTag(265, "CellLength", nil))
-	BaselineTags.Register(NewTag(266, "FillOrder", nil))
-	BaselineTags.Register(NewTag(270, "ImageDescription", nil))
-	BaselineTags.Register(NewTag(271, "Make", nil))
-	BaselineTags.Register(NewTag(272, "Model", nil))
-	BaselineTags.Register(NewTag(273, "StripOffsets", nil))
-	BaselineTags.Register(NewTag(274, "Orientation", nil))
-	BaselineTags.Register(NewTag(277, "SamplesPerPixel", nil))
-	BaselineTags.Register(NewTag(278, "RowsPerStrip", nil))
-	BaselineTags.Register(NewTag(279, "StripByteCounts", nil))
-	BaselineTags.Register(NewTag(280, "MinSampleValue", nil))
-	BaselineTags.Register(NewTag(281, "MaxSampleValue", nil))
-	BaselineTags.Register(NewTag(282, "XResolution", nil))
-	BaselineTags.Register(NewTag(283, "YResolution", nil))
-	BaselineTags.Register(NewTag(284, "PlanarConfiguration", nil))
-	BaselineTags.Register(NewTag(288, "FreeOffsets", nil))
-	BaselineTags.Register(NewTag(289, "FreeByteCounts", nil))
-	BaselineTags.Register(NewTag(290, "GrayResponseUnit", nil))
-	BaselineTags.Register(NewTag(291, "GrayResponseCurve", nil))
-	BaselineTags.Register(NewTag(296, "ResolutionUnit", nil))
-	BaselineTags.Register(NewTag(305, "Software", nil))
-	BaselineTags.Register(NewTag(306, "DateTime", nil))
-	BaselineTags.Register(NewTag(315, "Artist", nil))
-	BaselineTags.Register(NewTag(316, "HostComputer", nil))
-	BaselineTags.Register(NewTag(320, "ColorMap", nil))
-	BaselineTags.Register(NewTag(338, "ExtraSamples", nil))
-	BaselineTags.Register(NewTag(33432, "Copyright", nil))
+	BaselineTags.Register(NewTypedTag(254, "NewSubfileType", nil, 4))
+	BaselineTags.Register(NewTypedTag(255, "SubfileType", nil, 3))
+	BaselineTags.Register(NewTypedTag(256, "ImageWidth", nil, 4, 3))
+	BaselineTags.Register(NewTypedTag(257, "ImageLength", nil, 4, 3))
+	BaselineTags.Register(NewTypedTag(258, "BitsPerSample", nil, 3))
+	BaselineTags.Register(NewTypedTag(259, "Compression", nil, 3))
+	BaselineTags.Register(NewTypedTag(262, "PhotometricInterpretation", nil, 3))
+	BaselineTags.Register(NewTypedTag(263, "Threshholding", nil, 3))
+	BaselineTags.Register(NewTypedTag(264, "CellWidth", nil, 3))
+	BaselineTags.Register(NewTypedTag(265, "CellLength", nil, 3))
+	BaselineTags.Register(NewTypedTag(266, "FillOrder", nil, 3))
+	BaselineTags.Register(NewTypedTag(270, "ImageDescription", nil, 2))
+	BaselineTags.Register(NewTypedTag(271, "Make", nil, 2))
+	BaselineTags.Register(NewTypedTag(272, "Model", nil, 2))
+	BaselineTags.Register(NewTypedTag(273, "StripOffsets", nil, 4, 3))
+	BaselineTags.Register(NewTypedTag(274, "Orientation", nil, 3))
+	BaselineTags.Register(NewTypedTag(277, "SamplesPerPixel", nil, 3))
+	BaselineTags.Register(NewTypedTag(278, "RowsPerStrip", nil, 4, 3))
+	BaselineTags.Register(NewTypedTag(279, "StripByteCounts", nil, 4, 3))
+	BaselineTags.Register(NewTypedTag(280, "MinSampleValue", nil, 3))
+	BaselineTags.Register(NewTypedTag(281, "MaxSampleValue", nil, 3))
+	BaselineTags.Register(NewTypedTag(282, "XResolution", nil, 5))
+	BaselineTags.Register(NewTypedTag(283, "YResolution", nil, 5))
+	BaselineTags.Register(NewTypedTag(284, "PlanarConfiguration", nil, 3))
+	BaselineTags.Register(NewTypedTag(288, "FreeOffsets", nil, 4))
+	BaselineTags.Register(NewTypedTag(289, "FreeByteCounts", nil, 4))
+	BaselineTags.Register(NewTypedTag(290, "GrayResponseUnit", nil, 3))
+	BaselineTags.Register(NewTypedTag(291, "GrayResponseCurve", nil, 3))
+	BaselineTags.Register(NewTypedTag(296, "ResolutionUnit", nil, 3))
+	BaselineTags.Register(NewTypedTag(305, "Software", nil, 2))
+	BaselineTags.Register(NewTypedTag(306, "DateTime", nil, 2))
+	BaselineTags.Register(NewTypedTag(315, "Artist", nil, 2))
+	BaselineTags.Register(NewTypedTag(316, "HostComputer", nil, 2))
+	BaselineTags.Register(NewTypedTag(320, "ColorMap", nil, 3))
+	BaselineTags.Register(NewTypedTag(338, "ExtraSamples", nil, 3))
+	BaselineTags.Register(NewTypedTag(33432, "Copyright", nil, 2))
 
 	// Prevent further registration in baseline.  If tags are missing, they
 	// should be added here instead of added from the outside.
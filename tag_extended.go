@@ -4,71 +4,70 @@
 
 package tiff
 
-// TODO: Pass in the slice of valid FieldType for each tag.
-
 var ExtendedTags = NewTagSet("Extended", 1, 64999)
 
 func init() {
-	ExtendedTags.Register(NewTag(269, "DocumentName", nil))
-	ExtendedTags.Register(NewTag(285, "PageName", nil))
-	ExtendedTags.Register(NewTag(286, "XPosition", nil))
-	ExtendedTags.Register(NewTag(287, "YPosition", nil))
-	ExtendedTags.Register(NewTag(292, "T4Options", nil))
-	ExtendedTags.Register(NewTag(293, "T6Options", nil))
-	ExtendedTags.Register(NewTag(297, "PageNumber", nil))
-	ExtendedTags.Register(NewTag(301, "TransferFunction", nil))
-	ExtendedTags.Register(NewTag(317, "Predictor", nil))
-	ExtendedTags.Register(NewTag(318, "WhitePoint", nil))
-	ExtendedTags.Register(NewTag(319, "PrimaryChromaticities", nil))
-	ExtendedTags.Register(NewTag(321, "HalftoneHints", nil))
-	ExtendedTags.Register(NewTag(322, "TileWidth", nil))
-	ExtendedTags.Register(NewTag(323, "TileLength", nil))
-	ExtendedTags.Register(NewTag(324, "TileOffsets", nil))
-	ExtendedTags.Register(NewTag(325, "TileByteCounts", nil))
-	ExtendedTags.Register(NewTag(326, "BadFaxLines", nil))
-	ExtendedTags.Register(NewTag(327, "CleanFaxData", nil))
-	ExtendedTags.Register(NewTag(328, "ConsecutiveBadFaxLines", nil))
-	ExtendedTags.Register(NewTag(330, "SubIFDs", nil))
-	ExtendedTags.Register(NewTag(332, "InkSet", nil))
-	ExtendedTags.Register(NewTag(333, "InkNames", nil))
-	ExtendedTags.Register(NewTag(334, "NumberOfInks", nil))
-	ExtendedTags.Register(NewTag(336, "DotRange", nil))
-	ExtendedTags.Register(NewTag(337, "TargetPrinter", nil))
-	ExtendedTags.Register(NewTag(339, "SampleFormat", nil))
+	ExtendedTags.Register(NewTypedTag(269, "DocumentName", nil, 2))
+	ExtendedTags.Register(NewTypedTag(285, "PageName", nil, 2))
+	ExtendedTags.Register(NewTypedTag(286, "XPosition", nil, 5))
+	ExtendedTags.Register(NewTypedTag(287, "YPosition", nil, 5))
+	ExtendedTags.Register(NewTypedTag(292, "T4Options", nil, 4))
+	ExtendedTags.Register(NewTypedTag(293, "T6Options", nil, 4))
+	ExtendedTags.Register(NewTypedTag(297, "PageNumber", nil, 3))
+	ExtendedTags.Register(NewTypedTag(301, "TransferFunction", nil, 3))
+	ExtendedTags.Register(NewTypedTag(317, "Predictor", nil, 3))
+	ExtendedTags.Register(NewTypedTag(318, "WhitePoint", nil, 5))
+	ExtendedTags.Register(NewTypedTag(319, "PrimaryChromaticities", nil, 5))
+	ExtendedTags.Register(NewTypedTag(321, "HalftoneHints", nil, 3))
+	ExtendedTags.Register(NewTypedTag(322, "TileWidth", nil, 4, 3))
+	ExtendedTags.Register(NewTypedTag(323, "TileLength", nil, 4, 3))
+	ExtendedTags.Register(NewTypedTag(324, "TileOffsets", nil, 4))
+	ExtendedTags.Register(NewTypedTag(325, "TileByteCounts", nil, 4, 3))
+	ExtendedTags.Register(NewTypedTag(326, "BadFaxLines", nil, 4, 3))
+	ExtendedTags.Register(NewTypedTag(327, "CleanFaxData", nil, 3))
+	ExtendedTags.Register(NewTypedTag(328, "ConsecutiveBadFaxLines", nil, 4, 3))
+	ExtendedTags.Register(NewTypedTag(330, "SubIFDs", nil, 4, 13))
+	ExtendedTags.Register(NewTypedTag(332, "InkSet", nil, 3))
+	ExtendedTags.Register(NewTypedTag(333, "InkNames", nil, 2))
+	ExtendedTags.Register(NewTypedTag(334, "NumberOfInks", nil, 3))
+	ExtendedTags.Register(NewTypedTag(336, "DotRange", nil, 1, 3))
+	ExtendedTags.Register(NewTypedTag(337, "TargetPrinter", nil, 2))
+	ExtendedTags.Register(NewTypedTag(339, "SampleFormat", nil, 3))
+	// The field type of these follows SampleFormat.
 	ExtendedTags.Register(NewTag(340, "SMinSampleValue", nil))
 	ExtendedTags.Register(NewTag(341, "SMaxSampleValue", nil))
-	ExtendedTags.Register(NewTag(342, "TransferRange", nil))
-	ExtendedTags.Register(NewTag(343, "ClipPath", nil))
-	ExtendedTags.Register(NewTag(344, "XClipPathUnits", nil))
-	ExtendedTags.Register(NewTag(345, "YClipPathUnits", nil))
-	ExtendedTags.Register(NewTag(346, "Indexed", nil))
-	ExtendedTags.Register(NewTag(347, "JPEGTables", nil))
-	ExtendedTags.Register(NewTag(351, "OPIProxy", nil))
-	ExtendedTags.Register(NewTag(400, "GlobalParametersIFD", nil))
-	ExtendedTags.Register(NewTag(401, "ProfileType", nil))
-	ExtendedTags.Register(NewTag(402, "FaxProfile", nil))
-	ExtendedTags.Register(NewTag(403, "CodingMethods", nil))
-	ExtendedTags.Register(NewTag(404, "VersionYear", nil))
-	ExtendedTags.Register(NewTag(405, "ModeNumber", nil))
-	ExtendedTags.Register(NewTag(433, "Decode", nil))
-	ExtendedTags.Register(NewTag(434, "DefaultImageColor", nil))
-	ExtendedTags.Register(NewTag(512, "JPEGProc", nil))
-	ExtendedTags.Register(NewTag(513, "JPEGInterchangeFormat", nil))
-	ExtendedTags.Register(NewTag(514, "JPEGInterchangeFormatLength", nil))
-	ExtendedTags.Register(NewTag(515, "JPEGRestartInterval", nil))
-	ExtendedTags.Register(NewTag(517, "JPEGLosslessPredictors", nil))
-	ExtendedTags.Register(NewTag(518, "JPEGPointTransforms", nil))
-	ExtendedTags.Register(NewTag(519, "JPEGQTables", nil))
-	ExtendedTags.Register(NewTag(520, "JPEGDCTables", nil))
-	ExtendedTags.Register(NewTag(521, "JPEGACTables", nil))
-	ExtendedTags.Register(NewTag(529, "YCbCrCoefficients", nil))
-	ExtendedTags.Register(NewTag(530, "YCbCrSubSampling", nil))
-	ExtendedTags.Register(NewTag(531, "YCbCrPositioning", nil))
-	ExtendedTags.Register(NewTag(532, "ReferenceBlackWhite", nil))
-	ExtendedTags.Register(NewTag(559, "StripRowCounts", nil))
-	ExtendedTags.Register(NewTag(700, "XMP", nil))
-	ExtendedTags.Register(NewTag(32781, "ImageID", nil))
-	ExtendedTags.Register(NewTag(34732, "ImageLayer", nil))
+	ExtendedTags.Register(NewTypedTag(342, "TransferRange", nil, 3))
+	ExtendedTags.Register(NewTypedTag(343, "ClipPath", nil, 1))
+	ExtendedTags.Register(NewTypedTag(344, "XClipPathUnits", nil, 4))
+	ExtendedTags.Register(NewTypedTag(345, "YClipPathUnits", nil, 4))
+	ExtendedTags.Register(NewTypedTag(346, "Indexed", nil, 3))
+	ExtendedTags.Register(NewTypedTag(347, "JPEGTables", nil, 7))
+	ExtendedTags.Register(NewTypedTag(351, "OPIProxy", nil, 3))
+	ExtendedTags.Register(NewTypedTag(400, "GlobalParametersIFD", nil, 13, 4))
+	ExtendedTags.Register(NewTypedTag(401, "ProfileType", nil, 4))
+	ExtendedTags.Register(NewTypedTag(402, "FaxProfile", nil, 1))
+	ExtendedTags.Register(NewTypedTag(403, "CodingMethods", nil, 4))
+	ExtendedTags.Register(NewTypedTag(404, "VersionYear", nil, 1))
+	ExtendedTags.Register(NewTypedTag(405, "ModeNumber", nil, 1))
+	ExtendedTags.Register(NewTypedTag(433, "Decode", nil, 10))
+	ExtendedTags.Register(NewTypedTag(434, "DefaultImageColor", nil, 3))
+	ExtendedTags.Register(NewTypedTag(512, "JPEGProc", nil, 3))
+	ExtendedTags.Register(NewTypedTag(513, "JPEGInterchangeFormat", nil, 4))
+	ExtendedTags.Register(NewTypedTag(514, "JPEGInterchangeFormatLength", nil, 4))
+	ExtendedTags.Register(NewTypedTag(515, "JPEGRestartInterval", nil, 3))
+	ExtendedTags.Register(NewTypedTag(517, "JPEGLosslessPredictors", nil, 3))
+	ExtendedTags.Register(NewTypedTag(518, "JPEGPointTransforms", nil, 3))
+	ExtendedTags.Register(NewTypedTag(519, "JPEGQTables", nil, 4))
+	ExtendedTags.Register(NewTypedTag(520, "JPEGDCTables", nil, 4))
+	ExtendedTags.Register(NewTypedTag(521, "JPEGACTables", nil, 4))
+	ExtendedTags.Register(NewTypedTag(529, "YCbCrCoefficients", nil, 5))
+	ExtendedTags.Register(NewTypedTag(530, "YCbCrSubSampling", nil, 3))
+	ExtendedTags.Register(NewTypedTag(531, "YCbCrPositioning", nil, 3))
+	ExtendedTags.Register(NewTypedTag(532, "ReferenceBlackWhite", nil, 5))
+	ExtendedTags.Register(NewTypedTag(559, "StripRowCounts", nil, 4, 3))
+	ExtendedTags.Register(NewTypedTag(700, "XMP", nil, 1, 7))
+	ExtendedTags.Register(NewTypedTag(32781, "ImageID", nil, 2))
+	ExtendedTags.Register(NewTypedTag(34732, "ImageLayer", nil, 4, 3))
 
 	// Prevent further registration in extended.  If tags are missing, they
 	// should be added here instead of added from the outside.
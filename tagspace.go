@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"sync"
 )
 
@@ -139,6 +140,23 @@ func (tsp *tagSpace) String() string {
 	return fmt.Sprintf("<TagSpace: %q>", tsp.name)
 }
 
+// GetTagByName looks through each TagSet in tsp for a tag with the given name.
+// Names are compared without regard to case.
+func GetTagByName(tsp TagSpace, name string) (Tag, bool) {
+	for _, tsName := range tsp.ListTagSets() {
+		ts, ok := tsp.GetTagSet(tsName)
+		if !ok {
+			continue
+		}
+		for _, id := range ts.ListTags() {
+			if t, ok := ts.GetTag(id); ok && strings.EqualFold(t.Name(), name) {
+				return t, true
+			}
+		}
+	}
+	return nil, false
+}
+
 //
 var DefaultTagSpace = NewTagSpace("Default")
 
@@ -183,4 +183,5 @@ func GetVersionParser(v uint16) TIFFParser {
 
 func init() {
 	RegisterVersion(Version, ParseTIFF)
+	RegisterIFDParser(Version, ParseIFD)
 }
@@ -16,4 +16,5 @@ const (
 
 func init() {
 	tiff.RegisterVersion(Version, tiff.ParseTIFF)
+	tiff.RegisterIFDParser(Version, tiff.ParseIFD)
 }
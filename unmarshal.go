@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
 	"reflect"
 	"strconv"
@@ -561,7 +562,34 @@ func unmarshalVal(data []byte, bo binary.ByteOrder, ft FieldType, v reflect.Valu
 			return ErrUnsuppConversion{ft, typ}
 		}
 		v.SetInt(i64)
-	case reflect.Uint8, reflect.Int8, reflect.Float32, reflect.Float64:
+	case reflect.Float64:
+		// We can up convert any integer or a float32 to a float64, which
+		// holds fields whose type follows the data (e.g. SMinSampleValue).
+		var f64 float64
+		switch ft.ReflectType().Kind() {
+		case reflect.Uint8:
+			f64 = float64(data[0])
+		case reflect.Int8:
+			f64 = float64(int8(data[0]))
+		case reflect.Uint16:
+			f64 = float64(bo.Uint16(data))
+		case reflect.Int16:
+			f64 = float64(int16(bo.Uint16(data)))
+		case reflect.Uint32:
+			f64 = float64(bo.Uint32(data))
+		case reflect.Int32:
+			f64 = float64(int32(bo.Uint32(data)))
+		case reflect.Uint64:
+			f64 = float64(bo.Uint64(data))
+		case reflect.Int64:
+			f64 = float64(int64(bo.Uint64(data)))
+		case reflect.Float32:
+			f64 = float64(math.Float32frombits(bo.Uint32(data)))
+		default:
+			return ErrUnsuppConversion{ft, typ}
+		}
+		v.SetFloat(f64)
+	case reflect.Uint8, reflect.Int8, reflect.Float32:
 		// If this was not handled at the top, we do not support
 		// converting other types to these types.
 		return ErrUnsuppConversion{ft, typ}
@@ -573,7 +601,13 @@ func UnmarshalIFD(ifd IFD, out interface{}) error {
 	if len(ifd.Fields()) == 0 {
 		return fmt.Errorf("tiff: UnmarshalIFD: ifd has no fields")
 	}
-	v := reflect.ValueOf(out).Elem()
+	return unmarshalIFD(ifd, reflect.ValueOf(out).Elem())
+}
+
+// unmarshalIFD does the work of UnmarshalIFD on the struct v.  Working on
+// reflect.Values allows recursing into embedded structs that are unexported,
+// whose exported fields are still settable.
+func unmarshalIFD(ifd IFD, v reflect.Value) error {
 	structType := v.Type()
 
 	for i := 0; i < v.NumField(); i++ {
@@ -589,6 +623,11 @@ func UnmarshalIFD(ifd IFD, out interface{}) error {
 		vf := v.Field(i)
 		vft := vf.Type()
 		vftk := vft.Kind()
+		if vftk != reflect.Struct && !vf.CanSet() {
+			// Unexported fields cannot be set.  Embedded structs are
+			// still walked since their exported fields can be.
+			continue
+		}
 
 		switch sTag.Type {
 		case "ifd":
@@ -602,14 +641,13 @@ func UnmarshalIFD(ifd IFD, out interface{}) error {
 				// the field points back to the enclosing struct.
 				if vf.Elem() != v && vft.Elem().Kind() == reflect.Struct {
 					newStruct := reflect.New(vft.Elem())
-					if err := UnmarshalIFD(ifd, newStruct.Interface()); err != nil {
+					if err := unmarshalIFD(ifd, newStruct.Elem()); err != nil {
 						return err
 					}
 					vf.Set(newStruct)
 				}
 			case reflect.Struct:
-				embStructPtr := v.Field(i).Addr().Interface()
-				if err := UnmarshalIFD(ifd, embStructPtr); err != nil {
+				if err := unmarshalIFD(ifd, vf); err != nil {
 					return err
 				}
 			default:
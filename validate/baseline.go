@@ -0,0 +1,239 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validate
+
+import (
+	"fmt"
+
+	"github.com/google/tiff"
+)
+
+/* TIFF 6.0 Baseline
+
+Classes
+	An IFD is classified by PhotometricInterpretation (Tag 262):
+		0, 1 = Bilevel when BitsPerSample is 1 (or absent), Grayscale otherwise
+		2 = RGB
+		3 = Palette Color
+	Any other value is not a Baseline image.
+
+Required Fields
+	See the comments in image/baseline_*.go for the fields of each class.
+	Required fields that have a default value in TIFF 6.0 (Compression,
+	RowsPerStrip and ResolutionUnit) are reported as warnings when missing.
+
+Allowed Values
+	Compression:          Bilevel: 1, 2, 32773.  Others: 1, 32773.
+	BitsPerSample:        Bilevel: 1.  Grayscale, Palette Color: 4 or 8.
+	                      RGB: 8,8,8 (plus one value per extra sample).
+	SamplesPerPixel:      1, except RGB which is 3 plus the number of
+	                      ExtraSamples.
+	ColorMap:             3 * 2**BitsPerSample values.
+	ResolutionUnit:       1, 2 or 3.
+	PlanarConfiguration:  1.
+	FillOrder:            1 (2 is allowed, but readers need not support it).
+	Orientation:          1 to 8.
+	StripOffsets and StripByteCounts have one value per strip.
+	Tiles are not part of Baseline.
+*/
+
+const (
+	ClassBilevel      = "Bilevel"
+	ClassGrayscale    = "Grayscale"
+	ClassPaletteColor = "PaletteColor"
+	ClassRGB          = "RGB"
+)
+
+type baseline struct{}
+
+func (baseline) Name() string { return "baseline" }
+
+// checker collects the problems of one IFD.
+type checker struct {
+	ifd      tiff.IFD
+	problems []Problem
+}
+
+func (c *checker) report(sev Severity, tagID uint16, format string, args ...interface{}) {
+	var name string
+	if tagID != 0 {
+		name = tiff.DefaultTagSpace.GetTag(tagID).Name()
+	}
+	c.problems = append(c.problems, Problem{sev, tagID, name, fmt.Sprintf(format, args...)})
+}
+
+// values returns the values of the field with tagID.  The bool is false if the
+// field is missing or is not of an unsigned integer type, which is reported.
+func (c *checker) values(tagID uint16) ([]uint64, bool) {
+	if !c.ifd.HasField(tagID) {
+		return nil, false
+	}
+	f := c.ifd.GetField(tagID)
+	vals, ok := Uints(f)
+	if !ok {
+		c.report(Error, tagID, "field type %s is not an unsigned integer type", f.Type().Name())
+		return nil, false
+	}
+	if len(vals) == 0 {
+		c.report(Error, tagID, "field has no values")
+		return nil, false
+	}
+	return vals, true
+}
+
+// value returns the single value of the field with tagID or def if the field
+// is missing.
+func (c *checker) value(tagID uint16, def uint64) uint64 {
+	vals, ok := c.values(tagID)
+	if !ok {
+		return def
+	}
+	if len(vals) != 1 {
+		c.report(Error, tagID, "count is %d, want 1", len(vals))
+	}
+	return vals[0]
+}
+
+func (c *checker) require(sev Severity, tagIDs ...uint16) {
+	for _, tagID := range tagIDs {
+		if !c.ifd.HasField(tagID) {
+			c.report(sev, tagID, "required field is missing")
+		}
+	}
+}
+
+func (c *checker) allow(tagID uint16, def uint64, allowed ...uint64) uint64 {
+	v := c.value(tagID, def)
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	if c.ifd.HasField(tagID) {
+		c.report(Error, tagID, "value %d is not one of %v", v, allowed)
+	}
+	return v
+}
+
+func (b baseline) Check(ifd tiff.IFD) (string, []Problem) {
+	c := &checker{ifd: ifd}
+	c.require(Error, 256, 257, 262, 273, 279, 282, 283)
+	c.require(Warning, 259, 278, 296)
+
+	bps, hasBPS := c.values(258)
+	if !hasBPS {
+		bps = []uint64{1}
+	}
+	spp := c.value(277, 1)
+	hasSPP := ifd.HasField(277)
+
+	var class string
+	photometric, hasPhotometric := c.values(262)
+	if hasPhotometric {
+		switch photometric[0] {
+		case 0, 1:
+			class = ClassGrayscale
+			if bps[0] == 1 {
+				class = ClassBilevel
+			}
+		case 2:
+			class = ClassRGB
+		case 3:
+			class = ClassPaletteColor
+		default:
+			c.report(Error, 262, "value %d is not a Baseline image class", photometric[0])
+		}
+	}
+
+	switch class {
+	case ClassBilevel:
+		c.allow(259, 1, 1, 2, 32773)
+	case ClassGrayscale, ClassPaletteColor:
+		c.allow(259, 1, 1, 32773)
+		if hasBPS && (len(bps) != 1 || (bps[0] != 4 && bps[0] != 8)) {
+			c.report(Error, 258, "value %v is not 4 or 8", bps)
+		}
+		if !hasBPS {
+			c.report(Error, 258, "required field is missing")
+		}
+	case ClassRGB:
+		c.allow(259, 1, 1, 32773)
+		c.require(Error, 277)
+		if !hasBPS {
+			c.report(Error, 258, "required field is missing")
+		}
+	}
+	switch class {
+	case ClassBilevel, ClassGrayscale, ClassPaletteColor:
+		if spp != 1 {
+			c.report(Error, 277, "value %d is not 1", spp)
+		}
+	case ClassRGB:
+		var extra uint64
+		if es, ok := c.values(338); ok {
+			extra = uint64(len(es))
+		}
+		// Missing fields have been reported above, so their defaults
+		// are not checked.
+		if hasSPP && spp != 3+extra {
+			c.report(Error, 277, "value %d is not 3 plus %d ExtraSamples", spp, extra)
+		}
+		if !hasBPS {
+			break
+		}
+		if hasSPP && uint64(len(bps)) != spp {
+			c.report(Error, 258, "count is %d, want %d (SamplesPerPixel)", len(bps), spp)
+		}
+		for i, v := range bps {
+			if i < 3 && v != 8 {
+				c.report(Error, 258, "value %v is not 8,8,8", bps)
+				break
+			}
+		}
+	}
+	if class == ClassPaletteColor {
+		if cm, ok := c.values(320); !ok {
+			c.require(Error, 320)
+		} else if hasBPS && bps[0] < 32 && uint64(len(cm)) != 3<<bps[0] {
+			c.report(Error, 320, "count is %d, want %d (3 * 2**BitsPerSample)", len(cm), 3<<bps[0])
+		}
+	}
+
+	c.allow(296, 2, 1, 2, 3)
+	c.allow(284, 1, 1)
+	if c.allow(266, 1, 1, 2) == 2 {
+		c.report(Warning, 266, "value 2 need not be supported by Baseline readers")
+	}
+	c.allow(274, 1, 1, 2, 3, 4, 5, 6, 7, 8)
+	for _, tagID := range []uint16{322, 323, 324, 325} {
+		if ifd.HasField(tagID) {
+			c.report(Error, tagID, "tiled images are not part of Baseline")
+		}
+	}
+
+	offsets, hasOffsets := c.values(273)
+	counts, hasCounts := c.values(279)
+	if hasOffsets && hasCounts && len(offsets) != len(counts) {
+		c.report(Error, 279, "count is %d, but StripOffsets has %d", len(counts), len(offsets))
+	}
+	length, hasLength := c.values(257)
+	rps := c.value(278, 1<<32-1)
+	if hasOffsets && hasLength && rps > 0 {
+		strips := (length[0] + rps - 1) / rps
+		if rps >= length[0] {
+			strips = 1
+		}
+		if uint64(len(offsets)) != strips {
+			c.report(Error, 273, "count is %d, want %d (one per strip)", len(offsets), strips)
+		}
+	} else if rps == 0 {
+		c.report(Error, 278, "value 0 is not allowed")
+	}
+	return class, c.problems
+}
+
+func init() {
+	RegisterProfile(baseline{})
+}
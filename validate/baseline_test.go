@@ -0,0 +1,194 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/tiff"
+)
+
+// testField is a field with its values as integers.  Rationals take two
+// values each.
+type testField struct {
+	tag, typ uint16
+	vals     []uint32
+}
+
+func short(tag uint16, vals ...uint32) testField { return testField{tag, 3, vals} }
+
+// testIFD writes an IFD with fields and the given number of strips, and
+// returns it as parsed.
+func testIFD(t *testing.T, fields map[uint16]testField, strips int) tiff.IFD {
+	t.Helper()
+	bo := binary.BigEndian
+	wi := &tiff.WritableIFD{}
+	for _, f := range fields {
+		size := 4
+		if f.typ == 3 || f.typ == 8 {
+			size = 2
+		}
+		buf := make([]byte, size*len(f.vals))
+		for i, v := range f.vals {
+			if size == 2 {
+				bo.PutUint16(buf[2*i:], uint16(v))
+			} else {
+				bo.PutUint32(buf[4*i:], v)
+			}
+		}
+		count := uint32(len(f.vals))
+		if f.typ == 5 {
+			count /= 2
+		}
+		wi.Fields = append(wi.Fields, tiff.NewField(f.tag, f.typ, count, buf, bo, nil, nil))
+	}
+	if strips > 0 {
+		wi.Data = map[uint16][]*io.SectionReader{273: nil}
+		for i := 0; i < strips; i++ {
+			wi.Data[273] = append(wi.Data[273], io.NewSectionReader(strings.NewReader("data"), 0, 4))
+		}
+	}
+	var out bytes.Buffer
+	if err := tiff.Write(&out, bo, []*tiff.WritableIFD{wi}); err != nil {
+		t.Fatal(err)
+	}
+	tf, err := tiff.Parse(bytes.NewReader(out.Bytes()), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tf.IFDs()[0]
+}
+
+// grayFields returns the fields of a valid 4x4 Grayscale image in one strip.
+func grayFields() map[uint16]testField {
+	fields := make(map[uint16]testField)
+	for _, f := range []testField{
+		short(256, 4), short(257, 4), short(258, 8), short(259, 1),
+		short(262, 1), short(278, 4), short(296, 2),
+		{282, 5, []uint32{72, 1}}, {283, 5, []uint32{72, 1}},
+	} {
+		fields[f.tag] = f
+	}
+	return fields
+}
+
+// problem is what a test expects of a Problem.  Message only has to be part
+// of the message.
+type problem struct {
+	sev     Severity
+	tag     uint16
+	message string
+}
+
+func TestBaselineCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    []testField
+		del    []uint16
+		strips int // 0 means one strip and -1 none.
+		class  string
+		want   []problem
+	}{
+		{name: "grayscale", class: ClassGrayscale},
+		{name: "grayscale 4 bits", set: []testField{short(258, 4)}, class: ClassGrayscale},
+		{name: "white is zero", set: []testField{short(262, 0)}, class: ClassGrayscale},
+		{name: "bilevel", set: []testField{short(258, 1)}, class: ClassBilevel},
+		{name: "bilevel without BitsPerSample", del: []uint16{258}, class: ClassBilevel},
+		{name: "bilevel CCITT", set: []testField{short(258, 1), short(259, 2)}, class: ClassBilevel},
+		{name: "palette", set: []testField{short(262, 3), short(258, 4), short(320, make([]uint32, 48)...)}, class: ClassPaletteColor},
+		{name: "RGB", set: []testField{short(262, 2), short(258, 8, 8, 8), short(277, 3)}, class: ClassRGB},
+		{name: "RGB with alpha", set: []testField{short(262, 2), short(258, 8, 8, 8, 8), short(277, 4), short(338, 2)}, class: ClassRGB},
+
+		{name: "missing required fields", del: []uint16{256, 282}, class: ClassGrayscale,
+			want: []problem{{Error, 256, "required field is missing"}, {Error, 282, "required field is missing"}}},
+		{name: "missing strips", strips: -1, class: ClassGrayscale,
+			want: []problem{{Error, 273, "required field is missing"}, {Error, 279, "required field is missing"}}},
+		{name: "missing fields with defaults", del: []uint16{259, 278, 296}, class: ClassGrayscale,
+			want: []problem{{Warning, 259, "required field is missing"}, {Warning, 278, "required field is missing"}, {Warning, 296, "required field is missing"}}},
+		{name: "missing PhotometricInterpretation", del: []uint16{262},
+			want: []problem{{Error, 262, "required field is missing"}}},
+		{name: "not a Baseline class", set: []testField{short(262, 5)},
+			want: []problem{{Error, 262, "not a Baseline image class"}}},
+		{name: "not an unsigned integer", set: []testField{{259, 8, []uint32{1}}}, class: ClassGrayscale,
+			want: []problem{{Error, 259, "not an unsigned integer type"}}},
+		{name: "two values", set: []testField{short(259, 1, 1)}, class: ClassGrayscale,
+			want: []problem{{Error, 259, "count is 2, want 1"}}},
+
+		{name: "LZW", set: []testField{short(259, 5)}, class: ClassGrayscale,
+			want: []problem{{Error, 259, "value 5 is not one of [1 32773]"}}},
+		{name: "bilevel LZW", set: []testField{short(258, 1), short(259, 5)}, class: ClassBilevel,
+			want: []problem{{Error, 259, "value 5 is not one of [1 2 32773]"}}},
+		{name: "grayscale 16 bits", set: []testField{short(258, 16)}, class: ClassGrayscale,
+			want: []problem{{Error, 258, "not 4 or 8"}}},
+		{name: "grayscale with three samples", set: []testField{short(277, 3)}, class: ClassGrayscale,
+			want: []problem{{Error, 277, "value 3 is not 1"}}},
+
+		{name: "RGB without BitsPerSample", set: []testField{short(262, 2), short(277, 3)}, del: []uint16{258}, class: ClassRGB,
+			want: []problem{{Error, 258, "required field is missing"}}},
+		{name: "RGB without SamplesPerPixel", set: []testField{short(262, 2), short(258, 8, 8, 8)}, class: ClassRGB,
+			want: []problem{{Error, 277, "required field is missing"}}},
+		{name: "RGB 16 bits", set: []testField{short(262, 2), short(258, 16, 16, 16), short(277, 3)}, class: ClassRGB,
+			want: []problem{{Error, 258, "not 8,8,8"}}},
+		{name: "RGB extra sample without ExtraSamples", set: []testField{short(262, 2), short(258, 8, 8, 8, 8), short(277, 4)}, class: ClassRGB,
+			want: []problem{{Error, 277, "value 4 is not 3 plus 0 ExtraSamples"}}},
+		{name: "RGB BitsPerSample count", set: []testField{short(262, 2), short(258, 8, 8, 8), short(277, 4), short(338, 0)}, class: ClassRGB,
+			want: []problem{{Error, 258, "count is 3, want 4"}}},
+
+		{name: "palette without ColorMap", set: []testField{short(262, 3)}, class: ClassPaletteColor,
+			want: []problem{{Error, 320, "required field is missing"}}},
+		{name: "palette ColorMap count", set: []testField{short(262, 3), short(320, make([]uint32, 48)...)}, class: ClassPaletteColor,
+			want: []problem{{Error, 320, "count is 48, want 768"}}},
+
+		{name: "ResolutionUnit", set: []testField{short(296, 4)}, class: ClassGrayscale,
+			want: []problem{{Error, 296, "value 4 is not one of [1 2 3]"}}},
+		{name: "planar", set: []testField{short(284, 2)}, class: ClassGrayscale,
+			want: []problem{{Error, 284, "value 2 is not one of [1]"}}},
+		{name: "FillOrder 2", set: []testField{short(266, 2)}, class: ClassGrayscale,
+			want: []problem{{Warning, 266, "need not be supported"}}},
+		{name: "Orientation", set: []testField{short(274, 9)}, class: ClassGrayscale,
+			want: []problem{{Error, 274, "value 9 is not one of"}}},
+		{name: "tiles", set: []testField{short(322, 16), short(323, 16)}, class: ClassGrayscale,
+			want: []problem{{Error, 322, "not part of Baseline"}, {Error, 323, "not part of Baseline"}}},
+		{name: "too few strips", set: []testField{short(278, 2)}, class: ClassGrayscale,
+			want: []problem{{Error, 273, "count is 1, want 2 (one per strip)"}}},
+		{name: "strips", set: []testField{short(278, 2)}, strips: 2, class: ClassGrayscale},
+		{name: "RowsPerStrip 0", set: []testField{short(278, 0)}, class: ClassGrayscale,
+			want: []problem{{Error, 278, "value 0 is not allowed"}}},
+	}
+	for _, tt := range tests {
+		fields := grayFields()
+		for _, f := range tt.set {
+			fields[f.tag] = f
+		}
+		for _, tagID := range tt.del {
+			delete(fields, tagID)
+		}
+		strips := tt.strips
+		switch strips {
+		case -1:
+			strips = 0
+		case 0:
+			strips = 1
+		}
+		class, problems := baseline{}.Check(testIFD(t, fields, strips))
+		if class != tt.class {
+			t.Errorf("%s: class is %q, want %q", tt.name, class, tt.class)
+		}
+		if len(problems) != len(tt.want) {
+			t.Errorf("%s: got problems %v, want %v", tt.name, problems, tt.want)
+			continue
+		}
+		for i, p := range problems {
+			w := tt.want[i]
+			if p.Severity != w.sev || p.Tag != w.tag || !strings.Contains(p.Message, w.message) {
+				t.Errorf("%s: problem %d is %v, want %v", tt.name, i, p, w)
+			}
+		}
+	}
+}
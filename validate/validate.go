@@ -0,0 +1,147 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package validate checks the IFDs of a TIFF against conformance profiles.
+
+A Profile classifies each image IFD (i.e. into one of the Baseline TIFF image
+classes) and reports every missing field and illegal value it finds.  The
+"baseline" profile for TIFF 6.0 Baseline is registered by this package.  Other
+profiles can be added with RegisterProfile.
+*/
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/google/tiff"
+)
+
+type Severity int
+
+const (
+	// Error is a violation of the profile.
+	Error Severity = iota
+	// Warning is something a reader must be lenient about, such as a
+	// required field that is missing but has a default value.
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Problem is a single finding of a Profile.  Tag and Name are zero for
+// problems that are not about a specific field.
+type Problem struct {
+	Severity Severity
+	Tag      uint16
+	Name     string
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Tag == 0 && p.Name == "" {
+		return fmt.Sprintf("%s: %s", p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s: %s (%d): %s", p.Severity, p.Name, p.Tag, p.Message)
+}
+
+// Result holds the class and problems of one IFD.  Path is the IFD path (see
+// tiff.FindIFD).  Class is empty when the IFD could not be classified.
+type Result struct {
+	Path     string
+	Class    string
+	Problems []Problem
+}
+
+// HasErrors reports whether any of the problems is an Error.
+func (r Result) HasErrors() bool {
+	for _, p := range r.Problems {
+		if p.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Profile checks single IFDs against a specification.
+type Profile interface {
+	// Name returns the name the profile is registered under.
+	Name() string
+	// Check classifies ifd and returns the class along with every
+	// problem found.
+	Check(ifd tiff.IFD) (class string, problems []Problem)
+}
+
+var allProfiles = struct {
+	mu   sync.RWMutex
+	list map[string]Profile
+}{
+	list: make(map[string]Profile, 1),
+}
+
+func RegisterProfile(p Profile) {
+	allProfiles.mu.Lock()
+	allProfiles.list[p.Name()] = p
+	allProfiles.mu.Unlock()
+}
+
+func GetProfile(name string) Profile {
+	allProfiles.mu.RLock()
+	defer allProfiles.mu.RUnlock()
+	return allProfiles.list[name]
+}
+
+func ListProfiles() []string {
+	allProfiles.mu.RLock()
+	defer allProfiles.mu.RUnlock()
+	names := make([]string, 0, len(allProfiles.list))
+	for name := range allProfiles.list {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks every image IFD of t with p.  Image IFDs are the IFDs of the
+// main chain and any sub-IFDs that use the DefaultTagSpace (i.e. SubIFDs).
+// IFDs in other TagSpaces, such as Exif or GPS IFDs, are skipped.
+func Validate(t tiff.TIFF, p Profile) ([]Result, error) {
+	var results []Result
+	err := tiff.WalkIFDs(t, nil, func(path string, ifd tiff.IFD, tsp tiff.TagSpace) error {
+		if tsp.Name() != tiff.DefaultTagSpace.Name() {
+			return nil
+		}
+		class, problems := p.Check(ifd)
+		results = append(results, Result{Path: path, Class: class, Problems: problems})
+		return nil
+	})
+	return results, err
+}
+
+// Uints returns the values of f as unsigned integers.  The bool is false if the
+// field type of f is not an unsigned integer type.
+func Uints(f tiff.Field) ([]uint64, bool) {
+	ft := f.Type()
+	switch ft.ReflectType().Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, false
+	}
+	size := ft.Size()
+	buf := f.Value().Bytes()
+	vals := make([]uint64, 0, f.Count())
+	for i := uint64(0); i < f.Count() && uint64(len(buf)) >= size; i++ {
+		vals = append(vals, ft.Valuer()(buf[:size], f.Value().Order()).Uint())
+		buf = buf[size:]
+	}
+	return vals, true
+}
@@ -0,0 +1,576 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+/*
+Writing
+  A TIFF is written from a list of WritableIFDs.  Most fields are written
+  as is, but two kinds of fields hold offsets that are only known once the
+  layout of the new file has been decided.
+    1. Offset tags (i.e. StripOffsets) point at blocks of data whose sizes
+       are held by a paired byte count tag (i.e. StripByteCounts).  The
+       blocks themselves are kept in WritableIFD.Data and both fields are
+       rebuilt when writing.  The old-style JPEG tables (JPEGQTables,
+       JPEGDCTables and JPEGACTables) are kept the same way, but have no
+       byte count tag since their sizes follow from the tables themselves.
+    2. Sub-IFD tags (see RegisterSubIFDTag) point at other IFDs.  Those IFDs
+       are kept in WritableIFD.SubIFDs and the field is rebuilt when writing.
+  Everything is written in the order it is found in the tree: each IFD is
+  followed by its values that do not fit in an entry, its data blocks and then
+  its sub-IFDs.  A field holding offsets that cannot be rebuilt is an error
+  rather than being written with offsets into the old file.
+*/
+
+var offsetTags = struct {
+	mu   sync.RWMutex
+	list map[uint16]uint16
+}{
+	list: make(map[uint16]uint16, 1),
+}
+
+// RegisterOffsetTag registers offsetTagID as a tag whose values are offsets to
+// blocks of data with their sizes held by byteCountTagID.
+func RegisterOffsetTag(offsetTagID, byteCountTagID uint16) {
+	offsetTags.mu.Lock()
+	offsetTags.list[offsetTagID] = byteCountTagID
+	offsetTags.mu.Unlock()
+}
+
+// GetByteCountTag returns the byte count tag registered for offsetTagID.  The
+// bool is false when offsetTagID was never registered as an offset tag.
+func GetByteCountTag(offsetTagID uint16) (uint16, bool) {
+	offsetTags.mu.RLock()
+	defer offsetTags.mu.RUnlock()
+	bc, ok := offsetTags.list[offsetTagID]
+	return bc, ok
+}
+
+// ListOffsetTags returns the sorted ids of all registered offset tags.
+func ListOffsetTags() []uint16 {
+	offsetTags.mu.RLock()
+	defer offsetTags.mu.RUnlock()
+	ids := make([]uint16, 0, len(offsetTags.list))
+	for id := range offsetTags.list {
+		ids = append(ids, id)
+	}
+	sort.Sort(uint16Slice(ids))
+	return ids
+}
+
+// NewField returns a Field for tagID holding count values of the field type
+// typeID.  The value holds the raw bytes of those values in byte order bo.  If
+// tsp or ftsp are nil, DefaultTagSpace and DefaultFieldTypeSpace are used.
+func NewField(tagID, typeID uint16, count uint32, value []byte, bo binary.ByteOrder, tsp TagSpace, ftsp FieldTypeSpace) Field {
+	if tsp == nil {
+		tsp = DefaultTagSpace
+	}
+	if ftsp == nil {
+		ftsp = DefaultFieldTypeSpace
+	}
+	e := &entry{tagID: tagID, typeID: typeID, count: count}
+	if len(value) <= 4 {
+		copy(e.valueOffset[:], value)
+	}
+	return &field{
+		entry: e,
+		value: &fieldValue{order: bo, value: value},
+		ftsp:  ftsp,
+		tsp:   tsp,
+	}
+}
+
+// A WritableIFD holds an IFD that is ready to be written along with any data
+// and sub-IFDs it references.
+type WritableIFD struct {
+	// TagSpace is used to look up the tags of Fields.  If nil, the
+	// DefaultTagSpace is assumed.
+	TagSpace TagSpace
+
+	// Fields holds the fields of the IFD in any order.  The values of
+	// fields for offset tags, their byte count tags and sub-IFD tags are
+	// replaced when writing.
+	Fields []Field
+
+	// Data holds the blocks of data referenced by each offset tag, in the
+	// same order as the offsets.
+	Data map[uint16][]*io.SectionReader
+
+	// SubIFDs holds the IFDs pointed to by each sub-IFD tag.
+	SubIFDs map[uint16][]*WritableIFD
+}
+
+// Field returns the field for tagID or nil if there is none.
+func (wi *WritableIFD) Field(tagID uint16) Field {
+	for _, f := range wi.Fields {
+		if f.Tag().ID() == tagID {
+			return f
+		}
+	}
+	return nil
+}
+
+// SetField adds f to the IFD, replacing any field with the same tag.
+func (wi *WritableIFD) SetField(f Field) {
+	for i := range wi.Fields {
+		if wi.Fields[i].Tag().ID() == f.Tag().ID() {
+			wi.Fields[i] = f
+			return
+		}
+	}
+	wi.Fields = append(wi.Fields, f)
+}
+
+// DeleteField removes the field for tagID along with any data or sub-IFDs it
+// references.  It reports whether a field was removed.
+func (wi *WritableIFD) DeleteField(tagID uint16) bool {
+	delete(wi.Data, tagID)
+	delete(wi.SubIFDs, tagID)
+	for i := range wi.Fields {
+		if wi.Fields[i].Tag().ID() == tagID {
+			wi.Fields = append(wi.Fields[:i], wi.Fields[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// NewWritableIFD prepares ifd from t for writing.  The data referenced by
+// offset tags is not read, but kept as sections of t.R().  Sub-IFDs are
+// prepared the same way.
+func NewWritableIFD(t TIFF, ifd IFD, tsp TagSpace) (*WritableIFD, error) {
+	return newWritableIFD(t, ifd, tsp, 0)
+}
+
+func newWritableIFD(t TIFF, ifd IFD, tsp TagSpace, depth int) (*WritableIFD, error) {
+	if tsp == nil {
+		tsp = DefaultTagSpace
+	}
+	wi := &WritableIFD{
+		TagSpace: tsp,
+		Fields:   append([]Field(nil), ifd.Fields()...),
+		Data:     make(map[uint16][]*io.SectionReader, 1),
+		SubIFDs:  make(map[uint16][]*WritableIFD, 1),
+	}
+	for _, offTagID := range append(ListOffsetTags(), jpegTableTags...) {
+		offsets, sizes, err := offsetBlocks(t.R(), ifd, offTagID)
+		if err != nil {
+			return nil, err
+		}
+		if offsets == nil {
+			continue
+		}
+		blocks := make([]*io.SectionReader, len(offsets))
+		for i := range offsets {
+			blocks[i] = io.NewSectionReader(t.R(), int64(offsets[i]), int64(sizes[i]))
+		}
+		wi.Data[offTagID] = blocks
+	}
+	for _, subTagID := range ListSubIFDTags() {
+		if !ifd.HasField(subTagID) {
+			continue
+		}
+		if depth >= maxSubIFDDepth {
+			// The sub-IFDs are not kept, so the offsets to them
+			// could not be rebuilt.
+			return nil, fmt.Errorf("tiff: sub-IFDs for tag %d nested deeper than %d", subTagID, maxSubIFDDepth)
+		}
+		subs, err := ParseSubIFDs(t, ifd, subTagID)
+		if err != nil {
+			return nil, err
+		}
+		subTSP, _ := GetSubIFDTagSpace(subTagID)
+		for _, sub := range subs {
+			wsub, err := newWritableIFD(t, sub, subTSP, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			wi.SubIFDs[subTagID] = append(wi.SubIFDs[subTagID], wsub)
+		}
+	}
+	return wi, nil
+}
+
+// jpegTableTags are the old-style JPEG tags JPEGQTables, JPEGDCTables and
+// JPEGACTables.  Their values are offsets to tables that have no byte count
+// tag, so the size of each table is worked out from the table itself.
+var jpegTableTags = []uint16{519, 520, 521}
+
+func isJPEGTableTag(tagID uint16) bool {
+	return tagID >= 519 && tagID <= 521
+}
+
+// jpegTableSize returns the size of the table at off for one of the
+// jpegTableTags.  A quantization table is 64 bytes.  A Huffman table is 16
+// bytes of code counts followed by one byte for each code.
+func jpegTableSize(r io.ReaderAt, tagID uint16, off uint64) (uint64, error) {
+	if tagID == 519 {
+		return 64, nil
+	}
+	var counts [16]byte
+	if _, err := r.ReadAt(counts[:], int64(off)); err != nil {
+		return 0, fmt.Errorf("tiff: unable to read the table for tag %d at offset %d: %v", tagID, off, err)
+	}
+	n := uint64(len(counts))
+	for _, c := range counts {
+		n += uint64(c)
+	}
+	return n, nil
+}
+
+// offsetBlocks returns the offsets and sizes of the blocks of data pointed to
+// by the field for offTagID in ifd.  The offTagID is either a registered offset
+// tag or one of the jpegTableTags.  It returns nil if ifd has no such field and
+// an error if the sizes of the blocks cannot be known.
+func offsetBlocks(r io.ReaderAt, ifd IFD, offTagID uint16) (offsets, sizes []uint64, err error) {
+	if !ifd.HasField(offTagID) {
+		return nil, nil, nil
+	}
+	offsets, err = fieldUints(ifd.GetField(offTagID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if isJPEGTableTag(offTagID) {
+		sizes = make([]uint64, len(offsets))
+		for i, off := range offsets {
+			if sizes[i], err = jpegTableSize(r, offTagID, off); err != nil {
+				return nil, nil, err
+			}
+		}
+		return offsets, sizes, nil
+	}
+	bcTagID, ok := GetByteCountTag(offTagID)
+	if !ok {
+		return nil, nil, fmt.Errorf("tiff: tag %d is not a registered offset tag", offTagID)
+	}
+	if !ifd.HasField(bcTagID) {
+		// Without the byte counts, there is no way to know how much
+		// data the offsets point to.
+		return nil, nil, fmt.Errorf("tiff: offsets for tag %d without byte counts for tag %d", offTagID, bcTagID)
+	}
+	sizes, err = fieldUints(ifd.GetField(bcTagID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(offsets) != len(sizes) {
+		return nil, nil, fmt.Errorf("tiff: %d offsets for tag %d, but %d byte counts for tag %d", len(offsets), offTagID, len(sizes), bcTagID)
+	}
+	return offsets, sizes, nil
+}
+
+// NewWritableIFDs prepares every IFD in t for writing.  The tsp is the TagSpace
+// t was parsed with.  If tsp is nil, the DefaultTagSpace is assumed.
+func NewWritableIFDs(t TIFF, tsp TagSpace) ([]*WritableIFD, error) {
+	wifds := make([]*WritableIFD, 0, len(t.IFDs()))
+	for _, ifd := range t.IFDs() {
+		wi, err := NewWritableIFD(t, ifd, tsp)
+		if err != nil {
+			return nil, err
+		}
+		wifds = append(wifds, wi)
+	}
+	return wifds, nil
+}
+
+// FindWritableIFD returns the IFD identified by path (see IFD Paths) from a
+// list of top level IFDs.
+func FindWritableIFD(wifds []*WritableIFD, path string) (*WritableIFD, error) {
+	elems, err := ParseIFDPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if elems[0].Index >= len(wifds) {
+		return nil, fmt.Errorf("tiff: IFD index %d out of range (%d IFDs)", elems[0].Index, len(wifds))
+	}
+	wi := wifds[elems[0].Index]
+	for _, e := range elems[1:] {
+		subs := wi.SubIFDs[e.Tag]
+		if e.Index >= len(subs) {
+			return nil, fmt.Errorf("tiff: no sub-IFD %d for tag %d in path %q", e.Index, e.Tag, path)
+		}
+		wi = subs[e.Index]
+	}
+	return wi, nil
+}
+
+// swapOrder converts the raw bytes of values of ft from one byte order to the
+// other.  Rationals and complex values are made up of two 4 byte parts.
+func swapOrder(ft FieldType, b []byte) []byte {
+	unit := int(ft.Size())
+	switch ft.ID() {
+	case 5, 10, 15: // Rational, SRational, Complex
+		unit = 4
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	if unit < 2 {
+		return out
+	}
+	for i := 0; i+unit <= len(out); i += unit {
+		for j, k := i, i+unit-1; j < k; j, k = j+1, k-1 {
+			out[j], out[k] = out[k], out[j]
+		}
+	}
+	return out
+}
+
+type writeField struct {
+	tagID  uint16
+	typeID uint16
+	count  uint32
+	value  []byte
+	offset uint64
+}
+
+type writePiece struct {
+	offset uint64
+	b      []byte
+	sr     *io.SectionReader
+}
+
+type tiffWriter struct {
+	bo     binary.ByteOrder
+	next   uint64
+	pieces []*writePiece
+}
+
+func (tw *tiffWriter) alloc(n uint64) uint64 {
+	if tw.next%2 != 0 {
+		tw.next++
+	}
+	off := tw.next
+	tw.next += n
+	return off
+}
+
+func (tw *tiffWriter) offsetsValue(typeID uint16, vals []uint64) []byte {
+	var buf bytes.Buffer
+	for _, v := range vals {
+		if typeID == 3 {
+			binary.Write(&buf, tw.bo, uint16(v))
+		} else {
+			binary.Write(&buf, tw.bo, uint32(v))
+		}
+	}
+	return buf.Bytes()
+}
+
+// layout assigns offsets to wi and everything it references.  It returns the
+// piece holding the IFD so that the offset to the next IFD can be set later.
+func (tw *tiffWriter) layout(wi *WritableIFD, depth int) (*writePiece, error) {
+	if depth > maxSubIFDDepth {
+		return nil, fmt.Errorf("tiff: sub-IFDs nested deeper than %d", maxSubIFDDepth)
+	}
+	byTag := make(map[uint16]*writeField, len(wi.Fields))
+	for _, f := range wi.Fields {
+		ft := f.Type()
+		size := ft.Size() * f.Count()
+		b := f.Value().Bytes()
+		if uint64(len(b)) < size {
+			return nil, fmt.Errorf("tiff: field %d has %d bytes for %d values of size %d", f.Tag().ID(), len(b), f.Count(), ft.Size())
+		}
+		b = b[:size]
+		if f.Value().Order() != tw.bo {
+			b = swapOrder(ft, b)
+		}
+		byTag[f.Tag().ID()] = &writeField{tagID: f.Tag().ID(), typeID: ft.ID(), count: uint32(f.Count()), value: b}
+	}
+	// Offsets, byte counts and sub-IFD pointers are rebuilt below.  The
+	// values are filled in once the layout is known, but their sizes are
+	// needed now.
+	placeholder := func(tagID uint16, typeID uint16, n int) *writeField {
+		wf := byTag[tagID]
+		if wf == nil {
+			wf = &writeField{tagID: tagID}
+			byTag[tagID] = wf
+		}
+		wf.typeID = typeID
+		wf.count = uint32(n)
+		wf.value = tw.offsetsValue(typeID, make([]uint64, n))
+		return wf
+	}
+	// A field holding offsets that are not rebuilt would point at the
+	// wrong place in the new file.
+	for tagID, wf := range byTag {
+		_, isOff := GetByteCountTag(tagID)
+		_, isSub := GetSubIFDTagSpace(tagID)
+		switch {
+		case wf.count == 0:
+		case (isOff || isJPEGTableTag(tagID)) && wi.Data[tagID] == nil:
+			return nil, fmt.Errorf("tiff: no data given for the offsets of tag %d", tagID)
+		case isSub && wi.SubIFDs[tagID] == nil:
+			return nil, fmt.Errorf("tiff: no sub-IFDs given for the offsets of tag %d", tagID)
+		}
+	}
+	var dataTags, subTags []uint16
+	for tagID, blocks := range wi.Data {
+		dataTags = append(dataTags, tagID)
+		placeholder(tagID, 4, len(blocks))
+		if isJPEGTableTag(tagID) {
+			continue
+		}
+		bcTagID, ok := GetByteCountTag(tagID)
+		if !ok {
+			return nil, fmt.Errorf("tiff: data given for tag %d which is not a registered offset tag", tagID)
+		}
+
+		// Byte counts stay a Short if they were one and still fit.
+		bcTypeID := uint16(4)
+		if wf := byTag[bcTagID]; wf != nil && wf.typeID == 3 {
+			bcTypeID = 3
+		}
+		counts := make([]uint64, len(blocks))
+		for i, b := range blocks {
+			counts[i] = uint64(b.Size())
+			if counts[i] > 0xFFFF {
+				bcTypeID = 4
+			}
+		}
+		placeholder(bcTagID, bcTypeID, len(blocks)).value = tw.offsetsValue(bcTypeID, counts)
+	}
+	for tagID, subs := range wi.SubIFDs {
+		subTags = append(subTags, tagID)
+		typeID := uint16(4)
+		if wf := byTag[tagID]; wf != nil && wf.typeID == 13 {
+			typeID = 13
+		}
+		placeholder(tagID, typeID, len(subs))
+	}
+	sort.Sort(uint16Slice(dataTags))
+	sort.Sort(uint16Slice(subTags))
+
+	tags := make([]uint16, 0, len(byTag))
+	for tagID := range byTag {
+		tags = append(tags, tagID)
+	}
+	sort.Sort(uint16Slice(tags))
+
+	dirPiece := &writePiece{offset: tw.alloc(2 + 12*uint64(len(tags)) + 4)}
+	tw.pieces = append(tw.pieces, dirPiece)
+	for _, tagID := range tags {
+		if wf := byTag[tagID]; len(wf.value) > 4 {
+			wf.offset = tw.alloc(uint64(len(wf.value)))
+		}
+	}
+	for _, tagID := range dataTags {
+		var offsets []uint64
+		for _, sr := range wi.Data[tagID] {
+			p := &writePiece{offset: tw.alloc(uint64(sr.Size())), sr: sr}
+			tw.pieces = append(tw.pieces, p)
+			offsets = append(offsets, p.offset)
+		}
+		byTag[tagID].value = tw.offsetsValue(4, offsets)
+	}
+	for _, tagID := range subTags {
+		var offsets []uint64
+		for _, sub := range wi.SubIFDs[tagID] {
+			p, err := tw.layout(sub, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			offsets = append(offsets, p.offset)
+		}
+		byTag[tagID].value = tw.offsetsValue(byTag[tagID].typeID, offsets)
+	}
+
+	var dir bytes.Buffer
+	binary.Write(&dir, tw.bo, uint16(len(tags)))
+	for _, tagID := range tags {
+		wf := byTag[tagID]
+		binary.Write(&dir, tw.bo, wf.tagID)
+		binary.Write(&dir, tw.bo, wf.typeID)
+		binary.Write(&dir, tw.bo, wf.count)
+		if len(wf.value) > 4 {
+			binary.Write(&dir, tw.bo, uint32(wf.offset))
+			tw.pieces = append(tw.pieces, &writePiece{offset: wf.offset, b: wf.value})
+		} else {
+			var vo [4]byte
+			copy(vo[:], wf.value)
+			dir.Write(vo[:])
+		}
+	}
+	binary.Write(&dir, tw.bo, uint32(0)) // Offset of the next IFD.
+	dirPiece.b = dir.Bytes()
+	return dirPiece, nil
+}
+
+// Write writes a TIFF made up of wifds to w using the byte order bo.  The
+// IFDs are chained in the order given.
+func Write(w io.Writer, bo binary.ByteOrder, wifds []*WritableIFD) error {
+	if len(wifds) == 0 {
+		return fmt.Errorf("tiff: no IFDs to write")
+	}
+	var hdr [8]byte
+	switch bo {
+	case binary.BigEndian:
+		copy(hdr[:], MagicBigEndian)
+	case binary.LittleEndian:
+		copy(hdr[:], MagicLitEndian)
+	default:
+		return fmt.Errorf("tiff: unsupported byte order %v", bo)
+	}
+	tw := &tiffWriter{bo: bo, next: 8}
+	var dirs []*writePiece
+	for _, wi := range wifds {
+		p, err := tw.layout(wi, 0)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, p)
+	}
+	if tw.next > 0xFFFFFFFF {
+		return fmt.Errorf("tiff: %d bytes is too large for a TIFF (use BigTIFF)", tw.next)
+	}
+	bo.PutUint32(hdr[4:], uint32(dirs[0].offset))
+	for i := 0; i+1 < len(dirs); i++ {
+		b := dirs[i].b
+		bo.PutUint32(b[len(b)-4:], uint32(dirs[i+1].offset))
+	}
+
+	sort.Slice(tw.pieces, func(i, j int) bool { return tw.pieces[i].offset < tw.pieces[j].offset })
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	pos := uint64(len(hdr))
+	var pad [1]byte
+	for _, p := range tw.pieces {
+		for ; pos < p.offset; pos++ {
+			if _, err := w.Write(pad[:]); err != nil {
+				return err
+			}
+		}
+		if p.sr != nil {
+			n, err := io.Copy(w, io.NewSectionReader(p.sr, 0, p.sr.Size()))
+			if err != nil {
+				return err
+			}
+			if n != p.sr.Size() {
+				return fmt.Errorf("tiff: short data block: copied %d of %d bytes", n, p.sr.Size())
+			}
+			pos += uint64(n)
+			continue
+		}
+		if _, err := w.Write(p.b); err != nil {
+			return err
+		}
+		pos += uint64(len(p.b))
+	}
+	return nil
+}
+
+func init() {
+	RegisterOffsetTag(273, 279) // StripOffsets, StripByteCounts
+	RegisterOffsetTag(288, 289) // FreeOffsets, FreeByteCounts
+	RegisterOffsetTag(324, 325) // TileOffsets, TileByteCounts
+	RegisterOffsetTag(513, 514) // JPEGInterchangeFormat, JPEGInterchangeFormatLength
+}
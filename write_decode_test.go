@@ -0,0 +1,177 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/google/tiff"
+	timage "github.com/google/tiff/image"
+)
+
+// gradient returns a 16 bit image whose size is not a multiple of the strip
+// or tile sizes used below.
+func gradient(w, h int) *image.Gray16 {
+	m := image.NewGray16(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.SetGray16(x, y, color.Gray16{uint16(1000*x + 37*y)})
+		}
+	}
+	return m
+}
+
+func encode(t *testing.T, m image.Image, opts *timage.Options) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := timage.Encode(&buf, m, opts); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func writableIFDs(t *testing.T, b []byte) ([]*tiff.WritableIFD, binary.ByteOrder) {
+	t.Helper()
+	tf, err := tiff.Parse(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	wifds, err := tiff.NewWritableIFDs(tf, nil)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	return wifds, tiff.GetByteOrder(binary.BigEndian.Uint16([]byte(tf.Order())))
+}
+
+func write(t *testing.T, bo binary.ByteOrder, wifds []*tiff.WritableIFD) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tiff.Write(&buf, bo, wifds); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func setField(t *testing.T, wi *tiff.WritableIFD, bo binary.ByteOrder, tagID uint16, ft tiff.FieldType, text string) {
+	t.Helper()
+	val, count, err := tiff.EncodeFieldValue(ft, text, bo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wi.SetField(tiff.NewField(tagID, ft.ID(), count, val, bo, nil, nil))
+}
+
+func checkDecode(t *testing.T, name string, b []byte, want image.Image) {
+	t.Helper()
+	got, err := timage.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Errorf("%s: decode: %v", name, err)
+		return
+	}
+	if got.Bounds() != want.Bounds() {
+		t.Errorf("%s: bounds %v, want %v", name, got.Bounds(), want.Bounds())
+		return
+	}
+	r := want.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if g, w := color.Gray16Model.Convert(got.At(x, y)), want.At(x, y); g != w {
+				t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, g, w)
+				return
+			}
+		}
+	}
+}
+
+func description(t *testing.T, b []byte, path string) string {
+	t.Helper()
+	tf, err := tiff.Parse(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ifd, _, err := tiff.FindIFD(tf, nil, path)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if !ifd.HasField(270) {
+		return ""
+	}
+	f := ifd.GetField(270)
+	return strings.TrimRight(string(f.Value().Bytes()[:f.Count()]), "\x00")
+}
+
+func TestEditAndWrite(t *testing.T) {
+	m := gradient(37, 29)
+	tests := []struct {
+		name string
+		opts timage.Options
+	}{
+		{"strips", timage.Options{RowsPerStrip: 5}},
+		{"big endian strips", timage.Options{ByteOrder: binary.BigEndian, RowsPerStrip: 5}},
+		{"LZW strips", timage.Options{Compression: 5, Predictor: 2, RowsPerStrip: 8}},
+		{"tiles", timage.Options{TileWidth: 16, TileLength: 16}},
+		{"big endian Deflate tiles", timage.Options{ByteOrder: binary.BigEndian, Compression: 8, TileWidth: 32, TileLength: 16}},
+	}
+	for _, tt := range tests {
+		wifds, bo := writableIFDs(t, encode(t, m, &tt.opts))
+		// A new field, a changed field and a deleted field.
+		setField(t, wifds[0], bo, 270, tiff.FTAscii, "edited "+tt.name)
+		setField(t, wifds[0], bo, 282, tiff.FTRational, "300/1")
+		wifds[0].DeleteField(296)
+		out := write(t, bo, wifds)
+		if got, want := description(t, out, "0"), "edited "+tt.name; got != want {
+			t.Errorf("%s: description is %q, want %q", tt.name, got, want)
+		}
+		checkDecode(t, tt.name, out, m)
+
+		// Writing the result again keeps everything in place.
+		wifds, bo = writableIFDs(t, out)
+		again := write(t, bo, wifds)
+		if !bytes.Equal(again, out) {
+			t.Errorf("%s: a second write changed the file", tt.name)
+		}
+	}
+}
+
+func TestWriteSubIFDs(t *testing.T) {
+	full, reduced := gradient(37, 29), gradient(19, 15)
+	top, bo := writableIFDs(t, encode(t, full, &timage.Options{RowsPerStrip: 4}))
+	sub, _ := writableIFDs(t, encode(t, reduced, &timage.Options{TileWidth: 16, TileLength: 16}))
+	setField(t, sub[0], bo, 254, tiff.FTLong, "1")
+	setField(t, sub[0], bo, 270, tiff.FTAscii, "reduced")
+	top[0].SubIFDs = map[uint16][]*tiff.WritableIFD{330: {sub[0], sub[0]}}
+	out := write(t, bo, top)
+
+	// Edit the sub-IFD of the written file and write it again, so that the
+	// sub-IFDs go through NewWritableIFD.
+	wifds, bo := writableIFDs(t, out)
+	wi, err := tiff.FindWritableIFD(wifds, "0/330:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setField(t, wi, bo, 270, tiff.FTAscii, "edited")
+	out = write(t, bo, wifds)
+	checkDecode(t, "main IFD", out, full)
+	for path, want := range map[string]string{"0/330:0": "reduced", "0/330:1": "edited"} {
+		if got := description(t, out, path); got != want {
+			t.Errorf("%s: description is %q, want %q", path, got, want)
+		}
+	}
+
+	// Decode the sub-IFDs by writing each of them as a file of its own.
+	wifds, bo = writableIFDs(t, out)
+	for _, path := range []string{"0/330:0", "0/330:1"} {
+		wi, err := tiff.FindWritableIFD(wifds, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDecode(t, path, write(t, bo, []*tiff.WritableIFD{wi}), reduced)
+	}
+}
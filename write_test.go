@@ -0,0 +1,179 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// put writes b at off.
+func (rt *rawTIFF) put(off uint32, b []byte) {
+	rt.grow(int(off) + len(b))
+	copy(rt.buf[off:], b)
+}
+
+func writeTIFF(t *testing.T, bo binary.ByteOrder, wifds ...*WritableIFD) TIFF {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, bo, wifds); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tf, err := Parse(bytes.NewReader(buf.Bytes()), nil, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return tf
+}
+
+// blocks returns the blocks of data the offset tag offTagID points to in ifd.
+func blocks(t *testing.T, tf TIFF, ifd IFD, offTagID uint16) [][]byte {
+	t.Helper()
+	offsets, sizes, err := offsetBlocks(tf.R(), ifd, offTagID)
+	if err != nil {
+		t.Fatalf("tag %d: %v", offTagID, err)
+	}
+	out := make([][]byte, len(offsets))
+	for i := range offsets {
+		out[i] = make([]byte, sizes[i])
+		if _, err := tf.R().ReadAt(out[i], int64(offsets[i])); err != nil {
+			t.Fatalf("tag %d: block %d: %v", offTagID, i, err)
+		}
+	}
+	return out
+}
+
+func TestWriteRelocatesData(t *testing.T) {
+	// Two strips with byte counts stored as Shorts, an old-style JPEG
+	// stream and its quantization table, scattered around the file.
+	qtable := bytes.Repeat([]byte{9}, 64)
+	for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		rt := newRawTIFF(bo, 400)
+		rt.put(100, []byte("first strip"))
+		rt.put(200, []byte("second"))
+		rt.put(300, []byte("jpeg stream"))
+		rt.put(500, qtable)
+		rt.ifd(400, 0,
+			rawEntry{tag: 273, typ: 4, count: 2, value: 600},
+			rawEntry{tag: 279, typ: 3, count: 2, value: 11<<16 | 6},
+			rawEntry{tag: 513, typ: 4, count: 1, value: 300},
+			rawEntry{tag: 514, typ: 4, count: 1, value: 11},
+			rawEntry{tag: 519, typ: 4, count: 1, value: 500},
+		)
+		rt.longs(600, 100, 200)
+		src := rt.parse(t)
+		wi, err := NewWritableIFD(src, src.IFDs()[0], nil)
+		if err != nil {
+			t.Fatalf("%v: %v", bo, err)
+		}
+		out := writeTIFF(t, bo, wi)
+		ifd := out.IFDs()[0]
+		if ifd.GetField(279).Type().ID() != 3 {
+			t.Errorf("%v: StripByteCounts became %s", bo, ifd.GetField(279).Type().Name())
+		}
+		want := map[uint16][]string{
+			273: {"first strip", "second"},
+			513: {"jpeg stream"},
+			519: {string(qtable)},
+		}
+		for tagID, w := range want {
+			got := blocks(t, out, ifd, tagID)
+			if len(got) != len(w) {
+				t.Errorf("%v: tag %d has %d blocks, want %d", bo, tagID, len(got), len(w))
+				continue
+			}
+			for i := range got {
+				if string(got[i]) != w[i] {
+					t.Errorf("%v: tag %d block %d is %q, want %q", bo, tagID, i, got[i], w[i])
+				}
+			}
+		}
+	}
+}
+
+func TestNewWritableIFDErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []rawEntry
+		want    string
+	}{
+		{"offsets without byte counts", []rawEntry{{tag: 513, typ: 4, count: 1, value: 8}}, "without byte counts"},
+		{"count mismatch", []rawEntry{{tag: 273, typ: 4, count: 2, value: 200}, {tag: 279, typ: 4, count: 1, value: 4}}, "byte counts"},
+		{"self-referencing SubIFDs", []rawEntry{{tag: 330, typ: 4, count: 1, value: 100}}, "nested deeper"},
+	}
+	for _, tt := range tests {
+		rt := newRawTIFF(binary.BigEndian, 100)
+		rt.ifd(100, 0, tt.entries...)
+		rt.longs(200, 8, 8)
+		src := rt.parse(t)
+		_, err := NewWritableIFD(src, src.IFDs()[0], nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	bo := binary.BigEndian
+	data := map[uint16][]*io.SectionReader{273: {io.NewSectionReader(strings.NewReader("data"), 0, 4)}}
+	nested := &WritableIFD{}
+	for i := 0; i <= maxSubIFDDepth; i++ {
+		nested = &WritableIFD{SubIFDs: map[uint16][]*WritableIFD{330: {nested}}}
+	}
+	tests := []struct {
+		name  string
+		wifds []*WritableIFD
+		want  string
+	}{
+		{"no IFDs", nil, "no IFDs"},
+		{"offsets without data", []*WritableIFD{{Fields: []Field{NewField(273, 4, 1, []byte{0, 0, 0, 8}, bo, nil, nil)}}}, "no data given"},
+		{"table offsets without data", []*WritableIFD{{Fields: []Field{NewField(519, 4, 1, []byte{0, 0, 0, 8}, bo, nil, nil)}}}, "no data given"},
+		{"sub-IFD offsets without sub-IFDs", []*WritableIFD{{Fields: []Field{NewField(330, 4, 1, []byte{0, 0, 0, 8}, bo, nil, nil)}}}, "no sub-IFDs given"},
+		{"data for an unregistered tag", []*WritableIFD{{Data: map[uint16][]*io.SectionReader{65000: data[273]}}}, "not a registered offset tag"},
+		{"short value", []*WritableIFD{{Fields: []Field{NewField(256, 4, 2, []byte{0, 0, 0, 8}, bo, nil, nil)}}}, "has 4 bytes"},
+		{"nested too deep", []*WritableIFD{nested}, "nested deeper"},
+	}
+	for _, tt := range tests {
+		err := Write(io.Discard, bo, tt.wifds)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.want)
+		}
+	}
+	// The deepest nesting that is allowed.
+	nested = &WritableIFD{Data: data}
+	for i := 0; i < maxSubIFDDepth; i++ {
+		nested = &WritableIFD{SubIFDs: map[uint16][]*WritableIFD{330: {nested}}}
+	}
+	tf := writeTIFF(t, bo, nested)
+	ifd, _, err := FindIFD(tf, nil, "0"+strings.Repeat("/330", maxSubIFDDepth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := blocks(t, tf, ifd, 273); len(got) != 1 || string(got[0]) != "data" {
+		t.Errorf("the deepest IFD has data %q", got)
+	}
+}
+
+func TestWritableIFDFields(t *testing.T) {
+	bo := binary.LittleEndian
+	wi := &WritableIFD{}
+	wi.SetField(NewField(256, 3, 1, []byte{1, 0}, bo, nil, nil))
+	wi.SetField(NewField(257, 3, 1, []byte{2, 0}, bo, nil, nil))
+	wi.SetField(NewField(256, 3, 1, []byte{3, 0}, bo, nil, nil))
+	if len(wi.Fields) != 2 || wi.Field(256).Value().Bytes()[0] != 3 {
+		t.Errorf("SetField did not replace the field: %v", wi.Fields)
+	}
+	wi.Data = map[uint16][]*io.SectionReader{273: {io.NewSectionReader(strings.NewReader("x"), 0, 1)}}
+	wi.SetField(NewField(273, 4, 1, []byte{0, 0, 0, 0}, bo, nil, nil))
+	if !wi.DeleteField(273) || wi.Field(273) != nil || wi.Data[273] != nil {
+		t.Error("DeleteField left the field or its data")
+	}
+	if wi.DeleteField(273) {
+		t.Error("DeleteField removed a field twice")
+	}
+}